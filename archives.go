@@ -0,0 +1,231 @@
+package ffcss
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// archiveMagicBytes maps the supported archive formats to the bytes their files start with.
+var archiveMagicBytes = map[string][][]byte{
+	// A local file header or, for empty archives, an end of central directory record
+	"zip":     {[]byte("PK\x03\x04"), []byte("PK\x05\x06")},
+	"tar.gz":  {[]byte("\x1f\x8b")},
+	"tar.xz":  {[]byte("\xfd7zXZ\x00")},
+	"tar.zst": {[]byte("\x28\xb5\x2f\xfd")},
+}
+
+// archiveExtensions maps file extensions to the archive format they denote.
+var archiveExtensions = map[string]string{
+	".zip":     "zip",
+	".tar.gz":  "tar.gz",
+	".tgz":     "tar.gz",
+	".tar.xz":  "tar.xz",
+	".txz":     "tar.xz",
+	".tar.zst": "tar.zst",
+	".tzst":    "tar.zst",
+}
+
+// archiveFormatOfURL returns the archive format denoted by the extension of the URL's path, or "" if it has none.
+func archiveFormatOfURL(URL string) string {
+	path := strings.ToLower(strings.SplitN(strings.SplitN(URL, "?", 2)[0], "#", 2)[0])
+	for extension, format := range archiveExtensions {
+		if strings.HasSuffix(path, extension) {
+			return format
+		}
+	}
+	return ""
+}
+
+// detectArchiveFormat returns the format of the archive at path (zip, tar.gz, tar.xz or tar.zst), according to its first bytes.
+// Servers often send archives with a generic Content-Type, so it can't be trusted.
+func detectArchiveFormat(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	read, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", fmt.Errorf("while reading %s: %w", path, err)
+	}
+	head = head[:read]
+	for format, magics := range archiveMagicBytes {
+		for _, magic := range magics {
+			if bytes.HasPrefix(head, magic) {
+				return format, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("expected an archive (zip, tar.gz, tar.xz or tar.zst), got %s", http.DetectContentType(head))
+}
+
+// extractArchive extracts the archive at path, of the given format, to destination.
+// It returns the paths of the extracted files.
+func extractArchive(path string, format string, destination string) ([]string, error) {
+	if format == "zip" {
+		return extractZip(path, destination)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return []string{}, err
+	}
+	defer file.Close()
+
+	var decompressed io.Reader
+	switch format {
+	case "tar.gz":
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return []string{}, fmt.Errorf("while decompressing: %w", err)
+		}
+		defer gzipReader.Close()
+		decompressed = gzipReader
+	case "tar.xz":
+		decompressed, err = xz.NewReader(file)
+		if err != nil {
+			return []string{}, fmt.Errorf("while decompressing: %w", err)
+		}
+	case "tar.zst":
+		zstdReader, err := zstd.NewReader(file)
+		if err != nil {
+			return []string{}, fmt.Errorf("while decompressing: %w", err)
+		}
+		defer zstdReader.Close()
+		decompressed = zstdReader
+	default:
+		return []string{}, fmt.Errorf("unsupported archive format %q", format)
+	}
+	return extractTar(decompressed, destination)
+}
+
+// extractTar extracts the tar stream to destination, and returns the paths of the extracted files.
+// Only directories and regular files are extracted. Entries that would end up outside of destination are refused.
+func extractTar(stream io.Reader, destination string) ([]string, error) {
+	extracted := make([]string, 0)
+	reader := tar.NewReader(stream)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return extracted, nil
+		}
+		if err != nil {
+			return extracted, fmt.Errorf("while reading archive: %w", err)
+		}
+
+		target, err := SafeJoin(destination, header.Name)
+		if err != nil {
+			return extracted, fmt.Errorf("invalid archive entry: %w", err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0700)
+			if err != nil {
+				return extracted, fmt.Errorf("while creating %s: %w", target, err)
+			}
+		case tar.TypeReg:
+			err = extractFile(reader, target)
+			if err != nil {
+				return extracted, fmt.Errorf("while extracting %s: %w", header.Name, err)
+			}
+			extracted = append(extracted, target)
+		default:
+			LogDebug("skipping archive entry %q of type %c", header.Name, header.Typeflag)
+		}
+	}
+}
+
+// extractZip extracts the zip file at path to destination, and returns the paths of the extracted files.
+// Only directories and regular files are extracted. Entries that would end up outside of destination are refused.
+func extractZip(path string, destination string) ([]string, error) {
+	extracted := make([]string, 0)
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return extracted, fmt.Errorf("while opening archive: %w", err)
+	}
+	defer archive.Close()
+
+	for _, entry := range archive.File {
+		target, err := SafeJoin(destination, entry.Name)
+		if err != nil {
+			return extracted, fmt.Errorf("invalid archive entry: %w", err)
+		}
+
+		switch {
+		case entry.Mode().IsDir():
+			err = os.MkdirAll(target, 0700)
+			if err != nil {
+				return extracted, fmt.Errorf("while creating %s: %w", target, err)
+			}
+		case entry.Mode().IsRegular():
+			content, err := entry.Open()
+			if err != nil {
+				return extracted, fmt.Errorf("while extracting %s: %w", entry.Name, err)
+			}
+			err = extractFile(content, target)
+			content.Close()
+			if err != nil {
+				return extracted, fmt.Errorf("while extracting %s: %w", entry.Name, err)
+			}
+			extracted = append(extracted, target)
+		default:
+			LogDebug("skipping archive entry %q of mode %s", entry.Name, entry.Mode())
+		}
+	}
+	return extracted, nil
+}
+
+// extractFile writes content to target, creating its parent directories.
+func extractFile(content io.Reader, target string) error {
+	err := os.MkdirAll(filepath.Dir(target), 0700)
+	if err != nil {
+		return fmt.Errorf("while creating %s: %w", filepath.Dir(target), err)
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("while creating %s: %w", target, err)
+	}
+	_, err = io.Copy(file, content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// archiveRoot returns the directory that should be considered as the theme's root in the extracted archive:
+// the directory containing the shallowest manifest if there is one, or else the only top-level directory of the archive if there is only one
+// (as is the case with archives of git repositories, such as the ones of GitHub releases).
+func archiveRoot(extractedTo string, extracted []string) string {
+	root := ""
+	for _, file := range extracted {
+		if filepath.Base(file) != "ffcss.yaml" {
+			continue
+		}
+		directory := filepath.Dir(file)
+		if root == "" || strings.Count(directory, string(filepath.Separator)) < strings.Count(root, string(filepath.Separator)) {
+			root = directory
+		}
+	}
+	if root != "" {
+		return root
+	}
+	entries, err := os.ReadDir(extractedTo)
+	if err == nil && len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(extractedTo, entries[0].Name())
+	}
+	return extractedTo
+}
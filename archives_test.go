@@ -0,0 +1,154 @@
+package ffcss
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/ulikunitz/xz"
+)
+
+// makeTarball returns a tar archive, compressed with the given format, containing the given files and symlinks.
+func makeTarball(t *testing.T, format string, files map[string]string, symlinks map[string]string) []byte {
+	var archive bytes.Buffer
+	var compressor io.WriteCloser
+	var err error
+	switch format {
+	case "tar.gz":
+		compressor = gzip.NewWriter(&archive)
+	case "tar.xz":
+		compressor, err = xz.NewWriter(&archive)
+	case "tar.zst":
+		compressor, err = zstd.NewWriter(&archive)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := tar.NewWriter(compressor)
+	for name, content := range files {
+		writer.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		writer.Write([]byte(content))
+	}
+	for name, target := range symlinks {
+		writer.WriteHeader(&tar.Header{Name: name, Linkname: target, Typeflag: tar.TypeSymlink})
+	}
+	writer.Close()
+	compressor.Close()
+	return archive.Bytes()
+}
+
+func TestDetectArchiveFormat(t *testing.T) {
+	directory := filepath.Join(testarea, "detectarchiveformat")
+	os.MkdirAll(directory, 0700)
+
+	format, err := detectArchiveFormat(filepath.Join("testdata", "materialfox.zip"))
+	assert.NoError(t, err)
+	assert.Equal(t, "zip", format)
+
+	os.WriteFile(filepath.Join(directory, "empty.zip"), []byte("PK\x05\x06"+string(make([]byte, 18))), 0600)
+	format, err = detectArchiveFormat(filepath.Join(directory, "empty.zip"))
+	assert.NoError(t, err)
+	assert.Equal(t, "zip", format)
+
+	for _, expected := range []string{"tar.gz", "tar.xz", "tar.zst"} {
+		os.WriteFile(filepath.Join(directory, "theme"), makeTarball(t, expected, map[string]string{"ffcss.yaml": "name: a"}, nil), 0600)
+		format, err = detectArchiveFormat(filepath.Join(directory, "theme"))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, format)
+	}
+
+	_, err = detectArchiveFormat(filepath.Join("testdata", "htmlfile.html"))
+	assert.EqualError(t, err, "expected an archive (zip, tar.gz, tar.xz or tar.zst), got text/html; charset=utf-8")
+
+	os.WriteFile(filepath.Join(directory, "empty"), []byte{}, 0600)
+	_, err = detectArchiveFormat(filepath.Join(directory, "empty"))
+	assert.EqualError(t, err, "expected an archive (zip, tar.gz, tar.xz or tar.zst), got text/plain; charset=utf-8")
+}
+
+func TestArchiveFormatOfURL(t *testing.T) {
+	assert.Equal(t, "tar.gz", archiveFormatOfURL("https://example.com/theme-1.0.TGZ"))
+	assert.Equal(t, "tar.xz", archiveFormatOfURL("https://example.com/theme.tar.xz?download=1"))
+	assert.Equal(t, "tar.zst", archiveFormatOfURL("https://example.com/theme.tar.zst#latest"))
+	assert.Equal(t, "zip", archiveFormatOfURL("https://example.com/theme.zip"))
+	assert.Equal(t, "", archiveFormatOfURL("https://example.com/theme.tar"))
+	assert.Equal(t, "css", sourceTypeOfURL("https://example.com/userChrome.css?v=2"))
+	assert.Equal(t, "archive", sourceTypeOfURL("https://example.com/theme.tar.gz"))
+	assert.Equal(t, "", sourceTypeOfURL("https://example.com/theme"))
+}
+
+func TestExtractArchive(t *testing.T) {
+	for _, format := range []string{"tar.gz", "tar.xz", "tar.zst"} {
+		directory := filepath.Join(testarea, "extractarchive", format)
+		os.MkdirAll(directory, 0700)
+		archive := filepath.Join(directory, "theme."+format)
+		os.WriteFile(archive, makeTarball(t, format, map[string]string{
+			"theme-1.0/ffcss.yaml":            "name: theme",
+			"theme-1.0/chrome/userChrome.css": "#nav-bar {}",
+		}, map[string]string{
+			"theme-1.0/chrome/link": "/etc/passwd",
+		}), 0600)
+
+		extracted, err := extractArchive(archive, format, filepath.Join(directory, "extracted"))
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			filepath.Join(directory, "extracted", "theme-1.0", "ffcss.yaml"),
+			filepath.Join(directory, "extracted", "theme-1.0", "chrome", "userChrome.css"),
+		}, extracted)
+		assert.NoFileExists(t, filepath.Join(directory, "extracted", "theme-1.0", "chrome", "link"))
+		assert.Equal(t, filepath.Join(directory, "extracted", "theme-1.0"), archiveRoot(filepath.Join(directory, "extracted"), extracted))
+	}
+
+	directory := filepath.Join(testarea, "extractarchive", "escaping")
+	os.MkdirAll(directory, 0700)
+	archive := filepath.Join(directory, "theme.tar.gz")
+	os.WriteFile(archive, makeTarball(t, "tar.gz", map[string]string{"../escaped.css": "* {}"}, nil), 0600)
+	_, err := extractArchive(archive, "tar.gz", filepath.Join(directory, "extracted"))
+	assert.EqualError(t, err, `invalid archive entry: "../escaped.css" goes up too many directories, refusing to go outside of `+filepath.Join(directory, "extracted"))
+	assert.NoFileExists(t, filepath.Join(directory, "escaped.css"))
+}
+
+func TestThemeNameFromCSSURL(t *testing.T) {
+	assert.Equal(t, "blurredfox", themeNameFromCSSURL("https://example.com/themes/BlurredFox.css"))
+	assert.Equal(t, "simplefox", themeNameFromCSSURL("https://raw.githubusercontent.com/migueravila/SimpleFox/master/chrome/userChrome.css"))
+	assert.Equal(t, "someone-gist-0123456", themeNameFromCSSURL("https://gist.githubusercontent.com/someone/0123456789abcdef/raw/userChrome.css"))
+	assert.Equal(t, "example.com", themeNameFromCSSURL("https://www.example.com/userChrome.css"))
+	assert.Equal(t, "my-theme", themeNameFromCSSURL("https://example.com/my%20theme.css"))
+}
+
+func TestDownloadCSS(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		w.Write([]byte("#nav-bar { display: none; }"))
+	}))
+	defer server.Close()
+
+	directory := filepath.Join(testarea, "downloadcss")
+	os.MkdirAll(directory, 0700)
+	manifest, err := DownloadCSS(server.URL+"/themes/hidenavbar.css", directory)
+	assert.NoError(t, err)
+	assert.Equal(t, "hidenavbar", manifest.Name())
+	assert.Equal(t, "hidenavbar.css", manifest.UserChrome)
+	assert.Equal(t, "", manifest.UserContent)
+	assert.Equal(t, CacheDir("hidenavbar", RootVariantName), manifest.DownloadedTo)
+	assert.FileExists(t, filepath.Join(manifest.DownloadedTo, "hidenavbar.css"))
+
+	written, err := LoadManifest(filepath.Join(manifest.DownloadedTo, "ffcss.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/themes/hidenavbar.css", written.DownloadAt)
+	assert.Equal(t, "hidenavbar.css", written.UserChrome)
+
+	directory = filepath.Join(testarea, "downloadcss-content")
+	os.MkdirAll(directory, 0700)
+	manifest, err = DownloadCSS(server.URL+"/darkreader/userContent.css", directory)
+	assert.NoError(t, err)
+	assert.Equal(t, "userContent.css", manifest.UserContent)
+	assert.Equal(t, "", manifest.UserChrome)
+}
@@ -27,22 +27,27 @@ func renderFileTemplate(f FileTemplate, operatingSystem string, variant Variant,
 
 // DestinationPathOfAsset computes the destination path of some asset from its path and the destination profile directory
 // It is assumed that assetPath is absolute.
+// An error is returned if the asset is outside of the theme's root, or if its destination is outside of the profile's chrome directory.
 func (t Theme) DestinationPathOfAsset(assetPath string, profileDir string, operatingSystem string, variant Variant) (string, error) {
-	if !strings.HasPrefix(assetPath, t.DownloadedTo) {
-		return "", fmt.Errorf("asset %q is outside of the theme's root %q", assetPath, t.DownloadedTo)
+	if err := EnsureWithin(t.DownloadedTo, assetPath); err != nil {
+		return "", fmt.Errorf("asset %q is outside of the theme's root %q: %w", assetPath, t.DownloadedTo, err)
 	}
 
-	relativeTo := filepath.Clean(filepath.Join(t.DownloadedTo, filepath.Clean(renderFileTemplate(t.CopyFrom, operatingSystem, variant, t.OSNames))))
-	if !strings.HasPrefix(relativeTo, t.DownloadedTo) {
-		return "", fmt.Errorf("copy from %q is outside of the theme's root %q", relativeTo, t.DownloadedTo)
+	relativeTo, err := SafeJoin(t.DownloadedTo, renderFileTemplate(t.CopyFrom, operatingSystem, variant, t.OSNames))
+	if err != nil {
+		return "", fmt.Errorf("copy from %q is outside of the theme's root %q: %w", t.CopyFrom, t.DownloadedTo, err)
 	}
 
-	relativised, err := filepath.Rel(relativeTo, assetPath)
+	relativised, err := filepath.Rel(relativeTo, filepath.Clean(assetPath))
 	if err != nil {
-		return "", fmt.Errorf("couldn't make %s relative to %s: %w", assetPath, filepath.Join(t.DownloadedTo, filepath.Clean(t.CopyFrom)), err)
+		return "", fmt.Errorf("couldn't make %s relative to %s: %w", assetPath, relativeTo, err)
 	}
 
-	return filepath.Join(profileDir, "chrome", relativised), nil
+	destination, err := SafeJoin(filepath.Join(profileDir, "chrome"), relativised)
+	if err != nil {
+		return "", fmt.Errorf("asset %q is outside of the %q directory set by copy from: %w", assetPath, t.CopyFrom, err)
+	}
+	return destination, nil
 }
 
 // AssetsPaths returns the individual file paths of all assets.
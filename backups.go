@@ -0,0 +1,260 @@
+package ffcss
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultBackupsToKeep is the number of backups kept per profile when none is specified.
+const DefaultBackupsToKeep = 5
+
+// backupTimeFormat is the format of the timestamp that starts each backup's ID, so that IDs sort chronologically.
+const backupTimeFormat = "20060102-150405"
+
+// Backup is a copy of a profile's chrome/ directory and user.js, taken before they were replaced or removed by ffcss.
+// Backups are stored in the ffcss-backups/ directory of the profile, one directory per backup.
+type Backup struct {
+	// ID is the name of the backup's directory: a timestamp followed by the name of the theme that was active.
+	ID        string       `yaml:"-"`
+	Path      string       `yaml:"-"`
+	CreatedAt time.Time    `yaml:"created at"`
+	Theme     CurrentTheme `yaml:",omitempty"`
+	// Installed is the install record of the theme that was active, if it was installed by ffcss.
+	Installed *InstallRecord `yaml:",omitempty"`
+}
+
+// backupMetadataFile is the name of the file describing a backup, in the backup's directory.
+const backupMetadataFile = "backup.yaml"
+
+// BackupsDir returns the directory where the backups of the profile are stored.
+func (ffp FirefoxProfile) BackupsDir() string {
+	return filepath.Join(ffp.Path, "ffcss-backups")
+}
+
+// Description returns a human-readable description of what the backup contains.
+func (backup Backup) Description() string {
+	if backup.Theme.Name == "" {
+		return "no ffcss theme"
+	}
+	description := backup.Theme.Name
+	if backup.Theme.Variant != "" {
+		description += " (" + backup.Theme.Variant + ")"
+	}
+	if len(backup.Theme.Components) > 0 {
+		description += " with " + strings.Join(backup.Theme.Components, ", ")
+	}
+	return description
+}
+
+// BackUp moves the profile's chrome/ directory and user.js to a new backup, tagged with the profile's current theme.
+// found is false if there was nothing to back up, in which case no backup is created.
+func (ffp FirefoxProfile) BackUp() (backup Backup, found bool, err error) {
+	return ffp.backUpFrom(ffp.Path)
+}
+
+// backUpFrom moves the chrome/ directory and user.js found in directory to a new backup of the profile, tagged with the profile's current theme.
+func (ffp FirefoxProfile) backUpFrom(directory string) (backup Backup, found bool, err error) {
+	for _, name := range installationSwappedFiles {
+		if _, err := os.Stat(filepath.Join(directory, name)); err == nil {
+			found = true
+		}
+	}
+	if !found {
+		return Backup{}, false, nil
+	}
+
+	backup, err = ffp.newBackup(time.Now())
+	if err != nil {
+		return backup, true, err
+	}
+	for _, name := range installationSwappedFiles {
+		err = renameIfExists(filepath.Join(directory, name), filepath.Join(backup.Path, name))
+		if err != nil {
+			return backup, true, fmt.Errorf("while backing up %s: %w", name, err)
+		}
+	}
+	LogDebug("backed up %s to %s", directory, backup.Path)
+	return backup, true, nil
+}
+
+// newBackup creates an empty backup directory, with metadata describing the profile's current theme.
+func (ffp FirefoxProfile) newBackup(createdAt time.Time) (Backup, error) {
+	backup := Backup{CreatedAt: createdAt}
+
+	currentThemes, err := CurrentThemeDetailsByProfile()
+	if err != nil {
+		return backup, fmt.Errorf("while getting the current theme: %w", err)
+	}
+	backup.Theme = currentThemes[ffp.FullName()]
+
+	record, found, err := ffp.InstallRecord()
+	if err != nil {
+		return backup, err
+	}
+	if found {
+		backup.Installed = &record
+	}
+
+	err = os.MkdirAll(ffp.BackupsDir(), 0700)
+	if err != nil {
+		return backup, fmt.Errorf("while creating backups directory: %w", err)
+	}
+
+	themeName := "none"
+	if backup.Theme.Name != "" {
+		themeName = backupIDSafe.Replace(backup.Theme.Name)
+	}
+	baseID := createdAt.Format(backupTimeFormat) + "_" + themeName
+	backup.ID = baseID
+	// Several backups can be created during the same second
+	for suffix := 2; ; suffix++ {
+		backup.Path = filepath.Join(ffp.BackupsDir(), backup.ID)
+		err = os.Mkdir(backup.Path, 0700)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return backup, fmt.Errorf("while creating backup directory: %w", err)
+		}
+		backup.ID = fmt.Sprintf("%s-%d", baseID, suffix)
+	}
+
+	metadata, err := yaml.Marshal(backup)
+	if err != nil {
+		return backup, fmt.Errorf("while marshaling into YAML: %w", err)
+	}
+	err = os.WriteFile(filepath.Join(backup.Path, backupMetadataFile), metadata, 0600)
+	if err != nil {
+		return backup, fmt.Errorf("while writing backup metadata: %w", err)
+	}
+	return backup, nil
+}
+
+// backupIDSafe replaces characters of theme names that are not safe in directory names.
+var backupIDSafe = strings.NewReplacer("/", "-", "\\", "-", ":", "-", " ", "-", "_", "-")
+
+// Backups returns the backups of the profile, most recent first.
+func (ffp FirefoxProfile) Backups() ([]Backup, error) {
+	backups := make([]Backup, 0)
+	entries, err := os.ReadDir(ffp.BackupsDir())
+	if os.IsNotExist(err) {
+		return backups, nil
+	}
+	if err != nil {
+		return backups, fmt.Errorf("while listing backups: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		backup := Backup{ID: entry.Name(), Path: filepath.Join(ffp.BackupsDir(), entry.Name())}
+		metadata, err := os.ReadFile(filepath.Join(backup.Path, backupMetadataFile))
+		if os.IsNotExist(err) {
+			LogDebug("ignoring %s: no %s", backup.Path, backupMetadataFile)
+			continue
+		}
+		if err != nil {
+			return backups, fmt.Errorf("while reading metadata of backup %s: %w", backup.ID, err)
+		}
+		err = yaml.Unmarshal(metadata, &backup)
+		if err != nil {
+			return backups, fmt.Errorf("while parsing metadata of backup %s: %w", backup.ID, err)
+		}
+		backups = append(backups, backup)
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		if backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].ID > backups[j].ID
+		}
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// FindBackup returns the backup of the profile designated by reference,
+// which is either a backup ID, or its position in the list of backups (1 being the most recent one).
+// An empty reference designates the most recent backup.
+func (ffp FirefoxProfile) FindBackup(reference string) (Backup, error) {
+	backups, err := ffp.Backups()
+	if err != nil {
+		return Backup{}, err
+	}
+	if len(backups) == 0 {
+		return Backup{}, fmt.Errorf("profile %s has no backups", ffp)
+	}
+	if reference == "" {
+		return backups[0], nil
+	}
+	for i, backup := range backups {
+		if backup.ID == reference || fmt.Sprint(i+1) == reference {
+			return backup, nil
+		}
+	}
+	return Backup{}, fmt.Errorf("profile %s has no backup %q. Use ffcss backups list to see available backups", ffp, reference)
+}
+
+// PruneBackups removes the oldest backups of the profile, so that at most keep backups remain,
+// besides the backups whose IDs are in except, which are never removed. It returns the removed backups.
+func (ffp FirefoxProfile) PruneBackups(keep int, except ...string) ([]Backup, error) {
+	removed := make([]Backup, 0)
+	backups, err := ffp.Backups()
+	if err != nil {
+		return removed, err
+	}
+	if keep < 0 || len(backups) <= keep {
+		return removed, nil
+	}
+	for _, backup := range backups[keep:] {
+		if contains(except, backup.ID) {
+			continue
+		}
+		err = os.RemoveAll(backup.Path)
+		if err != nil {
+			return removed, fmt.Errorf("while removing backup %s: %w", backup.ID, err)
+		}
+		removed = append(removed, backup)
+	}
+	return removed, nil
+}
+
+// Restore puts the backup's chrome/ directory and user.js back in the profile.
+// The profile's current chrome/ directory and user.js are backed up first, so that restoring can be undone.
+// The backup itself is kept. The theme it was tagged with becomes the profile's current theme again.
+func (ffp FirefoxProfile) Restore(backup Backup) error {
+	_, _, err := ffp.BackUp()
+	if err != nil {
+		return fmt.Errorf("while backing up the current theme: %w", err)
+	}
+
+	for _, name := range installationSwappedFiles {
+		err = copyIfExists(filepath.Join(backup.Path, name), filepath.Join(ffp.Path, name))
+		if err != nil {
+			return fmt.Errorf("while restoring %s: %w", name, err)
+		}
+	}
+
+	if backup.Installed != nil {
+		err = ffp.RecordInstallation(*backup.Installed)
+	} else {
+		err = os.Remove(ffp.installRecordPath())
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("while restoring install record: %w", err)
+	}
+
+	if backup.Theme.Name == "" {
+		return ffp.UnregisterCurrentTheme()
+	}
+	return ffp.RegisterCurrentTheme(backup.Theme)
+}
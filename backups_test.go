@@ -0,0 +1,94 @@
+package ffcss
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackups(t *testing.T) {
+	profile := NewFirefoxProfileFromPath(filepath.Join(testarea, "backups", "abcdefgh.backedup"))
+	inProfile := func(path string) string {
+		return filepath.Join(profile.Path, path)
+	}
+	readFile := func(path string) string {
+		content, _ := os.ReadFile(path)
+		return string(content)
+	}
+	install := func(theme string) {
+		os.MkdirAll(inProfile("chrome"), 0700)
+		os.WriteFile(inProfile("chrome/userChrome.css"), []byte("/* "+theme+" */"), 0700)
+		os.WriteFile(inProfile("user.js"), []byte("// "+theme), 0700)
+		assert.NoError(t, profile.RegisterCurrentTheme(CurrentTheme{Name: theme}))
+	}
+	// Start from an empty profile, so that backups of previous runs aren't counted
+	os.RemoveAll(filepath.Dir(profile.Path))
+	os.MkdirAll(profile.Path, 0700)
+
+	_, found, err := profile.BackUp()
+	assert.NoError(t, err)
+	assert.False(t, found)
+	_, err = profile.FindBackup("")
+	assert.EqualError(t, err, "profile backedup (abcdefgh) has no backups")
+
+	for _, theme := range []string{"first", "second", "third"} {
+		install(theme)
+		backup, found, err := profile.BackUp()
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, theme, backup.Theme.Name)
+		assert.Regexp(t, `^\d{8}-\d{6}_`+theme+`(-\d+)?$`, backup.ID)
+		assert.NoDirExists(t, inProfile("chrome"))
+		assert.NoFileExists(t, inProfile("user.js"))
+	}
+
+	backups, err := profile.Backups()
+	assert.NoError(t, err)
+	if assert.Len(t, backups, 3) {
+		assert.Equal(t, "third", backups[0].Description())
+		assert.Equal(t, "first", backups[2].Description())
+	}
+
+	backup, err := profile.FindBackup("2")
+	assert.NoError(t, err)
+	assert.Equal(t, "second", backup.Theme.Name)
+	backup, err = profile.FindBackup(backups[2].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", backup.Theme.Name)
+	_, err = profile.FindBackup("4")
+	assert.Error(t, err)
+
+	install("fourth")
+	assert.NoError(t, profile.Restore(backup))
+	assert.Equal(t, "/* first */", readFile(inProfile("chrome/userChrome.css")))
+	assert.Equal(t, "// first", readFile(inProfile("user.js")))
+	currentThemes, err := CurrentThemeByProfile()
+	assert.NoError(t, err)
+	assert.Equal(t, "first", currentThemes[profile.FullName()])
+	assert.DirExists(t, backup.Path)
+
+	backups, _ = profile.Backups()
+	if assert.Len(t, backups, 4) {
+		assert.Equal(t, "fourth", backups[0].Theme.Name)
+	}
+
+	removed, err := profile.PruneBackups(2, backup.ID)
+	assert.NoError(t, err)
+	assert.Len(t, removed, 1)
+	backups, _ = profile.Backups()
+	if assert.Len(t, backups, 3) {
+		assert.Equal(t, "first", backups[2].Theme.Name)
+	}
+
+	removed, err = profile.PruneBackups(2)
+	assert.NoError(t, err)
+	assert.Len(t, removed, 1)
+	backups, _ = profile.Backups()
+	if assert.Len(t, backups, 2) {
+		assert.Equal(t, "fourth", backups[0].Theme.Name)
+		assert.Equal(t, "third", backups[1].Theme.Name)
+	}
+	assert.NoError(t, profile.UnregisterCurrentTheme())
+}
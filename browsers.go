@@ -0,0 +1,172 @@
+package ffcss
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Browser is a Firefox-based browser, or a packaging of one, that stores its profiles in its own directory.
+type Browser struct {
+	// ID is what --browser accepts to select the browser, e.g. librewolf or firefox-flatpak
+	ID   string
+	Name string
+	// profilesDirs maps operating systems to the directory containing the browser's profiles, relative to the home directory.
+	// On Windows, the home directory is the Roaming application data directory.
+	profilesDirs map[string][]string
+}
+
+// Browsers is the registry of known browsers.
+// Firefox Developer Edition and Nightly store their profiles with Firefox's, so their profiles are listed under Firefox.
+var Browsers = []Browser{
+	{
+		ID:   "firefox",
+		Name: "Firefox",
+		profilesDirs: map[string][]string{
+			"linux":   {".mozilla", "firefox"},
+			"macos":   {"Library", "Application Support", "Firefox", "Profiles"},
+			"windows": {"Mozilla", "Firefox", "Profiles"},
+		},
+	},
+	{
+		ID:           "firefox-flatpak",
+		Name:         "Firefox (Flatpak)",
+		profilesDirs: map[string][]string{"linux": {".var", "app", "org.mozilla.firefox", ".mozilla", "firefox"}},
+	},
+	{
+		ID:           "firefox-snap",
+		Name:         "Firefox (Snap)",
+		profilesDirs: map[string][]string{"linux": {"snap", "firefox", "common", ".mozilla", "firefox"}},
+	},
+	{
+		ID:   "librewolf",
+		Name: "LibreWolf",
+		profilesDirs: map[string][]string{
+			"linux":   {".librewolf"},
+			"macos":   {"Library", "Application Support", "librewolf", "Profiles"},
+			"windows": {"librewolf", "Profiles"},
+		},
+	},
+	{
+		ID:           "librewolf-flatpak",
+		Name:         "LibreWolf (Flatpak)",
+		profilesDirs: map[string][]string{"linux": {".var", "app", "io.gitlab.librewolf-community", ".librewolf"}},
+	},
+	{
+		ID:   "waterfox",
+		Name: "Waterfox",
+		profilesDirs: map[string][]string{
+			"linux":   {".waterfox"},
+			"macos":   {"Library", "Application Support", "Waterfox", "Profiles"},
+			"windows": {"Waterfox", "Profiles"},
+		},
+	},
+	{
+		ID:   "floorp",
+		Name: "Floorp",
+		profilesDirs: map[string][]string{
+			"linux":   {".floorp"},
+			"macos":   {"Library", "Application Support", "Floorp", "Profiles"},
+			"windows": {"Floorp", "Profiles"},
+		},
+	},
+	{
+		ID:           "floorp-flatpak",
+		Name:         "Floorp (Flatpak)",
+		profilesDirs: map[string][]string{"linux": {".var", "app", "one.ablaze.floorp", ".floorp"}},
+	},
+}
+
+// BrowserIDs returns the IDs of all known browsers.
+func BrowserIDs() []string {
+	ids := make([]string, 0, len(Browsers))
+	for _, browser := range Browsers {
+		ids = append(ids, browser.ID)
+	}
+	return ids
+}
+
+// FindBrowser returns the known browser with the given ID or name.
+func FindBrowser(idOrName string) (Browser, error) {
+	for _, browser := range Browsers {
+		if lookupPreprocess(browser.ID) == lookupPreprocess(idOrName) || lookupPreprocess(browser.Name) == lookupPreprocess(idOrName) {
+			return browser, nil
+		}
+	}
+	return Browser{}, fmt.Errorf("unknown browser %q. Known browsers are %s", idOrName, strings.Join(BrowserIDs(), ", "))
+}
+
+// ProfilesDir returns the directory containing the browser's profiles on the given operating system.
+func (b Browser) ProfilesDir(operatingSystem string) (string, error) {
+	segments, ok := b.profilesDirs[operatingSystem]
+	if !ok {
+		return "", fmt.Errorf("%s is not available on %s", b.Name, operatingSystem)
+	}
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if operatingSystem == "windows" {
+		homedir = filepath.Join(homedir, "AppData", "Roaming")
+	}
+	return filepath.Join(homedir, filepath.Join(segments...)), nil
+}
+
+// InstalledBrowsers returns the known browsers whose profiles directory exists on the given operating system.
+func InstalledBrowsers(operatingSystem string) []Browser {
+	installed := make([]Browser, 0)
+	for _, browser := range Browsers {
+		profilesDir, err := browser.ProfilesDir(operatingSystem)
+		if err != nil {
+			continue
+		}
+		if stat, err := os.Stat(profilesDir); err == nil && stat.IsDir() {
+			installed = append(installed, browser)
+		}
+	}
+	return installed
+}
+
+// BrowserProfiles returns the profiles of the given browser, or of all installed browsers if browserID is empty.
+// Each profile's Browser is set to the name of the browser it belongs to.
+func BrowserProfiles(operatingSystem string, browserID string) ([]FirefoxProfile, error) {
+	browsers := InstalledBrowsers(operatingSystem)
+	if browserID == "" && len(browsers) == 0 {
+		// Let Profiles explain why there are no profiles
+		return Profiles("")
+	}
+	if browserID != "" {
+		browser, err := FindBrowser(browserID)
+		if err != nil {
+			return []FirefoxProfile{}, err
+		}
+		browsers = []Browser{browser}
+	}
+
+	profiles := make([]FirefoxProfile, 0)
+	for _, browser := range browsers {
+		profilesDir, err := browser.ProfilesDir(operatingSystem)
+		if err != nil {
+			return profiles, err
+		}
+		browserProfiles, err := Profiles(profilesDir)
+		if err != nil {
+			return profiles, fmt.Errorf("while getting %s profiles: %w", browser.Name, err)
+		}
+		for _, profile := range browserProfiles {
+			profile.Browser = browser.Name
+			profiles = append(profiles, profile)
+		}
+	}
+	return profiles, nil
+}
+
+// SelectableProfiles returns the profiles of profilesDir, or, if it is empty, the profiles of the given browser (see BrowserProfiles).
+func SelectableProfiles(profilesDir string, browserID string) ([]FirefoxProfile, error) {
+	if profilesDir != "" {
+		return Profiles(profilesDir)
+	}
+	return BrowserProfiles(GOOStoOS(runtime.GOOS), browserID)
+}
@@ -0,0 +1,70 @@
+package ffcss
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindBrowser(t *testing.T) {
+	browser, err := FindBrowser("LibreWolf (Flatpak)")
+	assert.NoError(t, err)
+	assert.Equal(t, "librewolf-flatpak", browser.ID)
+
+	browser, err = FindBrowser("firefox_snap")
+	assert.NoError(t, err)
+	assert.Equal(t, "Firefox (Snap)", browser.Name)
+
+	_, err = FindBrowser("chromium")
+	assert.EqualError(t, err, `unknown browser "chromium". Known browsers are firefox, firefox-flatpak, firefox-snap, librewolf, librewolf-flatpak, waterfox, floorp, floorp-flatpak`)
+}
+
+func TestBrowserProfilesDir(t *testing.T) {
+	browser, _ := FindBrowser("firefox-flatpak")
+	dir, err := browser.ProfilesDir("linux")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(mockedHomedir, ".var", "app", "org.mozilla.firefox", ".mozilla", "firefox"), dir)
+
+	_, err = browser.ProfilesDir("windows")
+	assert.EqualError(t, err, "Firefox (Flatpak) is not available on windows")
+
+	browser, _ = FindBrowser("waterfox")
+	dir, err = browser.ProfilesDir("windows")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(mockedHomedir, "AppData", "Roaming", "Waterfox", "Profiles"), dir)
+}
+
+func TestBrowserProfiles(t *testing.T) {
+	librewolfDir := filepath.Join(mockedHomedir, ".librewolf")
+	os.MkdirAll(filepath.Join(librewolfDir, "wolfwolf.default-default"), 0700)
+	defer os.RemoveAll(librewolfDir)
+
+	installed := InstalledBrowsers("linux")
+	if assert.Len(t, installed, 2) {
+		assert.Equal(t, "firefox", installed[0].ID)
+		assert.Equal(t, "librewolf", installed[1].ID)
+	}
+
+	profiles, err := BrowserProfiles("linux", "")
+	assert.NoError(t, err)
+	if assert.Len(t, profiles, 2) {
+		assert.Equal(t, "Firefox", profiles[0].Browser)
+		assert.Equal(t, "667ekipp", profiles[0].ID)
+		assert.Equal(t, "LibreWolf", profiles[1].Browser)
+		assert.Equal(t, "wolfwolf", profiles[1].ID)
+	}
+
+	profiles, err = BrowserProfiles("linux", "librewolf")
+	assert.NoError(t, err)
+	assert.Equal(t, []FirefoxProfile{{
+		ID:      "wolfwolf",
+		Name:    "default-default",
+		Path:    filepath.Join(librewolfDir, "wolfwolf.default-default"),
+		Browser: "LibreWolf",
+	}}, profiles)
+
+	_, err = BrowserProfiles("linux", "floorp")
+	assert.Error(t, err)
+}
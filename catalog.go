@@ -2,9 +2,11 @@ package ffcss
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/hbollon/go-edlib"
@@ -38,6 +40,133 @@ func (store Catalog) Lookup(query string) (Theme, error) {
 	return Theme{}, fmt.Errorf("theme %q not found", originalQuery)
 }
 
+// Themes returns the catalog's themes, sorted by name.
+func (store Catalog) Themes() []Theme {
+	themes := make([]Theme, 0, len(store))
+	for _, theme := range store {
+		themes = append(themes, theme)
+	}
+	sort.Slice(themes, func(i, j int) bool {
+		return themes[i].Name() < themes[j].Name()
+	})
+	return themes
+}
+
+// SearchMatch is a theme matching a search query, see (Catalog).Search.
+type SearchMatch struct {
+	Theme Theme
+	// Score is between 0 and 1, 1 being a perfect match
+	Score float32
+}
+
+// Weights of the fields of a theme in search scores: a match on the name counts more than a match on the description
+const (
+	searchNameWeight        = 1
+	searchTagsWeight        = 0.9
+	searchAuthorWeight      = 0.8
+	searchDescriptionWeight = 0.7
+)
+
+// searchThreshold is the minimum similarity (see edlib.StringsSimilarity) of a query's word with a theme's word for it to match.
+const searchThreshold = 0.75
+
+var wordSeparators = regexp.MustCompile(`[^\pL\pN]+`)
+
+// Search returns the themes that match query, best matches first.
+// The query's words are fuzzy-matched (see lookupPreprocess) with the themes' names, tags, authors and descriptions' words.
+// A theme matches if all of the query's words match one of these.
+func (store Catalog) Search(query string) []SearchMatch {
+	queryWords := searchWords(query)
+	if len(queryWords) == 0 {
+		return []SearchMatch{}
+	}
+	matches := make([]SearchMatch, 0)
+	for _, theme := range store.Themes() {
+		// The whole query can also match a name made of several words, e.g. "material fox" for materialfox
+		wholeQueryScore := searchNameWeight * similarity(lookupPreprocess(query), []string{lookupPreprocess(theme.Name())})
+
+		var wordsScore float32
+		for _, word := range queryWords {
+			best := maxFloat32(
+				searchNameWeight*similarity(word, searchWords(theme.Name())),
+				searchTagsWeight*similarity(word, searchWords(strings.Join(theme.Tags, " "))),
+				searchAuthorWeight*similarity(word, searchWords(theme.AuthorName())),
+				searchDescriptionWeight*similarity(word, searchWords(theme.Description)),
+			)
+			if best == 0 {
+				wordsScore = 0
+				break
+			}
+			wordsScore += best / float32(len(queryWords))
+		}
+
+		if score := maxFloat32(wholeQueryScore, wordsScore); score > 0 {
+			matches = append(matches, SearchMatch{Theme: theme, Score: float32(math.Round(float64(score)*100) / 100)})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	return matches
+}
+
+// searchWords splits s into words, preprocessed with lookupPreprocess.
+func searchWords(s string) []string {
+	words := make([]string, 0)
+	for _, word := range wordSeparators.Split(s, -1) {
+		if word = lookupPreprocess(word); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// similarity returns how similar word is to the most similar of candidates, from 0 to 1, or 0 if none is similar enough (see searchThreshold).
+// Words are also fuzzy-matched with parts of candidates (if they have at least 3 characters), with a lower score,
+// so that "materal" matches "materialfox".
+func similarity(word string, candidates []string) float32 {
+	var best float32
+	for _, candidate := range candidates {
+		if candidate == word {
+			return 1
+		}
+		if score, _ := edlib.StringsSimilarity(word, candidate, edlib.Levenshtein); score >= searchThreshold {
+			best = maxFloat32(best, score)
+		}
+		if len([]rune(word)) < 3 {
+			continue
+		}
+		if score := partialSimilarity(word, candidate); score >= searchThreshold {
+			best = maxFloat32(best, 0.9*score)
+		}
+	}
+	return best
+}
+
+// partialSimilarity returns how similar word is to the most similar part of candidate, from 0 to 1.
+// Parts have the length of word, give or take a character.
+func partialSimilarity(word string, candidate string) float32 {
+	wordLength, candidateRunes := len([]rune(word)), []rune(candidate)
+	var best float32
+	for length := wordLength - 1; length <= wordLength+1; length++ {
+		for start := 0; start+length <= len(candidateRunes); start++ {
+			score, _ := edlib.StringsSimilarity(word, string(candidateRunes[start:start+length]), edlib.Levenshtein)
+			best = maxFloat32(best, score)
+		}
+	}
+	return best
+}
+
+func maxFloat32(values ...float32) float32 {
+	var max float32
+	for _, value := range values {
+		if value > max {
+			max = value
+		}
+	}
+	return max
+}
+
 // lookupPreprocess applies transformations to s so that it can be compared
 // to search for something.
 // For example, it is used by (ThemeStore).Lookup
@@ -45,9 +174,30 @@ func lookupPreprocess(s string) string {
 	return strings.ToLower(regexp.MustCompile(`[-_ .]`).ReplaceAllString(norm.NFKD.String(s), ""))
 }
 
-// LoadCatalog loads a directory of theme manifests.
+// LoadCatalog loads a directory of theme manifests, along with the themes synced from the registry (see SyncRegistry).
 // Keys are theme names (files' basenames with the .yaml removed).
-func LoadCatalog(storeDirectory string) (themes Catalog, err error) {
+// Manifests of storeDirectory replace the registry's manifests of the same name. Missing directories are treated as empty.
+// Manifests that extend others by URL may download them, see LoadManifest.
+func LoadCatalog(storeDirectory string) (Catalog, error) {
+	themes, err := loadCatalogDirectory(RegistryDir("themes"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("while loading the themes synced from the registry: %w", err)
+	}
+	if themes == nil {
+		themes = make(Catalog)
+	}
+	local, err := loadCatalogDirectory(storeDirectory)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for name, theme := range local {
+		themes[name] = theme
+	}
+	return themes, nil
+}
+
+// loadCatalogDirectory loads a directory of theme manifests.
+func loadCatalogDirectory(storeDirectory string) (themes Catalog, err error) {
 	themeNamePattern := regexp.MustCompile(`^(.+)\.ya?ml$`)
 	themes = make(Catalog)
 	manifests, err := os.ReadDir(storeDirectory)
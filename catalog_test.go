@@ -69,3 +69,32 @@ func TestLoadCatalog(t *testing.T) {
 	sort.Strings(actualNames)
 	assert.Equal(t, []string{"alpenblue", "flyingfox", "montereyfox", "sometheme"}, actualNames)
 }
+
+func TestCatalogSearch(t *testing.T) {
+	materialfox := makeTheme("materialfox")
+	materialfox.DownloadAt = "https://github.com/muckSponge/MaterialFox"
+	simplefox := makeTheme("simplefox")
+	simplefox.Description = "A clean and minimalistic theme"
+	simplefox.Tags = []string{"minimal", "dark"}
+	blurredfox := makeTheme("blurredfox")
+	blurredfox.Author = "manilarome"
+	catalog := Catalog{"materialfox": materialfox, "simplefox": simplefox, "blurredfox": blurredfox}
+
+	names := func(matches []SearchMatch) []string {
+		matchedNames := make([]string, 0, len(matches))
+		for _, match := range matches {
+			matchedNames = append(matchedNames, match.Theme.Name())
+		}
+		return matchedNames
+	}
+
+	assert.Equal(t, []string{"materialfox"}, names(catalog.Search("Material Fox")))
+	assert.Equal(t, float32(1), catalog.Search("material-fox")[0].Score)
+	assert.Equal(t, []string{"materialfox"}, names(catalog.Search("materal")))
+	assert.Equal(t, []string{"materialfox"}, names(catalog.Search("mucksponge")))
+	assert.Equal(t, []string{"simplefox"}, names(catalog.Search("dark minimalistic")))
+	assert.Equal(t, []string{"blurredfox"}, names(catalog.Search("manilarome")))
+	assert.Equal(t, []string{"blurredfox", "materialfox", "simplefox"}, names(catalog.Search("fox")))
+	assert.Empty(t, catalog.Search("dark lorem"))
+	assert.Empty(t, catalog.Search(" "))
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/docopt/docopt-go"
 	"github.com/ewen-lbh/ffcss"
-	"gopkg.in/yaml.v2"
 )
 
 func runCommandUse(args flagsAndArgs) error {
@@ -18,6 +23,11 @@ func runCommandUse(args flagsAndArgs) error {
 		return err
 	}
 
+	keepBackups, err := keepBackupsCount(args)
+	if err != nil {
+		return err
+	}
+
 	ffcss.LogStep(0, "Resolving the theme's name")
 	uri, typ, err := ffcss.ResolveURL(args.string("THEME_NAME"))
 	if err != nil {
@@ -40,6 +50,7 @@ func runCommandUse(args flagsAndArgs) error {
 	selectedProfiles, err := ffcss.SelectProfiles(
 		args.strings("--profiles"),
 		args.string("--profiles-dir"),
+		args.string("--browser"),
 		args.bool("--default-profile"),
 		args.bool("--all-profiles"),
 	)
@@ -50,6 +61,11 @@ func runCommandUse(args flagsAndArgs) error {
 	if len(selectedProfiles) == 0 {
 		return nil
 	}
+
+	err = ensureNotRunning(selectedProfiles, args)
+	if err != nil {
+		return err
+	}
 	singleProfile := len(selectedProfiles) == 1
 
 	incompatibleProfiles, err := manifest.IncompatibleProfiles(selectedProfiles)
@@ -65,24 +81,23 @@ func runCommandUse(args flagsAndArgs) error {
 	}
 
 	// Choose variant
-	variant := ffcss.Variant{}
-	if len(manifest.AvailableVariants()) > 0 {
-		variantName, _ := args.String("VARIANT")
-		if variantName == "" {
-			var cancel bool
-			variant, cancel = manifest.ChooseVariant()
-			if cancel {
-				return nil
-			}
-		} else {
-			var found bool
-			variant, found = manifest.Variants[variantName]
-			if !found {
-				return fmt.Errorf("variant %q does not exist on this theme. Available variants are %s", variantName, strings.Join(manifest.AvailableVariants(), ", "))
-			}
+	variant, cancel, err := chooseVariant(manifest, args)
+	if err != nil || cancel {
+		return err
+	}
+	if variant.Name != "" {
+		variantManifest, actionsNeeded := manifest.WithVariant(variant)
+		err = variantManifest.ReDownloadIfNeeded(actionsNeeded)
+		if err != nil {
+			return err
 		}
-		manifest, actionsNeeded := manifest.WithVariant(variant)
-		manifest.ReDownloadIfNeeded(actionsNeeded)
+		manifest = variantManifest
+	}
+
+	// Choose components
+	components, err := chooseComponents(manifest, args)
+	if err != nil {
+		return err
 	}
 
 	// Check for OS compatibility
@@ -92,100 +107,266 @@ func runCommandUse(args flagsAndArgs) error {
 	if singleProfile {
 		ffcss.BaseIndentLevel--
 	}
+	profileManifests := make([]ffcss.Theme, 0, len(selectedProfiles))
 	for _, profile := range selectedProfiles {
 		if !singleProfile {
 			ffcss.LogStep(0, "With profile "+filepath.Base(profile.Path))
 		}
 
-		ffcss.LogStep(1, "Backing up the current theme")
-		err = profile.BackupChrome()
+		profileManifest, err := withConditionsFor(manifest, profile, operatingSystem, variant, components)
 		if err != nil {
-			return fmt.Errorf("while backing up chrome directory: %w", err)
-		}
-		err = profile.BackupUserJS()
-		if err != nil {
-			return fmt.Errorf("while backing up user.js: %w", err)
+			return err
 		}
+		profileManifests = append(profileManifests, profileManifest)
 
-		// Run pre-install script
-		if manifest.Run.Before != "" {
-			ffcss.LogStep(1, "Running pre-install script")
-			// TODO for this to be useful, print commandline _with mustaches replaced_:  Step(baseIndent+2, "[dim]$ bash -c [reset][bold]%s", manifest.Run.Before)
-			output, err := manifest.RunPreInstallHook(profile)
-			if err != nil {
-				return fmt.Errorf("while running pre-install script: %w", err)
-			}
-			ffcss.ShowHookOutput(output)
+		currentTheme := ffcss.CurrentTheme{
+			Name:       args.string("THEME_NAME"),
+			Variant:    variant.Name,
+			Components: ffcss.ComponentNames(components),
 		}
-
-		err := os.Mkdir(filepath.Join(profile.Path, "chrome"), 0700)
+		err = installOnProfile(profileManifest, profile, operatingSystem, variant, components, currentTheme, keepBackups)
 		if err != nil {
 			return err
 		}
 
-		// Install stuff
-		ffcss.LogStep(1, "Installing the theme")
-		err = manifest.InstallUserChrome(operatingSystem, variant, profile.Path)
+		err = profile.RegisterCurrentTheme(currentTheme)
 		if err != nil {
-			return fmt.Errorf("couldn't install userChrome.css: %w", err)
+			return fmt.Errorf("while registering current theme for profile %q: %w", profile.FullName(), err)
 		}
 
-		err = manifest.InstallUserContent(operatingSystem, variant, profile.Path)
-		if err != nil {
-			return fmt.Errorf("couldn't install userContent.css: %w", err)
+	}
+	if singleProfile {
+		ffcss.BaseIndentLevel++
+	}
+
+	// Ask to open extensions' pages
+	addons := make([]string, 0)
+	for _, profileManifest := range profileManifests {
+		for _, addonURL := range profileManifest.Addons {
+			if !contains(addons, addonURL) {
+				addons = append(addons, addonURL)
+			}
 		}
+	}
+	if len(addons) > 0 {
+		if ffcss.ConfirmInstallAddons(addons) {
+			for i, profile := range selectedProfiles {
+				ffcss.LogStep(0, "With profile "+filepath.Base(profile.Path))
+				for _, addonURL := range profileManifests[i].Addons {
+					profile.InstallAddon(operatingSystem, addonURL)
+				}
+			}
+		}
+	}
 
-		err = manifest.InstallUserJS(operatingSystem, variant, profile.Path)
+	// Show message (once, even if conditions made it differ between profiles)
+	shownMessages := make(map[string]bool)
+	for _, profileManifest := range profileManifests {
+		if shownMessages[profileManifest.Message] {
+			continue
+		}
+		shownMessages[profileManifest.Message] = true
+		err = profileManifest.ShowMessage()
 		if err != nil {
-			return fmt.Errorf("couldn't install user.js: %w", err)
+			return fmt.Errorf("couldn't display the message: %w", err)
 		}
+	}
+	return nil
+}
+
+// chooseVariant returns the variant given as VARIANT, or asks which one to use if the theme has variants.
+// It returns an empty variant if the theme has none.
+func chooseVariant(manifest ffcss.Theme, args flagsAndArgs) (variant ffcss.Variant, cancel bool, err error) {
+	if len(manifest.AvailableVariants()) == 0 {
+		return ffcss.Variant{}, false, nil
+	}
+	variantName := args.string("VARIANT")
+	if variantName == "" {
+		return manifest.ChooseVariant()
+	}
+	variant, err = variantNamed(manifest, variantName)
+	return variant, false, err
+}
+
+// variantNamed returns the theme's variant named variantName.
+func variantNamed(manifest ffcss.Theme, variantName string) (ffcss.Variant, error) {
+	variant, found := manifest.Variants[variantName]
+	if !found {
+		return variant, fmt.Errorf("variant %q does not exist on this theme. Available variants are %s", variantName, strings.Join(manifest.AvailableVariants(), ", "))
+	}
+	return variant, nil
+}
 
-		err = manifest.InstallAssets(operatingSystem, variant, profile.Path)
+// withConditionsFor applies the theme's conditional blocks that match the profile, the variant and the components,
+// and downloads the theme again if they change where it is downloaded from.
+func withConditionsFor(manifest ffcss.Theme, profile ffcss.FirefoxProfile, operatingSystem string, variant ffcss.Variant, components []ffcss.Component) (ffcss.Theme, error) {
+	conditionContext := ffcss.ConditionContext{
+		OS:         operatingSystem,
+		OSVersion:  ffcss.OSVersion(),
+		Variant:    variant.Name,
+		Components: ffcss.ComponentNames(components),
+	}
+	if profileVersion, err := profile.FirefoxVersion(); err == nil {
+		conditionContext.FirefoxVersion = &profileVersion
+	}
+	conditionalManifest, actionsNeeded := manifest.WithConditions(conditionContext)
+	return conditionalManifest, conditionalManifest.ReDownloadIfNeeded(actionsNeeded)
+}
+
+// chooseComponents returns the components given with --components, or asks which ones to enable if the theme has some.
+func chooseComponents(manifest ffcss.Theme, args flagsAndArgs) ([]ffcss.Component, error) {
+	if len(manifest.AvailableComponents()) == 0 {
+		return []ffcss.Component{}, nil
+	}
+	if args.given("--components") {
+		return manifest.ComponentsByName(args.strings("--components"))
+	}
+	return manifest.ChooseComponents(), nil
+}
+
+// installOnProfile installs the theme on the profile.
+// The installation is staged next to the profile, and only swapped in once all files are installed:
+// if anything fails, including the hooks, the profile's previous chrome/ directory and user.js are restored.
+// Otherwise, they are backed up, and only the keepBackups most recent backups of the profile are kept.
+func installOnProfile(manifest ffcss.Theme, profile ffcss.FirefoxProfile, operatingSystem string, variant ffcss.Variant, components []ffcss.Component, currentTheme ffcss.CurrentTheme, keepBackups int) error {
+	// Run pre-install script
+	if manifest.Run.Before != "" {
+		ffcss.LogStep(1, "Running pre-install script")
+		// TODO for this to be useful, print commandline _with mustaches replaced_:  Step(baseIndent+2, "[dim]$ bash -c [reset][bold]%s", manifest.Run.Before)
+		output, err := manifest.RunPreInstallHook(profile)
 		if err != nil {
-			return fmt.Errorf("couldn't install assets: %w", err)
+			return fmt.Errorf("while running pre-install script: %w", err)
 		}
+		ffcss.ShowHookOutput(output)
+	}
 
-		// Run post-install script
-		if manifest.Run.After != "" {
-			ffcss.LogStep(1, "Running post-install script")
-			// TODO for this to be useful, print commandline _with mustaches replaced_:  Step(baseIndent+2, "[dim]$ bash -c [reset][bold]%s", manifest.Run.After)
-			output, err := manifest.RunPostInstallHook(profile)
-			if err != nil {
-				return fmt.Errorf("while running post-install script: %w", err)
-			}
-			ffcss.ShowHookOutput(output)
-		}
+	installation, err := profile.BeginInstallation()
+	if err != nil {
+		return err
+	}
 
-		err = profile.RegisterCurrentTheme(args.string("THEME_NAME"))
+	record, err := stageInstallation(installation, manifest, operatingSystem, variant, components, currentTheme)
+	if err != nil {
+		return rollBack(installation, err)
+	}
+
+	// Run post-install script
+	if manifest.Run.After != "" {
+		ffcss.LogStep(1, "Running post-install script")
+		// TODO for this to be useful, print commandline _with mustaches replaced_:  Step(baseIndent+2, "[dim]$ bash -c [reset][bold]%s", manifest.Run.After)
+		output, err := manifest.RunPostInstallHook(profile)
 		if err != nil {
-			return fmt.Errorf("while registering current theme for profile %q: %w", profile.FullName(), err)
+			return rollBack(installation, fmt.Errorf("while running post-install script: %w", err))
 		}
+		ffcss.ShowHookOutput(output)
+	}
 
+	ffcss.LogStep(1, "Backing up the previous theme")
+	backup, found, err := installation.Finish(record)
+	if err != nil {
+		return rollBack(installation, err)
 	}
-	if singleProfile {
-		ffcss.BaseIndentLevel++
+	if found {
+		ffcss.LogStep(2, "[dim]Backed up to %s", backup.ID)
 	}
 
-	// Ask to open extensions' pages
-	if len(manifest.Addons) > 0 {
-		if ffcss.ConfirmInstallAddons(manifest.Addons) {
-			for _, profile := range selectedProfiles {
-				ffcss.LogStep(0, "With profile "+filepath.Base(profile.Path))
-				for _, addonURL := range manifest.Addons {
-					profile.InstallAddon(operatingSystem, addonURL)
-				}
-			}
-		}
+	err = pruneBackups(profile, keepBackups)
+	if err != nil {
+		return err
 	}
+	ffcss.LogResult(ffcss.ResultInstalled, ffcss.NewInstallResult(profile, record, backup.ID))
+	return nil
+}
 
-	// Show message
-	err = manifest.ShowMessage()
+// pruneBackups removes the oldest backups of the profile, so that only keep backups remain, besides the ones in except.
+func pruneBackups(profile ffcss.FirefoxProfile, keep int, except ...string) error {
+	removed, err := profile.PruneBackups(keep, except...)
 	if err != nil {
-		return fmt.Errorf("couldn't display the message: %w", err)
+		return fmt.Errorf("while removing old backups: %w", err)
+	}
+	for _, backup := range removed {
+		ffcss.LogStep(2, "[dim]Removed old backup %s", backup.ID)
 	}
 	return nil
 }
 
+// stageInstallation installs the theme's files in the installation's staging directory and swaps them into the profile.
+// It returns the install record of the installed files.
+func stageInstallation(installation *ffcss.Installation, manifest ffcss.Theme, operatingSystem string, variant ffcss.Variant, components []ffcss.Component, currentTheme ffcss.CurrentTheme) (ffcss.InstallRecord, error) {
+	// Install stuff
+	ffcss.LogStep(1, "Installing the theme")
+	installed := make([]string, 0)
+	files, err := manifest.InstallUserChrome(operatingSystem, variant, installation.StagingDir)
+	if err != nil {
+		return ffcss.InstallRecord{}, fmt.Errorf("couldn't install userChrome.css: %w", err)
+	}
+	installed = append(installed, files...)
+
+	files, err = manifest.InstallUserContent(operatingSystem, variant, installation.StagingDir)
+	if err != nil {
+		return ffcss.InstallRecord{}, fmt.Errorf("couldn't install userContent.css: %w", err)
+	}
+	installed = append(installed, files...)
+
+	files, err = manifest.InstallUserJS(operatingSystem, variant, installation.StagingDir)
+	if err != nil {
+		return ffcss.InstallRecord{}, fmt.Errorf("couldn't install user.js: %w", err)
+	}
+	installed = append(installed, files...)
+
+	files, err = manifest.InstallAssets(operatingSystem, variant, installation.StagingDir)
+	if err != nil {
+		return ffcss.InstallRecord{}, fmt.Errorf("couldn't install assets: %w", err)
+	}
+	installed = append(installed, files...)
+
+	if len(components) > 0 {
+		ffcss.LogStep(1, "Applying components")
+		err = manifest.ApplyComponents(components, operatingSystem, variant, installation.StagingDir)
+		if err != nil {
+			return ffcss.InstallRecord{}, fmt.Errorf("couldn't apply components: %w", err)
+		}
+	}
+
+	err = manifest.ApplyModifications(manifest.Modifications, operatingSystem, variant, installation.StagingDir)
+	if err != nil {
+		return ffcss.InstallRecord{}, fmt.Errorf("couldn't apply modifications: %w", err)
+	}
+
+	files, err = installation.Profile.ApplyOverrides(installation.StagingDir)
+	if err != nil {
+		return ffcss.InstallRecord{}, fmt.Errorf("couldn't apply your overrides: %w", err)
+	}
+	if len(files) > 0 {
+		ffcss.LogStep(1, "Applied your overrides")
+	}
+	installed = append(installed, files...)
+
+	record, err := ffcss.NewInstallRecord(currentTheme, installation.StagingDir, installed)
+	if err != nil {
+		return ffcss.InstallRecord{}, fmt.Errorf("while recording installed files: %w", err)
+	}
+	record, err = installation.Profile.SnapshotPrefs(record)
+	if err != nil {
+		return ffcss.InstallRecord{}, fmt.Errorf("while recording configuration entries: %w", err)
+	}
+
+	err = installation.Commit()
+	if err != nil {
+		return record, fmt.Errorf("while swapping the new theme in: %w", err)
+	}
+	return record, nil
+}
+
+// rollBack rolls the installation back after err happened, and returns err.
+func rollBack(installation *ffcss.Installation, err error) error {
+	ffcss.LogStep(1, "[yellow]Restoring the previous theme")
+	if rollbackErr := installation.Rollback(); rollbackErr != nil {
+		return fmt.Errorf("%w (and restoring the previous theme failed: %s)", err, rollbackErr)
+	}
+	return err
+}
+
 func runCommandGet(args flagsAndArgs) error {
 	themeName, _ := args.String("THEME_NAME")
 	// variant, _ := args.String("VARIANT")
@@ -208,42 +389,208 @@ func runCommandGet(args flagsAndArgs) error {
 	}
 
 	ffcss.LogStepC("✓", 0, "Downloaded [blue][bold]%s[reset] [dim](to %s)", manifest.Name(), manifest.DownloadedTo)
+	ffcss.LogResult(ffcss.ResultDownloaded, ffcss.DownloadResult{Theme: manifest.Name(), DownloadedTo: manifest.DownloadedTo})
 	return nil
 }
 
-func runCommandReapply(args flagsAndArgs) error {
-	operatingSystem := ffcss.GOOStoOS(runtime.GOOS)
-	profilesDir, _ := args.String("--profiles-dir")
-	var profilesPaths []string
+func runCommandList(args flagsAndArgs) error {
+	catalog, err := ffcss.LoadCatalog(ffcss.ConfigDir("themes"))
+	if err != nil {
+		return fmt.Errorf("while loading catalog of themes: %w", err)
+	}
+	if len(catalog) == 0 {
+		ffcss.LogStep(0, "[yellow]The catalog has no themes")
+		return nil
+	}
+	for _, theme := range catalog.Themes() {
+		showCatalogTheme(ffcss.NewThemeResult(theme), theme.FirefoxVersionConstraint)
+	}
+	return nil
+}
+
+func runCommandSearch(args flagsAndArgs) error {
+	catalog, err := ffcss.LoadCatalog(ffcss.ConfigDir("themes"))
+	if err != nil {
+		return fmt.Errorf("while loading catalog of themes: %w", err)
+	}
+	matches := catalog.Search(args.string("QUERY"))
+	if len(matches) == 0 {
+		ffcss.LogStep(0, "[yellow]No themes match %q", args.string("QUERY"))
+		return nil
+	}
+	for _, match := range matches {
+		result := ffcss.NewThemeResult(match.Theme)
+		result.Score = match.Score
+		showCatalogTheme(result, match.Theme.FirefoxVersionConstraint)
+	}
+	return nil
+}
+
+func runCommandRegistrySync(args flagsAndArgs) error {
+	config, err := ffcss.LoadRegistryConfig()
+	if err != nil {
+		return err
+	}
+	ffcss.LogStep(0, "Syncing the registry from [blue]%s", config.URL)
+	// So that the manifests the registry's themes extend are downloaded again too
+	err = ffcss.ClearExtendedManifests()
+	if err != nil {
+		return err
+	}
+	sync, err := ffcss.SyncRegistry(config)
+	if err != nil {
+		return fmt.Errorf("while syncing the registry: %w", err)
+	}
+	if sync.Updated {
+		ffcss.LogStepC("✓", 0, "Synced [bold]%d[reset] themes", len(sync.Themes))
+	} else {
+		ffcss.LogStepC("✓", 0, "The registry is up to date [dim](%d themes)", len(sync.Themes))
+	}
+	ffcss.LogResult(ffcss.ResultRegistry, sync)
+	return nil
+}
+
+func runCommandLint(args flagsAndArgs) error {
+	path := args.string("PATH")
+	if path == "" {
+		path = "."
+	}
+	var problems []ffcss.LintProblem
 	var err error
-	if profilesDir != "" {
-		profilesPaths, err = ffcss.ProfilePaths(operatingSystem, profilesDir)
+	if args.bool("--catalog") {
+		problems, err = ffcss.LintCatalog(path)
 	} else {
-		profilesPaths, err = ffcss.ProfilePaths(operatingSystem)
+		if stat, statErr := os.Stat(path); statErr == nil && stat.IsDir() {
+			path = ffcss.ManifestPath(path)
+		}
+		problems, err = ffcss.LintTheme(path)
+	}
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		location := problem.File
+		if problem.Line != 0 {
+			location += ":" + strconv.Itoa(problem.Line)
+		}
+		ffcss.LogStepC("✗", 0, "[bold]%s[reset] %s", location, problem.Message)
+		ffcss.LogResult(ffcss.ResultProblem, problem)
+	}
+	if len(problems) == 1 {
+		return fmt.Errorf("found 1 problem")
+	}
+	if len(problems) > 1 {
+		return fmt.Errorf("found %d problems", len(problems))
+	}
+	ffcss.LogStepC("✓", 0, "No problems found")
+	return nil
+}
+
+func runCommandMigrate(args flagsAndArgs) error {
+	path := args.string("PATH")
+	if path == "" {
+		path = "."
 	}
+	if stat, err := os.Stat(path); err == nil && stat.IsDir() {
+		path = ffcss.ManifestPath(path)
+	}
+	changes, err := ffcss.MigrateManifestFile(path)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		ffcss.LogStepC("✓", 0, "[bold]%s[reset] is already up to date", path)
+		return nil
+	}
+	for _, change := range changes {
+		ffcss.LogStep(0, "[bold]%s:%d[reset] %s", path, change.Line, change.Message)
+	}
+	ffcss.LogStepC("✓", 0, "Migrated [bold]%s[reset] to the format of ffcss %d.X.X", path, ffcss.VersionMajor)
+	ffcss.LogResult(ffcss.ResultMigrated, ffcss.MigrationResult{File: path, Changes: changes})
+	return nil
+}
+
+func runCommandSchema(args flagsAndArgs) error {
+	if ffcss.JSONOutput {
+		ffcss.LogResult(ffcss.ResultSchema, json.RawMessage(ffcss.ManifestSchema))
+		return nil
+	}
+	_, err := out.Write(ffcss.ManifestSchema)
+	return err
+}
+
+// showCatalogTheme displays a theme of the catalog, with the first line of its description.
+func showCatalogTheme(theme ffcss.ThemeResult, constraint ffcss.FirefoxVersionConstraint) {
+	if theme.Author != "" {
+		ffcss.LogStep(0, "[blue][bold]%s[reset] [dim]by[reset] %s", theme.Name, theme.Author)
+	} else {
+		ffcss.LogStep(0, "[blue][bold]%s", theme.Name)
+	}
+	if theme.Description != "" {
+		ffcss.LogStep(1, "%s", strings.SplitN(theme.Description, "\n", 2)[0])
+	}
+	if len(theme.Tags) > 0 {
+		ffcss.LogStep(1, "[dim]Tags: %s", strings.Join(theme.Tags, ", "))
+	}
+	if constraint.Sentence != "" {
+		ffcss.LogStep(1, "[dim]Compatible with Firefox %s", constraint.Sentence)
+	}
+	if len(theme.Variants) > 0 {
+		ffcss.LogStep(1, "[dim]Variants: %s", strings.Join(theme.Variants, ", "))
+	}
+	ffcss.LogResult(ffcss.ResultTheme, theme)
+}
+
+func runCommandReapply(args flagsAndArgs) error {
+	profiles, err := ffcss.SelectableProfiles(args.string("--profiles-dir"), args.string("--browser"))
 	if err != nil {
 		return fmt.Errorf("while getting profiles: %w", err)
 	}
 
-	currentThemesFileContents, err := os.ReadFile(ffcss.ConfigDir("currently.yaml"))
+	currentThemes, err := ffcss.CurrentThemeDetailsByProfile()
 	if err != nil {
-		return fmt.Errorf("while reading current themes list: %w", err)
+		return err
 	}
 
-	currentThemes := make(map[string]string)
-	yaml.Unmarshal(currentThemesFileContents, &currentThemes)
+	return reapplyOn(profiles, currentThemes, args)
+}
 
-	for _, profilePath := range profilesPaths {
-		themeName, exists := currentThemes[filepath.Base(profilePath)]
+// reapplyOn re-installs the current theme of each profile, with the variant, components and prefs that were chosen.
+func reapplyOn(profiles []ffcss.FirefoxProfile, currentThemes map[string]ffcss.CurrentTheme, args flagsAndArgs) error {
+	for _, profile := range profiles {
+		currentTheme, exists := currentThemes[profile.FullName()]
 		if !exists {
-			ffcss.LogStep(0, "[yellow]Profile %s[reset][yellow] has no ffcss theme applied, skipping.", ffcss.NewFirefoxProfileFromPath(profilePath).Display())
+			ffcss.LogStep(0, "[yellow]Profile %s[reset][yellow] has no ffcss theme applied, skipping.", profile.Display())
+			continue
+		}
+		ffcss.LogStep(0, "Apply theme [blue][bold]%s[reset] to profile %s", currentTheme.Name, profile.Display())
+
+		useArgv := []string{"use", currentTheme.Name}
+		if currentTheme.Variant != "" {
+			useArgv = append(useArgv, currentTheme.Variant)
+		}
+		useArgv = append(useArgv, "--profiles", profile.Path, "--profiles-dir", args.string("--profiles-dir"), "--browser", args.string("--browser"), "--skip-manifest-source", "--components", strings.Join(currentTheme.Components, ","))
+		for _, flag := range []string{"--force", "--wait", "--locked", "--link"} {
+			if args.bool(flag) {
+				useArgv = append(useArgv, flag)
+			}
+		}
+		if args.given("--keep-backups") {
+			useArgv = append(useArgv, "--keep-backups", args.string("--keep-backups"))
+		}
+		useArgs, _ := docopt.ParseArgs(usage, useArgv, ffcss.VersionString)
+
+		// Prefs can only be set by ffcss apply, so the theme is installed like ffcss apply does, with the same flags ffcss use would get
+		if len(currentTheme.Prefs) > 0 {
+			err := reapplyWithPrefs(profile, currentTheme, flagsAndArgs{useArgs})
+			if err != nil {
+				return err
+			}
 			continue
 		}
-		ffcss.LogStep(0, "Apply theme [blue][bold]%s[reset] to profile %s", themeName, ffcss.NewFirefoxProfileFromPath(profilePath).Display())
 
-		useArgs, _ := docopt.ParseArgs(usage, []string{"use", string(themeName), "--profiles", profilePath, "--skip-manifest-source"}, ffcss.VersionString)
 		ffcss.BaseIndentLevel++
-		err = runCommandUse(flagsAndArgs{useArgs})
+		err := runCommandUse(flagsAndArgs{useArgs})
 		if err != nil {
 			return err
 		}
@@ -251,28 +598,451 @@ func runCommandReapply(args flagsAndArgs) error {
 	return nil
 }
 
+// reapplyWithPrefs re-installs the current theme of the profile with its prefs, see applyProfileConfig.
+// args are the arguments ffcss use would be called with.
+func reapplyWithPrefs(profile ffcss.FirefoxProfile, currentTheme ffcss.CurrentTheme, args flagsAndArgs) error {
+	keepBackups, err := keepBackupsCount(args)
+	if err != nil {
+		return err
+	}
+	err = ensureNotRunning([]ffcss.FirefoxProfile{profile}, args)
+	if err != nil {
+		return err
+	}
+	return applyProfileConfig(profile, ffcss.ProfileConfig{
+		Theme:      currentTheme.Name,
+		Variant:    currentTheme.Variant,
+		Components: currentTheme.Components,
+		Prefs:      currentTheme.Prefs,
+	}, keepBackups)
+}
+
+func runCommandUpdate(args flagsAndArgs) error {
+	themes, err := ffcss.CachedThemes()
+	if err != nil {
+		return err
+	}
+	if args.string("THEME") != "" {
+		requested := ffcss.CurrentTheme{Name: args.string("THEME")}
+		found := false
+		for _, theme := range themes {
+			if requested.UsesTheme(theme) {
+				themes, found = []string{theme}, true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("theme %q is not in the cache, use it first", args.string("THEME"))
+		}
+	}
+
+	// Manifests extended by URL are downloaded again when the themes' manifests are loaded
+	err = ffcss.ClearExtendedManifests()
+	if err != nil {
+		return err
+	}
+
+	updatedThemes := make([]string, 0)
+	failures := make([]string, 0)
+	for _, theme := range themes {
+		ffcss.LogStep(0, "Updating [blue][bold]%s", theme)
+		updates, err := ffcss.UpdateCachedTheme(theme)
+		for _, update := range updates {
+			ffcss.LogResult(ffcss.ResultUpdated, update)
+			cachedCopy := "variant " + filepath.Base(update.Directory)
+			if filepath.Base(update.Directory) == ffcss.RootVariantName {
+				cachedCopy = "default variant"
+			}
+			switch {
+			case update.Error != "":
+				ffcss.LogStep(1, "[red]Couldn't update the %s: %s", cachedCopy, update.Error)
+			case update.Skipped != "":
+				ffcss.LogStep(1, "[dim]Skipped the %s: %s", cachedCopy, update.Skipped)
+			case !update.Updated():
+				ffcss.LogStep(1, "[dim]The %s is up to date", cachedCopy)
+			default:
+				ffcss.LogStepC("✓", 1, "Updated the %s: [bold]%s[reset] [dim](%d new commits)", cachedCopy, update.Range(), len(update.Commits))
+				for _, commit := range update.Commits {
+					ffcss.LogStep(2, "[dim]%s", commit)
+				}
+				if !contains(updatedThemes, theme) {
+					updatedThemes = append(updatedThemes, theme)
+				}
+			}
+		}
+		// Update the other themes anyway
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", theme, err))
+		}
+	}
+	var updateErr error
+	if len(failures) > 0 {
+		updateErr = fmt.Errorf("couldn't update every cached theme: %s", strings.Join(failures, "; "))
+	}
+
+	if !args.bool("--reapply") || len(updatedThemes) == 0 {
+		return updateErr
+	}
+	profiles, err := ffcss.SelectableProfiles(args.string("--profiles-dir"), args.string("--browser"))
+	if err != nil {
+		return fmt.Errorf("while getting profiles: %w", err)
+	}
+	currentThemes, err := ffcss.CurrentThemeDetailsByProfile()
+	if err != nil {
+		return err
+	}
+	usingUpdatedThemes := make([]ffcss.FirefoxProfile, 0)
+	for _, profile := range profiles {
+		currentTheme, exists := currentThemes[profile.FullName()]
+		if !exists {
+			continue
+		}
+		for _, theme := range updatedThemes {
+			if currentTheme.UsesTheme(theme) {
+				usingUpdatedThemes = append(usingUpdatedThemes, profile)
+				break
+			}
+		}
+	}
+	err = reapplyOn(usingUpdatedThemes, currentThemes, args)
+	if err != nil {
+		return err
+	}
+	return updateErr
+}
+
+func runCommandApply(args flagsAndArgs) error {
+	keepBackups, err := keepBackupsCount(args)
+	if err != nil {
+		return err
+	}
+	err = ffcss.CreateDataDirectories()
+	if err != nil {
+		return err
+	}
+
+	configPath := args.string("CONFIG_FILE")
+	if configPath == "" {
+		configPath = ffcss.MachineConfigPath()
+	}
+	config, err := ffcss.LoadMachineConfig(configPath)
+	if err != nil {
+		return err
+	}
+	configured, err := config.ConfiguredProfiles(args.string("--profiles-dir"), args.string("--browser"))
+	if err != nil {
+		return err
+	}
+
+	changes := make([]ffcss.ProfileChange, 0, len(configured))
+	toChange := make([]ffcss.FirefoxProfile, 0)
+	for _, profile := range configured {
+		change, err := profile.PlanChange()
+		if err != nil {
+			return fmt.Errorf("while checking profile %s: %w", profile.Reference, err)
+		}
+		changes = append(changes, change)
+		if change.Action != ffcss.ProfileUpToDate {
+			toChange = append(toChange, change.Profile)
+		}
+	}
+	err = ensureNotRunning(toChange, args)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		ffcss.LogStep(0, "With profile %s", change.Profile.Display())
+		switch change.Action {
+		case ffcss.ProfileUpToDate:
+			if change.Config.Theme == "" {
+				ffcss.LogStep(1, "[dim]No theme to uninstall")
+			} else {
+				ffcss.LogStep(1, "[dim][bold]%s[reset][dim] is already applied", change.Config.Theme)
+			}
+			continue
+		case ffcss.ProfileUninstall:
+			removed, err := change.Profile.Uninstall()
+			if err != nil {
+				return fmt.Errorf("while uninstalling %s: %w", change.Current.Name, err)
+			}
+			ffcss.LogStepC("✓", 1, "Uninstalled [blue][bold]%s[reset] [dim](removed %d files)", change.Current.Name, len(removed))
+			ffcss.LogResult(ffcss.ResultUninstalled, uninstallResult(change.Profile, change.Current.Name, removed))
+			continue
+		}
+
+		err = applyProfileConfig(change.Profile, change.Config, keepBackups)
+		if err != nil {
+			return err
+		}
+		switch change.Action {
+		case ffcss.ProfileInstall:
+			ffcss.LogStepC("✓", 1, "Installed [blue][bold]%s", change.Config.Theme)
+		case ffcss.ProfileSwitch:
+			ffcss.LogStepC("✓", 1, "Switched from [blue][bold]%s[reset] to [blue][bold]%s", change.Current.Name, change.Config.Theme)
+		case ffcss.ProfileReinstall:
+			ffcss.LogStepC("✓", 1, "Re-installed [blue][bold]%s[reset] [dim](%s)", change.Config.Theme, change.Reason)
+		}
+	}
+
+	ffcss.LogStep(0, "[bold]%d[reset] profiles changed, [bold]%d[reset] already up to date", len(toChange), len(changes)-len(toChange))
+	return nil
+}
+
+// applyProfileConfig installs the theme on the profile with the configured variant, components and prefs, without asking anything.
+// Add-ons the theme recommends are only listed.
+func applyProfileConfig(profile ffcss.FirefoxProfile, config ffcss.ProfileConfig, keepBackups int) error {
+	uri, typ, err := ffcss.ResolveURL(config.Theme)
+	if err != nil {
+		return fmt.Errorf("while resolving name %s: %w", config.Theme, err)
+	}
+	manifest, err := ffcss.Download(uri, typ)
+	if err != nil {
+		return err
+	}
+	operatingSystem := ffcss.GOOStoOS(runtime.GOOS)
+
+	var variant ffcss.Variant
+	if config.Variant != "" {
+		variant, err = variantNamed(manifest, config.Variant)
+		if err != nil {
+			return err
+		}
+		variantManifest, actionsNeeded := manifest.WithVariant(variant)
+		err = variantManifest.ReDownloadIfNeeded(actionsNeeded)
+		if err != nil {
+			return err
+		}
+		manifest = variantManifest
+	}
+	components, err := manifest.ComponentsByName(config.Components)
+	if err != nil {
+		return err
+	}
+	manifest.WarnIfIncompatibleWithOS(operatingSystem)
+
+	manifest, err = withConditionsFor(manifest, profile, operatingSystem, variant, components)
+	if err != nil {
+		return err
+	}
+	if len(config.Prefs) > 0 {
+		prefs := make(ffcss.Config, len(manifest.Config)+len(config.Prefs))
+		for name, value := range manifest.Config {
+			prefs[name] = value
+		}
+		for name, value := range config.Prefs {
+			prefs[name] = value
+		}
+		manifest.Config = prefs
+	}
+
+	currentTheme := config.CurrentTheme()
+	err = installOnProfile(manifest, profile, operatingSystem, variant, components, currentTheme, keepBackups)
+	if err != nil {
+		return err
+	}
+	err = profile.RegisterCurrentTheme(currentTheme)
+	if err != nil {
+		return fmt.Errorf("while registering current theme for profile %q: %w", profile.FullName(), err)
+	}
+	for _, addonURL := range manifest.Addons {
+		ffcss.LogStep(1, "[dim]This theme recommends the add-on at %s", addonURL)
+	}
+	return nil
+}
+
 func runCommandReset(args flagsAndArgs) error {
-	profiles, err := ffcss.SelectProfiles(args.strings("--profiles"), args.string("--profiles-dir"), args.bool("--default-profile"), args.bool("--all-profiles"))
+	keepBackups, err := keepBackupsCount(args)
+	if err != nil {
+		return err
+	}
+	profiles, err := ffcss.SelectProfiles(args.strings("--profiles"), args.string("--profiles-dir"), args.string("--browser"), args.bool("--default-profile"), args.bool("--all-profiles"))
+	if err != nil {
+		return err
+	}
+	err = ensureNotRunning(profiles, args)
 	if err != nil {
 		return err
 	}
 	for _, profile := range profiles {
 		ffcss.LogStep(0, "With profile %s", profile.Display())
 		ffcss.LogStep(1, "Removing the current theme")
-		ffcss.LogStep(2, "Moving chrome/ to chrome.bak/")
-		err = profile.BackupChrome()
+		backup, found, err := profile.BackUp()
 		if err != nil {
-			return fmt.Errorf("couldn't back up the chrome folder: %w", err)
+			return fmt.Errorf("couldn't back up the current theme: %w", err)
 		}
-		ffcss.LogStep(2, "Moving user.js to user.js.bak")
-		err = profile.BackupUserJS()
+		if !found {
+			ffcss.LogStep(2, "[dim]Nothing to remove")
+			continue
+		}
+		ffcss.LogStep(2, "Moved chrome/ and user.js to backup %s", backup.ID)
+		err = pruneBackups(profile, keepBackups)
 		if err != nil {
-			return fmt.Errorf("couldn't back up user.js: %w", err)
+			return err
 		}
 	}
 	return nil
 }
 
+func runCommandBackupsList(args flagsAndArgs) error {
+	profiles, err := ffcss.SelectProfiles(args.strings("--profiles"), args.string("--profiles-dir"), args.string("--browser"), args.bool("--default-profile"), args.bool("--all-profiles"))
+	if err != nil {
+		return err
+	}
+	for _, profile := range profiles {
+		ffcss.LogStep(0, "With profile %s", profile.Display())
+		backups, err := profile.Backups()
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			ffcss.LogStep(1, "[dim]No backups")
+			continue
+		}
+		for i, backup := range backups {
+			ffcss.LogStepC(fmt.Sprint(i+1), 1, "[bold]%s[reset] %s [dim](%s)", backup.CreatedAt.Local().Format("2006-01-02 15:04:05"), backup.Description(), backup.ID)
+			ffcss.LogResult(ffcss.ResultBackup, backupResult(profile, backup))
+		}
+	}
+	return nil
+}
+
+func runCommandRestore(args flagsAndArgs) error {
+	keepBackups, err := keepBackupsCount(args)
+	if err != nil {
+		return err
+	}
+	profiles, err := ffcss.SelectProfiles(args.strings("--profiles"), args.string("--profiles-dir"), args.string("--browser"), args.bool("--default-profile"), args.bool("--all-profiles"))
+	if err != nil {
+		return err
+	}
+	err = ensureNotRunning(profiles, args)
+	if err != nil {
+		return err
+	}
+	for _, profile := range profiles {
+		ffcss.LogStep(0, "With profile %s", profile.Display())
+		backup, err := profile.FindBackup(args.string("BACKUP"))
+		if err != nil {
+			return err
+		}
+		ffcss.LogStep(1, "Restoring backup [bold]%s[reset] [dim](%s)", backup.ID, backup.Description())
+		err = profile.Restore(backup)
+		if err != nil {
+			return fmt.Errorf("while restoring backup %s: %w", backup.ID, err)
+		}
+		// The restored backup is kept even if it is the oldest one, so that it can be restored again
+		err = pruneBackups(profile, keepBackups, backup.ID)
+		if err != nil {
+			return err
+		}
+		ffcss.LogStepC("✓", 1, "Restored [bold]%s", backup.Description())
+		ffcss.LogResult(ffcss.ResultRestored, backupResult(profile, backup))
+	}
+	return nil
+}
+
+// backupResult describes the profile's backup.
+func backupResult(profile ffcss.FirefoxProfile, backup ffcss.Backup) ffcss.BackupResult {
+	return ffcss.BackupResult{
+		Profile:     profile.FullName(),
+		ID:          backup.ID,
+		CreatedAt:   backup.CreatedAt.Format(time.RFC3339),
+		Description: backup.Description(),
+	}
+}
+
+// ensureNotRunning checks that Firefox is not running with any of the profiles.
+// With --wait, it waits until Firefox is closed instead, for at most --wait-timeout, and with --force, it only warns.
+func ensureNotRunning(profiles []ffcss.FirefoxProfile, args flagsAndArgs) error {
+	timeout, err := waitTimeout(args)
+	if err != nil {
+		return err
+	}
+	for _, profile := range profiles {
+		err := profile.EnsureNotRunning()
+		var inUse ffcss.ProfileInUseError
+		if errors.As(err, &inUse) {
+			if args.bool("--force") {
+				ffcss.LogWarning("Firefox is running with profile %s (%s), modifying it anyway", profile, inUse.Lock)
+				continue
+			}
+			if args.bool("--wait") {
+				ffcss.LogStep(0, "[yellow]Waiting for Firefox to close profile %s", profile.Display())
+				err = profile.WaitUntilNotRunning(time.Second, timeout)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitTimeout returns how long to wait for Firefox to be closed with --wait, as set by --wait-timeout.
+func waitTimeout(args flagsAndArgs) (time.Duration, error) {
+	if !args.given("--wait-timeout") {
+		return ffcss.DefaultWaitTimeout, nil
+	}
+	seconds, err := strconv.Atoi(args.string("--wait-timeout"))
+	if err != nil || seconds < 1 {
+		return 0, fmt.Errorf("--wait-timeout must be a positive number of seconds, not %q", args.string("--wait-timeout"))
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// keepBackupsCount returns the number of backups to keep per profile, as set by --keep-backups.
+func keepBackupsCount(args flagsAndArgs) (int, error) {
+	if !args.given("--keep-backups") {
+		return ffcss.DefaultBackupsToKeep, nil
+	}
+	count, err := strconv.Atoi(args.string("--keep-backups"))
+	if err != nil || count < 1 {
+		return 0, fmt.Errorf("--keep-backups must be a positive number, not %q", args.string("--keep-backups"))
+	}
+	return count, nil
+}
+
+func runCommandUninstall(args flagsAndArgs) error {
+	profiles, err := ffcss.SelectProfiles(args.strings("--profiles"), args.string("--profiles-dir"), args.string("--browser"), args.bool("--default-profile"), args.bool("--all-profiles"))
+	if err != nil {
+		return err
+	}
+	err = ensureNotRunning(profiles, args)
+	if err != nil {
+		return err
+	}
+	for _, profile := range profiles {
+		ffcss.LogStep(0, "With profile %s", profile.Display())
+		record, found, err := profile.InstallRecord()
+		if err != nil {
+			return err
+		}
+		if !found {
+			ffcss.LogStep(1, "[yellow]No theme was installed by ffcss on this profile, skipping.")
+			continue
+		}
+		ffcss.LogStep(1, "Uninstalling [blue][bold]%s", record.Theme)
+		removed, err := profile.Uninstall()
+		if err != nil {
+			return fmt.Errorf("while uninstalling %s: %w", record.Theme, err)
+		}
+		ffcss.LogStepC("✓", 1, "Uninstalled [blue][bold]%s[reset] [dim](removed %d of %d installed files)", record.Theme, len(removed), len(record.Files))
+		ffcss.LogResult(ffcss.ResultUninstalled, uninstallResult(profile, record.Theme, removed))
+	}
+	return nil
+}
+
+// uninstallResult describes the removal of theme from the profile, removed being the paths of the removed files, relative to the profile.
+func uninstallResult(profile ffcss.FirefoxProfile, theme string, removed []string) ffcss.UninstallResult {
+	return ffcss.UninstallResult{
+		Profile:      profile.FullName(),
+		ProfilePath:  profile.Path,
+		Theme:        theme,
+		RemovedFiles: removed,
+	}
+}
+
 func runCommandInit(args flagsAndArgs) error {
 	// TODO: set user{Chrome,Content,.js} by finding their path
 	// TODO: only set assets if chrome/ actually exists
@@ -288,3 +1058,125 @@ func runCommandInit(args flagsAndArgs) error {
 
 	return theme.WriteManifest(workingDir)
 }
+
+func runCommandDev(args flagsAndArgs) error {
+	keepBackups, err := keepBackupsCount(args)
+	if err != nil {
+		return err
+	}
+
+	directory := args.string("DIRECTORY")
+	if directory == "" {
+		directory = "."
+	}
+	uri, typ, err := ffcss.ResolveURL(directory)
+	if err != nil {
+		return fmt.Errorf("while resolving %s: %w", directory, err)
+	}
+	if typ != "local" {
+		return fmt.Errorf("%s is not a theme's folder. Use ./%s if it is a relative path", directory, directory)
+	}
+	manifest, err := ffcss.LoadManifest(filepath.Join(uri, "ffcss.yaml"))
+	if err != nil {
+		return err
+	}
+	operatingSystem := ffcss.GOOStoOS(runtime.GOOS)
+
+	var profiles []ffcss.FirefoxProfile
+	if args.bool("--scratch-profile") {
+		profile, err := ffcss.NewScratchProfile(manifest.Name())
+		if err != nil {
+			return err
+		}
+		profiles = []ffcss.FirefoxProfile{profile}
+		defer func() {
+			ffcss.LogStep(0, "Removing the scratch profile")
+			if err := os.RemoveAll(profile.Path); err != nil {
+				ffcss.LogWarning("couldn't remove the scratch profile at %s: %s", profile.Path, err)
+			}
+		}()
+	} else {
+		profiles, err = ffcss.SelectProfiles(args.strings("--profiles"), args.string("--profiles-dir"), args.string("--browser"), args.bool("--default-profile"), args.bool("--all-profiles"))
+		if err != nil {
+			return err
+		}
+		if len(profiles) == 0 {
+			return nil
+		}
+	}
+
+	variant, cancel, err := chooseVariant(manifest, args)
+	if err != nil || cancel {
+		return err
+	}
+	components, err := chooseComponents(manifest, args)
+	if err != nil {
+		return err
+	}
+
+	// Install everything once
+	sessions := make([]*ffcss.DevSession, 0, len(profiles))
+	for _, profile := range profiles {
+		ffcss.LogStep(0, "With profile %s", profile.Display())
+		session, err := ffcss.NewDevSession(uri, profile, operatingSystem, variant.Name, ffcss.ComponentNames(components))
+		if err != nil {
+			return err
+		}
+		currentTheme := ffcss.CurrentTheme{
+			Name:       uri,
+			Variant:    variant.Name,
+			Components: ffcss.ComponentNames(components),
+		}
+		if args.bool("--scratch-profile") {
+			ffcss.LogStep(1, "Installing the theme")
+			var installed []string
+			installed, err = session.InstallAll()
+			if err == nil {
+				var record ffcss.InstallRecord
+				record, err = ffcss.NewInstallRecord(currentTheme, profile.Path, installed)
+				ffcss.LogResult(ffcss.ResultInstalled, ffcss.NewInstallResult(profile, record, ""))
+			}
+		} else {
+			// Installed like ffcss use does, so that the profile's own theme is backed up and the theme can be reapplied or uninstalled
+			err = installOnProfile(session.Theme, profile, operatingSystem, session.Variant, session.Components, currentTheme, keepBackups)
+			if err == nil {
+				err = profile.RegisterCurrentTheme(currentTheme)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		sessions = append(sessions, &session)
+	}
+
+	if args.bool("--scratch-profile") {
+		ffcss.LogStep(0, "Start Firefox with the scratch profile with [bold]%s", ffcss.ScratchProfileCommand(profiles[0]))
+	}
+	ffcss.LogStep(0, "Watching [blue][bold]%s[reset] for changes, press Ctrl-C to stop", uri)
+
+	stop := make(chan struct{})
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+	go func() {
+		<-interrupted
+		close(stop)
+	}()
+
+	return ffcss.WatchDirectory(uri, stop, func(changed []string) {
+		for _, session := range sessions {
+			updated, err := session.Update(changed)
+			for _, file := range updated {
+				relative, _ := filepath.Rel(session.Profile.Path, file)
+				ffcss.LogStepC("✓", 1, "%s [dim]Updated %s in %s", time.Now().Format("15:04:05"), filepath.ToSlash(relative), session.Profile.Display())
+			}
+			// Keep the install record in sync, so that the theme can still be uninstalled cleanly
+			if err == nil && len(updated) > 0 && !args.bool("--scratch-profile") {
+				err = session.Profile.UpdateInstallRecord(updated)
+			}
+			if err != nil {
+				ffcss.LogError("couldn't update %s: %s", session.Profile.Display(), err)
+			}
+		}
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"time"
 	_ "embed"
 
 	"github.com/docopt/docopt-go"
@@ -29,16 +31,33 @@ func main() {
 		panic(err)
 	}
 
+	ffcss.JSONOutput = flagsAndArgs{args}.bool("--json")
 	if err := dispatchCommand(flagsAndArgs{args}); err != nil {
+		if ffcss.JSONOutput {
+			ffcss.DisplayErrorMessage(err)
+			os.Exit(1)
+		}
 		fmt.Fprintln(out)
 		ffcss.LogError("Woops! An error occurred:")
 		fmt.Fprintln(out)
 		ffcss.DisplayErrorMessage(err)
+		os.Exit(1)
 	}
 }
 
 func dispatchCommand(args flagsAndArgs) error {
 	ffcss.LogDebug("dispatching %#v", args)
+	if args.given("--timeout") {
+		seconds, err := strconv.Atoi(args.string("--timeout"))
+		if err != nil || seconds < 1 {
+			return fmt.Errorf("--timeout must be a positive number of seconds, not %q", args.string("--timeout"))
+		}
+		ffcss.DownloadTimeout = time.Duration(seconds) * time.Second
+	}
+	ffcss.LinkLocalThemes = args.bool("--link")
+	ffcss.UseLockedRevisions = args.bool("--locked")
+	ffcss.AssumeYes = args.bool("--yes")
+	ffcss.NonInteractive = args.bool("--yes") || args.bool("--no-input") || args.bool("--json") || !ffcss.StdinIsTerminal()
 	if val, _ := args.Bool("configure"); val {
 		return fmt.Errorf("not implemented")
 	}
@@ -46,6 +65,35 @@ func dispatchCommand(args flagsAndArgs) error {
 		err := runCommandUse(args)
 		return err
 	}
+	if val, _ := args.Bool("dev"); val {
+		return runCommandDev(args)
+	}
+	if val, _ := args.Bool("apply"); val {
+		return runCommandApply(args)
+	}
+	if val, _ := args.Bool("update"); val {
+		return runCommandUpdate(args)
+	}
+	if val, _ := args.Bool("list"); val && !args.bool("backups") {
+		return runCommandList(args)
+	}
+	if val, _ := args.Bool("search"); val {
+		return runCommandSearch(args)
+	}
+	if val, _ := args.Bool("registry"); val {
+		if val, _ := args.Bool("sync"); val {
+			return runCommandRegistrySync(args)
+		}
+	}
+	if val, _ := args.Bool("lint"); val {
+		return runCommandLint(args)
+	}
+	if val, _ := args.Bool("migrate"); val {
+		return runCommandMigrate(args)
+	}
+	if val, _ := args.Bool("schema"); val {
+		return runCommandSchema(args)
+	}
 	if val, _ := args.Bool("get"); val {
 		err := runCommandGet(args)
 		return err
@@ -66,8 +114,32 @@ func dispatchCommand(args flagsAndArgs) error {
 	if val, _ := args.Bool("reset"); val {
 		return runCommandReset(args)
 	}
+	if val, _ := args.Bool("uninstall"); val {
+		return runCommandUninstall(args)
+	}
+	if val, _ := args.Bool("backups"); val {
+		if val, _ := args.Bool("list"); val {
+			return runCommandBackupsList(args)
+		}
+	}
+	if val, _ := args.Bool("restore"); val {
+		return runCommandRestore(args)
+	}
 	if val, _ := args.Bool("version"); val {
 		component, _ := args.String("COMPONENT")
+		if ffcss.JSONOutput {
+			version := ffcss.VersionString
+			switch component {
+			case "major":
+				version = fmt.Sprint(ffcss.VersionMajor)
+			case "minor":
+				version = fmt.Sprint(ffcss.VersionMinor)
+			case "patch":
+				version = fmt.Sprint(ffcss.VersionPatch)
+			}
+			ffcss.LogResult(ffcss.ResultVersion, version)
+			return nil
+		}
 		switch component {
 		case "major":
 			fmt.Fprintln(out, ffcss.VersionMajor)
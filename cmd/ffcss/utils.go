@@ -35,3 +35,19 @@ func (o flagsAndArgs) strings(name string) []string {
 	}
 	return strings.Split(val, ",")
 }
+
+// given returns true if the option was passed on the command line, even with an empty value.
+func (o flagsAndArgs) given(name string) bool {
+	val, found := o.Opts[name]
+	return found && val != nil
+}
+
+// contains returns true if needle is one of haystack's elements.
+func contains(haystack []string, needle string) bool {
+	for _, element := range haystack {
+		if element == needle {
+			return true
+		}
+	}
+	return false
+}
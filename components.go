@@ -0,0 +1,163 @@
+package ffcss
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Component represents an optional part of a theme, that users can choose to enable independently of other components.
+// Unlike variants, any number of components can be chosen at once.
+type Component struct {
+	// Properties exclusive to components
+
+	// Name is the component's name, which is its key in components
+	Name string
+
+	// Description is shown to users when they choose components
+	Description string
+	// Modifications are text edits done to the installed files when the component is enabled
+	Modifications []Modification
+	// IncompatibleWith are the names of components that can't be enabled along with this one
+	IncompatibleWith []string `yaml:"incompatible with"`
+}
+
+// Modification represents a text edit done to a file of the installed theme.
+// The file is designated by In, relative to the profile's chrome/ directory.
+//
+// Prepend and Append add text to the start or the end of the file,
+// while Replace replaces every occurrence of its text with With (which can be empty, to remove text).
+type Modification struct {
+	// In is the path of the edited file, relative to the profile's chrome/ folder
+	In FileTemplate
+	// Prepend is text added at the start of the file
+	Prepend string
+	// Append is text added at the end of the file
+	Append string
+	// Replace is text replaced with With everywhere in the file
+	Replace string
+	// With is what Replace is replaced with. Leave it empty to remove text
+	With string
+}
+
+// AvailableComponents lists the possible component names to choose from, sorted alphabetically.
+func (t Theme) AvailableComponents() []string {
+	names := make([]string, 0, len(t.Components))
+	for name := range t.Components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Component looks up a component by its name.
+// Names are compared the same way theme names are (see (Catalog).Lookup), so that "horizontal-tabs" finds "Horizontal tabs".
+func (t Theme) Component(name string) (Component, bool) {
+	name = strings.TrimPrefix(name, "component:")
+	for componentName, component := range t.Components {
+		if lookupPreprocess(componentName) == lookupPreprocess(name) {
+			return component, true
+		}
+	}
+	return Component{}, false
+}
+
+// ComponentsByName resolves the given component names into components.
+// It returns an error if a component does not exist, or if two of the given components are incompatible with each other.
+func (t Theme) ComponentsByName(names []string) ([]Component, error) {
+	components := make([]Component, 0, len(names))
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		component, found := t.Component(name)
+		if !found {
+			return []Component{}, fmt.Errorf("component %q does not exist on this theme. Available components are %s", name, strings.Join(t.AvailableComponents(), ", "))
+		}
+		components = append(components, component)
+	}
+	return components, CheckComponentsCompatibility(components)
+}
+
+// CheckComponentsCompatibility returns an error if any of the given components declares another one as incompatible.
+func CheckComponentsCompatibility(components []Component) error {
+	for _, component := range components {
+		for _, incompatible := range component.IncompatibleWith {
+			for _, other := range components {
+				if lookupPreprocess(other.Name) == lookupPreprocess(strings.TrimPrefix(incompatible, "component:")) {
+					return fmt.Errorf("component %q is incompatible with component %q", component.Name, other.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ComponentNames returns the names of the given components.
+func ComponentNames(components []Component) []string {
+	names := make([]string, 0, len(components))
+	for _, component := range components {
+		names = append(names, component.Name)
+	}
+	return names
+}
+
+// ApplyComponents applies the modifications of each component to the theme installed in profileDir.
+// Modifications are done on the installed copy, the cached theme is left untouched.
+func (t Theme) ApplyComponents(components []Component, operatingSystem string, variant Variant, profileDir string) error {
+	for _, component := range components {
+		LogDebug("applying component %q", component.Name)
+		err := t.ApplyModifications(component.Modifications, operatingSystem, variant, profileDir)
+		if err != nil {
+			return fmt.Errorf("while applying component %q: %w", component.Name, err)
+		}
+	}
+	return nil
+}
+
+// ApplyModifications applies each modification, in order, to the files of the theme installed in profileDir.
+func (t Theme) ApplyModifications(modifications []Modification, operatingSystem string, variant Variant, profileDir string) error {
+	for _, modification := range modifications {
+		chromeDir := filepath.Join(profileDir, "chrome")
+		file, err := SafeJoin(chromeDir, renderFileTemplate(modification.In, operatingSystem, variant, t.OSNames))
+		if err != nil {
+			return fmt.Errorf("%q is outside of the profile's chrome directory", modification.In)
+		}
+
+		contentBytes, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("while reading %s: %w", file, err)
+		}
+
+		content := modification.Apply(string(contentBytes))
+		if content == string(contentBytes) && modification.Replace != "" {
+			LogWarning("%q was not found in %s, it was left untouched", modification.Replace, modification.In)
+		}
+
+		err = ioutil.WriteFile(file, []byte(content), 0700)
+		if err != nil {
+			return fmt.Errorf("while writing %s: %w", file, err)
+		}
+		LogDebug("modified %s", file)
+	}
+	return nil
+}
+
+// Apply returns content with the modification applied.
+func (m Modification) Apply(content string) string {
+	if m.Replace != "" {
+		content = strings.ReplaceAll(content, m.Replace, m.With)
+	}
+	if m.Prepend != "" {
+		content = strings.TrimSuffix(m.Prepend, "\n") + "\n" + content
+	}
+	if m.Append != "" {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += m.Append
+	}
+	return content
+}
@@ -0,0 +1,70 @@
+package ffcss
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModificationApply(t *testing.T) {
+	content := "@import \"hide-tabline.css\";\n@import \"userChrome-hover.css\";\n"
+
+	assert.Equal(t,
+		"@import \"hide-tabline.css\";\n@import \"userChrome-static.css\";\n",
+		Modification{Replace: `@import "userChrome-hover.css";`, With: `@import "userChrome-static.css";`}.Apply(content),
+	)
+	assert.Equal(t,
+		"\n@import \"userChrome-hover.css\";\n",
+		Modification{Replace: `@import "hide-tabline.css";`}.Apply(content),
+	)
+	assert.Equal(t,
+		"@import \"icons.css\";\n"+content,
+		Modification{Prepend: `@import "icons.css";`}.Apply(content),
+	)
+	assert.Equal(t,
+		content+"#nav-bar { display: none }",
+		Modification{Append: "#nav-bar { display: none }"}.Apply(content),
+	)
+}
+
+func TestComponentsByName(t *testing.T) {
+	flyingfox, err := LoadManifest(filepath.Join(testarea, "catalogs", "various", "flyingfox.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Extension Icons", "Horizontal tabs", "Sidebar Hover", "Sidebar Static"}, flyingfox.AvailableComponents())
+
+	components, err := flyingfox.ComponentsByName([]string{"horizontal-tabs", "Extension Icons"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Horizontal tabs", "Extension Icons"}, ComponentNames(components))
+
+	components, err = flyingfox.ComponentsByName([]string{""})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, ComponentNames(components))
+
+	_, err = flyingfox.ComponentsByName([]string{"vertical tabs"})
+	assert.EqualError(t, err, `component "vertical tabs" does not exist on this theme. Available components are Extension Icons, Horizontal tabs, Sidebar Hover, Sidebar Static`)
+
+	_, err = flyingfox.ComponentsByName([]string{"sidebar-hover", "sidebar-static"})
+	assert.EqualError(t, err, `component "Sidebar Hover" is incompatible with component "Sidebar Static"`)
+}
+
+func TestApplyComponents(t *testing.T) {
+	profileDir := filepath.Join(testarea, "components-profile")
+	os.MkdirAll(filepath.Join(profileDir, "chrome"), 0700)
+	os.WriteFile(filepath.Join(profileDir, "chrome", "config.css"), []byte("@import \"userChrome-hover.css\";\n"), 0700)
+
+	theme := Theme{}
+	err := theme.ApplyComponents([]Component{
+		{Name: "static", Modifications: []Modification{{In: "config.css", Replace: `@import "userChrome-hover.css";`, With: `@import "userChrome-static.css";`}}},
+		{Name: "icons", Modifications: []Modification{{In: "config.css", Prepend: `@import "icons.css";`}}},
+	}, "linux", Variant{}, profileDir)
+	assert.NoError(t, err)
+	content, _ := os.ReadFile(filepath.Join(profileDir, "chrome", "config.css"))
+	assert.Equal(t, "@import \"icons.css\";\n@import \"userChrome-static.css\";\n", string(content))
+
+	err = theme.ApplyComponents([]Component{
+		{Name: "evil", Modifications: []Modification{{In: "../prefs.js", Append: "hehe"}}},
+	}, "linux", Variant{}, profileDir)
+	assert.EqualError(t, err, `while applying component "evil": "../prefs.js" is outside of the profile's chrome directory`)
+}
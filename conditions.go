@@ -0,0 +1,270 @@
+package ffcss
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var operatingSystemVersion = regexp.MustCompile(`^\d+(\.\d+)*$`)
+
+// isOperatingSystemName returns true if name is one of the operating systems conditions can refer to.
+func isOperatingSystemName(name string) bool {
+	return name == "linux" || name == "macos" || name == "windows"
+}
+
+// ConditionContext holds the values that conditions (keys of the "if" entry in the manifest) are evaluated against.
+type ConditionContext struct {
+	OS             string
+	OSVersion      string // empty when unknown, see OSVersion
+	FirefoxVersion *FirefoxVersion // nil when the profile's version is unknown
+	Variant        string
+	Components     []string
+}
+
+// Condition is a parsed boolean expression, such as "os:windows and not component:horizontal-tabs".
+//
+// Expressions are made of the following atoms, combined with "and", "or", "not" and parentheses:
+//
+//    Atom                 True when
+//    os:NAME              the operating system is NAME (linux, macos or windows)
+//    os:NAME:VERSION      the operating system is NAME, and its version is VERSION or starts with VERSION.
+//                         For example, windows:10 is true on Windows 10 but not on Windows 11
+//    linux, macos, ...    shorthand for os:linux, os:macos, etc., also with a version: windows:10
+//    firefox:CONSTRAINT   the profile's Firefox version fulfills CONSTRAINT (see NewFirefoxVersionConstraint)
+//    variant:NAME         the chosen variant is NAME
+//    component:NAME       the component NAME was chosen
+//
+// Values containing spaces can be surrounded by double quotes, e.g. firefox:"up to 90".
+type Condition struct {
+	operator string // "and", "or", "not" or "" for atoms
+	operands []Condition
+	key      string
+	value    string
+	version  string // of the operating system, for os atoms
+}
+
+// ParseCondition parses a condition expression. See Condition for the syntax.
+func ParseCondition(expression string) (Condition, error) {
+	tokens, err := tokenizeCondition(expression)
+	if err != nil {
+		return Condition{}, err
+	}
+	if len(tokens) == 0 {
+		return Condition{}, fmt.Errorf("empty condition")
+	}
+	parser := conditionParser{tokens: tokens}
+	condition, err := parser.parseOr()
+	if err != nil {
+		return Condition{}, err
+	}
+	if parser.position < len(parser.tokens) {
+		return Condition{}, fmt.Errorf("unexpected %q", parser.tokens[parser.position])
+	}
+	return condition, nil
+}
+
+// tokenizeCondition splits an expression into parentheses and words.
+// Double quotes group words together and are removed.
+func tokenizeCondition(expression string) ([]string, error) {
+	tokens := make([]string, 0)
+	var current strings.Builder
+	inQuotes := false
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, char := range expression {
+		switch {
+		case char == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+			current.WriteRune(char)
+		case char == '(' || char == ')':
+			flush()
+			tokens = append(tokens, string(char))
+		case char == ' ' || char == '\t' || char == '\n':
+			flush()
+		default:
+			current.WriteRune(char)
+		}
+	}
+	if inQuotes {
+		return tokens, fmt.Errorf("unterminated quote in %q", expression)
+	}
+	flush()
+	return tokens, nil
+}
+
+type conditionParser struct {
+	tokens   []string
+	position int
+}
+
+func (p *conditionParser) peek() string {
+	if p.position >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.position]
+}
+
+func (p *conditionParser) parseOr() (Condition, error) {
+	return p.parseBinary("or", p.parseAnd)
+}
+
+func (p *conditionParser) parseAnd() (Condition, error) {
+	return p.parseBinary("and", p.parseNot)
+}
+
+func (p *conditionParser) parseBinary(operator string, parseOperand func() (Condition, error)) (Condition, error) {
+	first, err := parseOperand()
+	if err != nil {
+		return Condition{}, err
+	}
+	operands := []Condition{first}
+	for strings.ToLower(p.peek()) == operator {
+		p.position++
+		operand, err := parseOperand()
+		if err != nil {
+			return Condition{}, err
+		}
+		operands = append(operands, operand)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return Condition{operator: operator, operands: operands}, nil
+}
+
+func (p *conditionParser) parseNot() (Condition, error) {
+	if strings.ToLower(p.peek()) == "not" {
+		p.position++
+		operand, err := p.parseNot()
+		if err != nil {
+			return Condition{}, err
+		}
+		return Condition{operator: "not", operands: []Condition{operand}}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *conditionParser) parsePrimary() (Condition, error) {
+	token := p.peek()
+	switch strings.ToLower(token) {
+	case "":
+		return Condition{}, fmt.Errorf("unexpected end of condition")
+	case "and", "or", ")":
+		return Condition{}, fmt.Errorf("unexpected %q", token)
+	case "(":
+		p.position++
+		condition, err := p.parseOr()
+		if err != nil {
+			return Condition{}, err
+		}
+		if p.peek() != ")" {
+			return Condition{}, fmt.Errorf("missing closing parenthesis")
+		}
+		p.position++
+		return condition, nil
+	}
+	p.position++
+	return parseConditionAtom(token)
+}
+
+func parseConditionAtom(token string) (Condition, error) {
+	if isOperatingSystemName(strings.SplitN(token, ":", 2)[0]) {
+		token = "os:" + token
+	}
+	parts := strings.SplitN(token, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return Condition{}, fmt.Errorf("%q is not a valid condition, expected KEY:VALUE or one of linux, macos and windows", token)
+	}
+	key, value := parts[0], parts[1]
+	switch key {
+	case "os":
+		nameAndVersion := strings.SplitN(value, ":", 2)
+		if !isOperatingSystemName(nameAndVersion[0]) {
+			return Condition{}, fmt.Errorf("%s is not a valid operating system. Operating systems are macos, windows and linux", nameAndVersion[0])
+		}
+		if len(nameAndVersion) == 1 {
+			break
+		}
+		if !operatingSystemVersion.MatchString(nameAndVersion[1]) {
+			return Condition{}, fmt.Errorf("%q is not a valid version of %s, expected numbers separated by dots, such as 10 or 13.4", nameAndVersion[1], nameAndVersion[0])
+		}
+		return Condition{key: key, value: nameAndVersion[0], version: nameAndVersion[1]}, nil
+	case "firefox":
+		if _, err := NewFirefoxVersionConstraint(value); err != nil {
+			return Condition{}, fmt.Errorf("invalid Firefox version constraint %q: %w", value, err)
+		}
+	case "variant", "component":
+	default:
+		return Condition{}, fmt.Errorf("unknown condition key %q in %q. Keys are os, firefox, variant and component", key, token)
+	}
+	return Condition{key: key, value: value}, nil
+}
+
+// Evaluate returns whether the condition holds in the given context.
+func (c Condition) Evaluate(context ConditionContext) bool {
+	switch c.operator {
+	case "not":
+		return !c.operands[0].Evaluate(context)
+	case "and":
+		for _, operand := range c.operands {
+			if !operand.Evaluate(context) {
+				return false
+			}
+		}
+		return true
+	case "or":
+		for _, operand := range c.operands {
+			if operand.Evaluate(context) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch c.key {
+	case "os":
+		if context.OS != c.value || c.version == "" {
+			return context.OS == c.value
+		}
+		if context.OSVersion == "" {
+			LogDebug("%s version unknown, %q is false", c.value, c.value+":"+c.version)
+			return false
+		}
+		return context.OSVersion == c.version || strings.HasPrefix(context.OSVersion, c.version+".")
+	case "firefox":
+		if context.FirefoxVersion == nil {
+			LogDebug("firefox version unknown, %q is false", "firefox:"+c.value)
+			return false
+		}
+		constraint, _ := NewFirefoxVersionConstraint(c.value)
+		return constraint.FulfilledBy(*context.FirefoxVersion)
+	case "variant":
+		return lookupPreprocess(context.Variant) == lookupPreprocess(c.value)
+	case "component":
+		for _, component := range context.Components {
+			if lookupPreprocess(component) == lookupPreprocess(c.value) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// references returns the values of all atoms of the condition with the given key.
+func (c Condition) references(key string) []string {
+	values := make([]string, 0)
+	if c.key == key {
+		values = append(values, c.value)
+	}
+	for _, operand := range c.operands {
+		values = append(values, operand.references(key)...)
+	}
+	return values
+}
@@ -0,0 +1,109 @@
+package ffcss
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCondition(t *testing.T) {
+	parsingFailsWith := func(expression string, errorPart string) {
+		_, err := ParseCondition(expression)
+		if assert.Error(t, err, "parsing %q", expression) {
+			assert.Contains(t, err.Error(), errorPart)
+		}
+	}
+
+	parsingFailsWith("", "empty condition")
+	parsingFailsWith("fedora:38", `unknown condition key "fedora"`)
+	parsingFailsWith("os:templeos", "templeos is not a valid operating system")
+	parsingFailsWith("os:templeos:5", "templeos is not a valid operating system")
+	parsingFailsWith("windows:ten", `"ten" is not a valid version of windows`)
+	parsingFailsWith("os:windows:", `"" is not a valid version of windows`)
+	parsingFailsWith("firefox:up to 90", `invalid Firefox version constraint "up"`)
+	parsingFailsWith(`firefox:"up to 90`, "unterminated quote")
+	parsingFailsWith("firefox:lol", "invalid Firefox version constraint")
+	parsingFailsWith("linux and", "unexpected end of condition")
+	parsingFailsWith("(linux or macos", "missing closing parenthesis")
+	parsingFailsWith("linux macos", `unexpected "macos"`)
+	parsingFailsWith("and linux", `unexpected "and"`)
+}
+
+func TestConditionEvaluate(t *testing.T) {
+	version := FirefoxVersion{90, 0}
+	context := ConditionContext{
+		OS:             "windows",
+		OSVersion:      "10.19045",
+		FirefoxVersion: &version,
+		Variant:        "new moon",
+		Components:     []string{"Horizontal tabs"},
+	}
+
+	evaluatesTo := func(expected bool, expression string, context ConditionContext) {
+		condition, err := ParseCondition(expression)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, condition.Evaluate(context), "evaluating %q", expression)
+	}
+
+	evaluatesTo(true, "windows", context)
+	evaluatesTo(true, "os:windows", context)
+	evaluatesTo(false, "os:linux", context)
+	evaluatesTo(true, "windows:10", context)
+	evaluatesTo(true, "os:windows:10.19045", context)
+	evaluatesTo(false, "windows:11", context)
+	evaluatesTo(false, "windows:1", context)
+	evaluatesTo(false, "macos:10", context)
+	evaluatesTo(false, "windows:10 and not component:horizontal-tabs", context)
+	evaluatesTo(true, "firefox:89+", context)
+	evaluatesTo(false, `firefox:"up to 88"`, context)
+	evaluatesTo(true, "variant:new-moon", context)
+	evaluatesTo(true, "component:horizontal-tabs", context)
+	evaluatesTo(false, "component:sidebar-static", context)
+	evaluatesTo(false, "os:windows and not component:horizontal-tabs", context)
+	evaluatesTo(true, "linux or macos or NOT component:sidebar-static", context)
+	evaluatesTo(true, "not (linux or macos) and firefox:90", context)
+	evaluatesTo(false, "not not linux", context)
+
+	context.FirefoxVersion = nil
+	evaluatesTo(false, "firefox:0+", context)
+	evaluatesTo(true, "not firefox:0+", context)
+
+	context.OSVersion = ""
+	evaluatesTo(true, "windows", context)
+	evaluatesTo(false, "windows:10", context)
+}
+
+func TestWithConditions(t *testing.T) {
+	flyingfox, err := LoadManifest(filepath.Join(testarea, "catalogs", "various", "flyingfox.yaml"))
+	assert.NoError(t, err)
+
+	windowsPatch := Modification{In: "config.css", Prepend: `@import "window-controls/wc-wt-windowspatch.css";`}
+
+	withConditions, actionsNeeded := flyingfox.WithConditions(ConditionContext{OS: "windows", OSVersion: "10.19045"})
+	assert.Equal(t, []Modification{windowsPatch}, withConditions.Modifications)
+	assert.False(t, actionsNeeded.reDownload || actionsNeeded.switchBranch)
+	assert.Equal(t, flyingfox.DownloadedTo, withConditions.DownloadedTo)
+
+	withConditions, _ = flyingfox.WithConditions(ConditionContext{OS: "windows", OSVersion: "10.19045", Components: []string{"Horizontal tabs"}})
+	assert.Equal(t, []Modification(nil), withConditions.Modifications)
+
+	withConditions, _ = flyingfox.WithConditions(ConditionContext{OS: "windows", OSVersion: "11.22631"})
+	assert.Equal(t, []Modification(nil), withConditions.Modifications)
+
+	withConditions, _ = flyingfox.WithConditions(ConditionContext{OS: "linux"})
+	assert.Equal(t, []Modification(nil), withConditions.Modifications)
+
+	theme := NewTheme()
+	theme.ExplicitName = "conditional"
+	theme.Conditions = map[string]Variant{
+		"firefox:100+": {DownloadAt: "https://example.com/for-recent-firefoxes", Config: Config{"some.pref": 1}},
+	}
+	version := FirefoxVersion{101, 0}
+	withConditions, actionsNeeded = theme.WithConditions(ConditionContext{OS: "linux", FirefoxVersion: &version})
+	assert.True(t, actionsNeeded.reDownload)
+	assert.Equal(t, "https://example.com/for-recent-firefoxes", withConditions.DownloadAt)
+	assert.Equal(t, Config{"some.pref": 1, "toolkit.legacyUserProfileCustomizations.stylesheets": true}, withConditions.Config)
+	assert.Equal(t, Config{"toolkit.legacyUserProfileCustomizations.stylesheets": true}, theme.Config)
+	assert.NotEqual(t, theme.DownloadedTo, withConditions.DownloadedTo)
+}
@@ -7,8 +7,52 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+// CurrentTheme describes the theme applied to a profile, as recorded in currently.yaml.
+// Entries that only have a name are stored as a plain string, the way they always were.
+type CurrentTheme struct {
+	Name       string
+	Variant    string   `yaml:",omitempty"`
+	Components []string `yaml:",omitempty"`
+	// Prefs are the configuration entries that were set in addition to the theme's, see ProfileConfig
+	Prefs Config `yaml:",omitempty"`
+}
+
+// UnmarshalYAML reads a CurrentTheme either from a plain theme name or from a mapping.
+func (current *CurrentTheme) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*current = CurrentTheme{Name: name}
+		return nil
+	}
+	type plain CurrentTheme
+	return unmarshal((*plain)(current))
+}
+
+// MarshalYAML writes a CurrentTheme as a plain theme name when it has no variant, components nor prefs.
+func (current CurrentTheme) MarshalYAML() (interface{}, error) {
+	if current.Variant == "" && len(current.Components) == 0 && len(current.Prefs) == 0 {
+		return current.Name, nil
+	}
+	type plain CurrentTheme
+	return plain(current), nil
+}
+
 // CurrentThemeByProfile returns a map mapping a profile path to its current theme's name.
 func CurrentThemeByProfile() (map[string]string, error) {
+	currentThemes, err := CurrentThemeDetailsByProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(currentThemes))
+	for profile, current := range currentThemes {
+		names[profile] = current.Name
+	}
+	return names, nil
+}
+
+// CurrentThemeDetailsByProfile is like CurrentThemeByProfile, but also returns the variant and components that were chosen.
+func CurrentThemeDetailsByProfile() (map[string]CurrentTheme, error) {
 	currentThemesRaw, err := os.ReadFile(ConfigDir("currently.yaml"))
 	if os.IsNotExist(err) {
 		err = os.WriteFile(ConfigDir("currently.yaml"), []byte(""), 0777)
@@ -19,19 +63,35 @@ func CurrentThemeByProfile() (map[string]string, error) {
 		return nil, fmt.Errorf("while reading current themes list: %w", err)
 	}
 
-	currentThemes := make(map[string]string)
+	currentThemes := make(map[string]CurrentTheme)
 	yaml.Unmarshal(currentThemesRaw, &currentThemes)
 	return currentThemes, nil
 }
 
 // RegisterCurrentTheme writes the currently.yaml file in ffcss' configuration to update
 // what ffcss considers to be the current theme for that profile.
-func (ffp FirefoxProfile) RegisterCurrentTheme(themeName string) error {
-	currentThemes, err := CurrentThemeByProfile()
+func (ffp FirefoxProfile) RegisterCurrentTheme(current CurrentTheme) error {
+	currentThemes, err := CurrentThemeDetailsByProfile()
+	if err != nil {
+		return err
+	}
+	currentThemes[ffp.FullName()] = current
+	return writeCurrentThemes(currentThemes)
+}
+
+// UnregisterCurrentTheme removes the profile from the currently.yaml file in ffcss' configuration,
+// so that ffcss considers that no theme is applied to it.
+func (ffp FirefoxProfile) UnregisterCurrentTheme() error {
+	currentThemes, err := CurrentThemeDetailsByProfile()
 	if err != nil {
 		return err
 	}
-	currentThemes[ffp.FullName()] = themeName
+	delete(currentThemes, ffp.FullName())
+	return writeCurrentThemes(currentThemes)
+}
+
+// writeCurrentThemes replaces the contents of currently.yaml with currentThemes.
+func writeCurrentThemes(currentThemes map[string]CurrentTheme) error {
 	currentThemesNewContents, err := yaml.Marshal(currentThemes)
 	if err != nil {
 		return fmt.Errorf("while marshaling into YAML: %w", err)
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
 )
 
 func TestCurrentThemeByProfile(t *testing.T) {
@@ -15,3 +16,17 @@ func TestCurrentThemeByProfile(t *testing.T) {
 		"stuff":   "yesees",
 	}, actual)
 }
+
+func TestCurrentThemeYAML(t *testing.T) {
+	currentThemes := map[string]CurrentTheme{}
+	err := yaml.Unmarshal([]byte("thingie: hmmmm\nstuff:\n  name: flyingfox\n  variant: dark\n  components: [Sidebar Static]\n"), &currentThemes)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]CurrentTheme{
+		"thingie": {Name: "hmmmm"},
+		"stuff":   {Name: "flyingfox", Variant: "dark", Components: []string{"Sidebar Static"}},
+	}, currentThemes)
+
+	marshaled, err := yaml.Marshal(currentThemes)
+	assert.NoError(t, err)
+	assert.Equal(t, "stuff:\n  name: flyingfox\n  variant: dark\n  components:\n  - Sidebar Static\nthingie: hmmmm\n", string(marshaled))
+}
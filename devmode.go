@@ -0,0 +1,366 @@
+package ffcss
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar"
+	"github.com/fsnotify/fsnotify"
+)
+
+// DevSession keeps a profile in sync with a theme that is being worked on, see ffcss dev.
+// The theme is used right from its directory: nothing is copied to the cache.
+type DevSession struct {
+	// Theme is the theme's manifest, with the variant and the conditional blocks applied
+	Theme           Theme
+	Directory       string
+	Profile         FirefoxProfile
+	OperatingSystem string
+	Variant         Variant
+	Components      []Component
+}
+
+// devWatchDebounce is how long to wait for other changes after a file changed, before updating the profile.
+// Editors often write a file in several steps (truncate, write, rename…).
+const devWatchDebounce = 100 * time.Millisecond
+
+// NewDevSession loads the theme in directory with the given variant and components, to be synced with profile.
+func NewDevSession(directory string, profile FirefoxProfile, operatingSystem string, variantName string, componentNames []string) (DevSession, error) {
+	session := DevSession{
+		Directory:       directory,
+		Profile:         profile,
+		OperatingSystem: operatingSystem,
+		Variant:         Variant{Name: variantName},
+	}
+	err := session.load(componentNames)
+	return session, err
+}
+
+// load (re)loads the theme's manifest from the session's directory.
+func (s *DevSession) load(componentNames []string) error {
+	manifest, err := LoadManifest(filepath.Join(s.Directory, "ffcss.yaml"))
+	if err != nil {
+		return err
+	}
+	manifest.DownloadedTo = s.Directory
+
+	if s.Variant.Name != "" {
+		variant, found := manifest.Variants[s.Variant.Name]
+		if !found {
+			return fmt.Errorf("variant %q does not exist on this theme. Available variants are %s", s.Variant.Name, strings.Join(manifest.AvailableVariants(), ", "))
+		}
+		var actionsNeeded struct{ switchBranch, reDownload bool }
+		manifest, actionsNeeded = manifest.WithVariant(variant)
+		if actionsNeeded.reDownload || actionsNeeded.switchBranch {
+			return fmt.Errorf("variant %q is downloaded from somewhere else, it can't be used from %s", variant.Name, s.Directory)
+		}
+		s.Variant = variant
+	}
+
+	s.Components, err = manifest.ComponentsByName(componentNames)
+	if err != nil {
+		return err
+	}
+
+	conditionContext := ConditionContext{
+		OS:         s.OperatingSystem,
+		OSVersion:  OSVersion(),
+		Variant:    s.Variant.Name,
+		Components: componentNames,
+	}
+	if profileVersion, err := s.Profile.FirefoxVersion(); err == nil {
+		conditionContext.FirefoxVersion = &profileVersion
+	}
+	withConditions, actionsNeeded := manifest.WithConditions(conditionContext)
+	if actionsNeeded.reDownload || actionsNeeded.switchBranch {
+		return fmt.Errorf("the theme's conditional blocks download it from somewhere else, it can't be used from %s", s.Directory)
+	}
+	s.Theme = withConditions
+	s.Theme.DownloadedTo = s.Directory
+	return nil
+}
+
+// InstallAll installs all of the theme's files in the profile, along with its components and modifications, and the user's overrides.
+// Unlike ffcss use, the files are written directly to the profile's chrome/ directory and user.js, without backing them up.
+// It returns the paths of the files it wrote.
+func (s DevSession) InstallAll() ([]string, error) {
+	installed := make([]string, 0)
+	err := os.MkdirAll(filepath.Join(s.Profile.Path, "chrome"), 0700)
+	if err != nil {
+		return installed, fmt.Errorf("while creating the profile's chrome directory: %w", err)
+	}
+	for _, install := range []func(string, Variant, string) ([]string, error){
+		s.Theme.InstallUserChrome,
+		s.Theme.InstallUserContent,
+		s.Theme.InstallUserJS,
+		s.Theme.InstallAssets,
+	} {
+		files, err := install(s.OperatingSystem, s.Variant, s.Profile.Path)
+		installed = append(installed, files...)
+		if err != nil {
+			return installed, err
+		}
+	}
+	err = s.Theme.ApplyModifications(s.modifications(), s.OperatingSystem, s.Variant, s.Profile.Path)
+	if err != nil {
+		return installed, err
+	}
+	files, err := s.Profile.ApplyOverrides(s.Profile.Path)
+	return append(installed, files...), err
+}
+
+// Update re-installs what depends on the changed files, which are paths inside the theme's directory.
+// When the manifest changes, it is reloaded and everything is re-installed.
+// Changed files the theme does not use are ignored.
+// It returns the paths of the files it wrote or removed in the profile.
+func (s *DevSession) Update(changed []string) ([]string, error) {
+	updated := make([]string, 0)
+	for _, file := range changed {
+		if filepath.Clean(file) == filepath.Join(s.Directory, "ffcss.yaml") {
+			LogDebug("manifest changed, reloading")
+			err := s.load(ComponentNames(s.Components))
+			if err != nil {
+				return updated, fmt.Errorf("while reloading the manifest: %w", err)
+			}
+			return s.InstallAll()
+		}
+	}
+
+	for _, install := range []struct {
+		template FileTemplate
+		install  func(string, Variant, string) ([]string, error)
+	}{
+		{s.Theme.UserChrome, s.Theme.InstallUserChrome},
+		{s.Theme.UserContent, s.Theme.InstallUserContent},
+		{s.Theme.UserJS, s.Theme.InstallUserJS},
+	} {
+		if install.template == "" {
+			continue
+		}
+		source, err := SafeJoin(s.Directory, renderFileTemplate(install.template, s.OperatingSystem, s.Variant, s.Theme.OSNames))
+		if err != nil {
+			return updated, err
+		}
+		if !contains(cleanPaths(changed), source) {
+			continue
+		}
+		files, err := install.install(s.OperatingSystem, s.Variant, s.Profile.Path)
+		updated = append(updated, files...)
+		if err != nil {
+			return updated, err
+		}
+	}
+
+	files, err := s.updateAssets(changed)
+	updated = append(updated, files...)
+	if err != nil {
+		return updated, err
+	}
+
+	// Re-apply the modifications made to the files that were just re-installed
+	chromeDir := filepath.Join(s.Profile.Path, "chrome")
+	modifications := make([]Modification, 0)
+	for _, modification := range s.modifications() {
+		target, err := SafeJoin(chromeDir, renderFileTemplate(modification.In, s.OperatingSystem, s.Variant, s.Theme.OSNames))
+		if err == nil && contains(updated, target) {
+			modifications = append(modifications, modification)
+		}
+	}
+	err = s.Theme.ApplyModifications(modifications, s.OperatingSystem, s.Variant, s.Profile.Path)
+	if err != nil {
+		return updated, err
+	}
+
+	// Re-apply the overrides of the files that were just re-installed
+	reinstalled := make([]string, 0)
+	for _, file := range overriddenFiles {
+		if contains(updated, filepath.Join(s.Profile.Path, file.installed)) {
+			reinstalled = append(reinstalled, file.override)
+		}
+	}
+	if len(reinstalled) == 0 {
+		return updated, nil
+	}
+	_, err = s.Profile.ApplyOverrides(s.Profile.Path, reinstalled...)
+	return updated, err
+}
+
+// updateAssets copies the changed files that are assets of the theme to the profile, and removes the ones that were deleted.
+func (s DevSession) updateAssets(changed []string) ([]string, error) {
+	updated := make([]string, 0)
+	if len(s.Theme.Assets) == 0 {
+		return updated, nil
+	}
+	assets, err := s.Theme.AssetsPaths(s.OperatingSystem, s.Variant)
+	if err != nil {
+		return updated, fmt.Errorf("while gathering assets: %w", err)
+	}
+	assets = cleanPaths(assets)
+
+	for _, file := range cleanPaths(changed) {
+		stat, err := os.Stat(file)
+		if os.IsNotExist(err) {
+			// The file can't match the assets' glob patterns anymore, so remove it if it would have been copied
+			destination, err := s.Theme.DestinationPathOfAsset(file, s.Profile.Path, s.OperatingSystem, s.Variant)
+			if err != nil || !s.wasAsset(file) {
+				continue
+			}
+			if os.Remove(destination) == nil {
+				updated = append(updated, destination)
+			}
+			continue
+		}
+		if err != nil {
+			return updated, fmt.Errorf("couldn't check file %s: %w", file, err)
+		}
+		if stat.IsDir() || !contains(assets, file) {
+			continue
+		}
+
+		destination, err := s.Theme.DestinationPathOfAsset(file, s.Profile.Path, s.OperatingSystem, s.Variant)
+		if err != nil {
+			return updated, err
+		}
+		err = os.MkdirAll(filepath.Dir(destination), 0700)
+		if err != nil {
+			return updated, fmt.Errorf("couldn't create parent directories for %s: %w", destination, err)
+		}
+		err = copyIfExists(file, destination)
+		if err != nil {
+			return updated, fmt.Errorf("while copying %s to %s: %w", file, destination, err)
+		}
+		updated = append(updated, destination)
+	}
+	return updated, nil
+}
+
+// wasAsset returns true if file, which does not exist anymore, matches one of the theme's assets patterns.
+func (s DevSession) wasAsset(file string) bool {
+	for _, template := range s.Theme.Assets {
+		pattern := filepath.Clean(filepath.Join(s.Directory, renderFileTemplate(template, s.OperatingSystem, s.Variant, s.Theme.OSNames)))
+		if matched, _ := doublestar.PathMatch(pattern, file); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// modifications returns the modifications of the chosen components followed by the theme's, in the order ffcss use applies them.
+func (s DevSession) modifications() []Modification {
+	modifications := make([]Modification, 0)
+	for _, component := range s.Components {
+		modifications = append(modifications, component.Modifications...)
+	}
+	return append(modifications, s.Theme.Modifications...)
+}
+
+// cleanPaths returns paths, cleaned (see filepath.Clean).
+func cleanPaths(paths []string) []string {
+	cleaned := make([]string, 0, len(paths))
+	for _, path := range paths {
+		cleaned = append(cleaned, filepath.Clean(path))
+	}
+	return cleaned
+}
+
+// WatchDirectory calls changed with the paths of the files that changed in directory and its subdirectories, until stop is closed.
+// Changes are grouped: changed is called once things calmed down for a moment.
+// Hidden directories, such as .git, are not watched.
+func WatchDirectory(directory string, stop <-chan struct{}, changed func(paths []string)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("while starting to watch files: %w", err)
+	}
+	defer watcher.Close()
+
+	err = watchRecursively(watcher, directory)
+	if err != nil {
+		return err
+	}
+
+	pending := make([]string, 0)
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-stop:
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			LogDebug("file event: %s", event)
+			if event.Op&fsnotify.Create != 0 {
+				if stat, err := os.Stat(event.Name); err == nil && stat.IsDir() {
+					err = watchRecursively(watcher, event.Name)
+					if err != nil {
+						LogWarning("%s", err)
+					}
+				}
+			}
+			if !contains(pending, event.Name) {
+				pending = append(pending, event.Name)
+			}
+			debounce = time.After(devWatchDebounce)
+		case <-debounce:
+			changed(pending)
+			pending = make([]string, 0)
+			debounce = nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			LogWarning("while watching files: %s", err)
+		}
+	}
+}
+
+// watchRecursively adds directory and all of its non-hidden subdirectories to the watcher.
+func watchRecursively(watcher *fsnotify.Watcher, directory string) error {
+	return filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != directory && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		err = watcher.Add(path)
+		if err != nil {
+			return fmt.Errorf("while watching %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// scratchProfilesDirName is the name of the directory of the cache that contains scratch profiles (see NewScratchProfile).
+// A theme cannot have that name.
+const scratchProfilesDirName = "scratch-profiles"
+
+// NewScratchProfile creates an empty profile directory in the cache, to try out a theme without touching your own profiles.
+// Firefox sets the profile up the first time it is started with it (see ScratchProfileCommand).
+func NewScratchProfile(themeName string) (FirefoxProfile, error) {
+	err := os.MkdirAll(CacheDir(scratchProfilesDirName), 0700)
+	if err != nil {
+		return FirefoxProfile{}, fmt.Errorf("while creating %s: %w", CacheDir(scratchProfilesDirName), err)
+	}
+	directory, err := os.MkdirTemp(CacheDir(scratchProfilesDirName), themeName+"-*")
+	if err != nil {
+		return FirefoxProfile{}, fmt.Errorf("while creating a scratch profile: %w", err)
+	}
+	return FirefoxProfile{
+		ID:   filepath.Base(directory),
+		Name: "ffcss-dev",
+		Path: directory,
+	}, nil
+}
+
+// ScratchProfileCommand returns the command line that starts Firefox with the given profile,
+// alongside the Firefox instance that might already be running.
+func ScratchProfileCommand(profile FirefoxProfile) string {
+	return fmt.Sprintf("firefox --no-remote --profile %q", profile.Path)
+}
@@ -0,0 +1,137 @@
+package ffcss
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDevSession(t *testing.T) {
+	directory := filepath.Join(testarea, "devtheme")
+	profile := FirefoxProfile{ID: "devprofile", Name: "dev", Path: filepath.Join(testarea, "devprofile")}
+	os.MkdirAll(filepath.Join(directory, "chrome", "icons"), 0700)
+	os.MkdirAll(profile.Path, 0700)
+	os.WriteFile(filepath.Join(directory, "ffcss.yaml"), []byte(`name: devtheme
+userChrome: chrome/userChrome.css
+assets:
+  - chrome/icons/*.svg
+copy from: chrome/
+modifications:
+  - in: userChrome.css
+    append: "/* appended */"
+`), 0600)
+	os.WriteFile(filepath.Join(directory, "chrome", "userChrome.css"), []byte("#nav-bar {}"), 0600)
+	os.WriteFile(filepath.Join(directory, "chrome", "icons", "back.svg"), []byte("<svg/>"), 0600)
+	os.WriteFile(filepath.Join(directory, "chrome", "icons", "forward.svg"), []byte("<svg/>"), 0600)
+
+	session, err := NewDevSession(directory, profile, "linux", "", []string{})
+	assert.NoError(t, err)
+	installed, err := session.InstallAll()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		filepath.Join(profile.Path, "chrome", "userChrome.css"),
+		filepath.Join(profile.Path, "chrome", "icons", "back.svg"),
+		filepath.Join(profile.Path, "chrome", "icons", "forward.svg"),
+		// With the configuration entry that enables userChrome.css
+		filepath.Join(profile.Path, "user.js"),
+	}, installed)
+
+	// Only the changed file is re-installed, and the modifications to it are applied again
+	os.WriteFile(filepath.Join(directory, "chrome", "userChrome.css"), []byte("#nav-bar { color: red; }"), 0600)
+	updated, err := session.Update([]string{filepath.Join(directory, "chrome", "userChrome.css"), filepath.Join(directory, "README.md")})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(profile.Path, "chrome", "userChrome.css")}, updated)
+	content, _ := os.ReadFile(filepath.Join(profile.Path, "chrome", "userChrome.css"))
+	assert.Equal(t, "#nav-bar { color: red; }\n/* appended */", string(content))
+
+	os.WriteFile(filepath.Join(directory, "chrome", "icons", "back.svg"), []byte("<svg></svg>"), 0600)
+	updated, err = session.Update([]string{filepath.Join(directory, "chrome", "icons", "back.svg")})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(profile.Path, "chrome", "icons", "back.svg")}, updated)
+	content, _ = os.ReadFile(filepath.Join(profile.Path, "chrome", "icons", "back.svg"))
+	assert.Equal(t, "<svg></svg>", string(content))
+
+	// Deleted assets are removed from the profile
+	os.Remove(filepath.Join(directory, "chrome", "icons", "forward.svg"))
+	updated, err = session.Update([]string{filepath.Join(directory, "chrome", "icons", "forward.svg")})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(profile.Path, "chrome", "icons", "forward.svg")}, updated)
+	assert.NoFileExists(t, filepath.Join(profile.Path, "chrome", "icons", "forward.svg"))
+
+	// Changing the manifest re-installs everything
+	os.WriteFile(filepath.Join(directory, "chrome", "userContent.css"), []byte("body {}"), 0600)
+	os.WriteFile(filepath.Join(directory, "ffcss.yaml"), []byte(`name: devtheme
+userChrome: chrome/userChrome.css
+userContent: chrome/userContent.css
+`), 0600)
+	updated, err = session.Update([]string{filepath.Join(directory, "ffcss.yaml")})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		filepath.Join(profile.Path, "chrome", "userChrome.css"),
+		filepath.Join(profile.Path, "chrome", "userContent.css"),
+		filepath.Join(profile.Path, "user.js"),
+	}, updated)
+}
+
+func TestWatchDirectory(t *testing.T) {
+	directory := t.TempDir()
+	os.MkdirAll(filepath.Join(directory, ".git"), 0700)
+
+	stop := make(chan struct{})
+	changes := make(chan []string, 10)
+	done := make(chan error)
+	go func() {
+		done <- WatchDirectory(directory, stop, func(paths []string) { changes <- paths })
+	}()
+	// The watcher takes some time to start: write to a file until it is noticed
+	ready := filepath.Join(directory, "ready")
+	started := false
+	for attempt := 0; attempt < 20 && !started; attempt++ {
+		os.WriteFile(ready, []byte{}, 0600)
+		select {
+		case <-changes:
+			started = true
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+	if !started {
+		t.Fatal("the watcher did not start")
+	}
+	// nextChange returns the next reported paths, without the ones written to before the watcher was noticed to start
+	nextChange := func(message string) []string {
+		for {
+			select {
+			case reported := <-changes:
+				changed := make([]string, 0, len(reported))
+				for _, path := range reported {
+					if path != ready {
+						changed = append(changed, path)
+					}
+				}
+				if len(changed) > 0 {
+					return changed
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal(message)
+				return nil
+			}
+		}
+	}
+
+	os.WriteFile(filepath.Join(directory, ".git", "index"), []byte("ignored"), 0600)
+	os.WriteFile(filepath.Join(directory, "userChrome.css"), []byte("a"), 0600)
+	os.WriteFile(filepath.Join(directory, "userChrome.css"), []byte("b"), 0600)
+	assert.Equal(t, []string{filepath.Join(directory, "userChrome.css")}, nextChange("no changes reported"))
+
+	// New directories are watched too, once their creation is reported
+	os.MkdirAll(filepath.Join(directory, "icons"), 0700)
+	assert.Equal(t, []string{filepath.Join(directory, "icons")}, nextChange("the new directory was not reported"))
+	os.WriteFile(filepath.Join(directory, "icons", "back.svg"), []byte("<svg/>"), 0600)
+	assert.Equal(t, []string{filepath.Join(directory, "icons", "back.svg")}, nextChange("no changes reported in new directory"))
+
+	close(stop)
+	assert.NoError(t, <-done)
+}
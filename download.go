@@ -1,15 +1,16 @@
 package ffcss
 
 import (
+	"crypto/sha1"
 	"errors"
 	"fmt"
-	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
-
-	"github.com/evilsocket/islazy/zip"
+	"strings"
 )
 
 // RootVariantName is the name of the default variant, when none were applied.
@@ -21,10 +22,18 @@ const RootVariantName = "_"
 // A theme cannot have that name.
 const TempDownloadsDirName = ".download"
 
+// LinkLocalThemes makes Download symlink local themes into the cache instead of copying them.
+var LinkLocalThemes = false
+
 // ResolveURL resolves the THEME_NAME given to ffcss use to either:
 // - a URL to download
 // - a git repo URL to clone
+// - the absolute path of a local directory that contains a theme
 func ResolveURL(themeName string) (URL string, typ string, err error) {
+	if directory, isLocal := localThemeDirectory(themeName); isLocal {
+		return directory, "local", nil
+	}
+
 	protocolLessURL := regexp.MustCompile(`^[\w_-]+\.[\w_-]+/.*$`)
 	userSlashRepo := regexp.MustCompile(`^[\w_-]+/[\w_-]+$`)
 	var completeURL string
@@ -48,28 +57,107 @@ func ResolveURL(themeName string) (URL string, typ string, err error) {
 		return themeName, "bare", nil
 	}
 
+	if typ := sourceTypeOfURL(completeURL); typ != "" {
+		return completeURL, typ, nil
+	}
 	if isURLClonable(completeURL) {
 		return completeURL, "git", nil
 	}
 	return completeURL, "website", nil
 }
 
+// localThemeDirectory returns the absolute path of the local theme themeName refers to, if it refers to one:
+// a file:// URL, a path that starts with ./, ../, / or ~/, or an existing directory that contains a manifest.
+// Paths to a manifest (ffcss.yaml) refer to the directory that contains it.
+func localThemeDirectory(themeName string) (string, bool) {
+	var directory string
+	if strings.HasPrefix(themeName, "file://") {
+		parsed, err := url.Parse(themeName)
+		if err != nil {
+			return "", false
+		}
+		directory = filepath.FromSlash(parsed.Path)
+		// file:///C:/Users/... on Windows
+		if filepath.VolumeName(strings.TrimPrefix(directory, string(filepath.Separator))) != "" {
+			directory = strings.TrimPrefix(directory, string(filepath.Separator))
+		}
+	} else if themeName == "~" || strings.HasPrefix(themeName, "~/") || strings.HasPrefix(themeName, "~"+string(filepath.Separator)) {
+		homedir, err := os.UserHomeDir()
+		if err != nil {
+			return "", false
+		}
+		directory = filepath.Join(homedir, themeName[1:])
+	} else if themeName == "." || themeName == ".." || filepath.IsAbs(themeName) || regexp.MustCompile(`^\.\.?[/\\]`).MatchString(themeName) {
+		directory = themeName
+	} else if _, err := os.Stat(filepath.Join(themeName, "ffcss.yaml")); err == nil {
+		directory = themeName
+	} else {
+		return "", false
+	}
+
+	if filepath.Base(directory) == "ffcss.yaml" {
+		directory = filepath.Dir(directory)
+	}
+	absolute, err := filepath.Abs(directory)
+	if err != nil {
+		return directory, true
+	}
+	return absolute, true
+}
+
+// sourceTypeOfURL returns the type of source the URL points to according to its extension:
+// archive for archives (see archiveExtensions), css for single CSS files, and the empty string otherwise.
+func sourceTypeOfURL(URL string) string {
+	if archiveFormatOfURL(URL) != "" {
+		return "archive"
+	}
+	if strings.HasSuffix(strings.ToLower(urlPath(URL)), ".css") {
+		return "css"
+	}
+	return ""
+}
+
+// urlPath returns the path of URL, or URL itself if it can't be parsed.
+func urlPath(URL string) string {
+	parsed, err := url.Parse(URL)
+	if err != nil {
+		return URL
+	}
+	return parsed.Path
+}
+
 // Download downloads the theme at URL.
-// If typ is website, then it downloads the zip file and extracts it.
+// If typ is archive or website, then it downloads the archive (zip, tar.gz, tar.xz or tar.zst) and extracts it.
+// If typ is css, then it downloads the CSS file and uses it as the theme's userChrome (see DownloadCSS).
 // If typ is git, then it clones the repository
+// If typ is local, then it copies (or symlinks, see LinkLocalThemes) the directory at URL, which must be an absolute path (see DownloadLocal).
 // If typ is bare, then it tries to find the URL in ~/.config/ffcss/themes/{{URL}}.yaml
 // In all cases, the theme is downloaded to ~/.cache/ffcss/{{themeName}}.
 // If themeName is not provided, the theme will first be downloaded to a temporary location to get the name from the manifest.
+// What was downloaded is recorded in the lock file (see RecordLockedRevision). With UseLockedRevisions,
+// git repositories are checked out at their locked commit and archives and CSS files are downloaded again and checked against their locked checksum.
 func Download(URL string, typ string, themeManifest ...Theme) (manifest Theme, err error) {
 	LogDebug("typ is %s", typ)
+	if UseLockedRevisions && typ == "local" {
+		return manifest, fmt.Errorf("%s is a local theme, it can't be used with --locked", URL)
+	}
 	if len(themeManifest) >= 1 {
 		manifest = themeManifest[0]
 		LogDebug("manifest is provided")
-		// Don't re-download if it already exists
+		// Don't re-download if it already exists, unless it's a local theme, which might have changed since,
+		// or the checksum of the downloaded file needs to be checked
 		LogDebug("checking if theme is in cache @ %s", manifest.DownloadedTo)
 		stat, err := os.Stat(manifest.DownloadedTo)
-		if err == nil && stat.IsDir() {
+		if err == nil && stat.IsDir() && typ != "local" && (typ == "git" || !UseLockedRevisions) {
 			LogDebug("skipped downloading of %s [%s#%s]", URL, manifest.Name(), manifest.currentVariantName)
+			if UseLockedRevisions {
+				err = checkoutLockedCommit(manifest, manifest.DownloadedTo)
+				if err != nil {
+					return manifest, err
+				}
+				return manifest, verifyLockedManifest(manifest)
+			}
+			recordLockedRevision(manifest, URL, typ)
 			return manifest, nil
 		}
 	}
@@ -82,10 +170,21 @@ func Download(URL string, typ string, themeManifest ...Theme) (manifest Theme, e
 		return manifest, fmt.Errorf("couldn't create a temporary directory at %s: %w", CacheDir(TempDownloadsDirName), err)
 	}
 	switch typ {
-	case "website":
-		manifest, err = DownloadFromZip(URL, tempDir, CacheDir(), themeManifest...)
+	case "website", "archive":
+		manifest, err = DownloadArchive(URL, tempDir, CacheDir(), themeManifest...)
+		if err != nil {
+			return manifest, fmt.Errorf("couldn't use the archive at %s: %w", URL, err)
+		}
+	case "css":
+		manifest, err = DownloadCSS(URL, tempDir, themeManifest...)
+		if err != nil {
+			return manifest, fmt.Errorf("couldn't use the CSS file at %s: %w", URL, err)
+		}
+	case "local":
+		os.RemoveAll(tempDir)
+		manifest, err = DownloadLocal(URL, LinkLocalThemes, themeManifest...)
 		if err != nil {
-			return manifest, fmt.Errorf("couldn't use the zip file at %s: %w", URL, err)
+			return manifest, fmt.Errorf("couldn't use the theme at %s: %w", URL, err)
 		}
 	case "git":
 		manifest, err = DownloadRepository(URL, tempDir, CacheDir(), themeManifest...)
@@ -102,7 +201,11 @@ func Download(URL string, typ string, themeManifest ...Theme) (manifest Theme, e
 			return theme, err
 		}
 
-		manifest, err = Download(theme.DownloadAt, "git", theme)
+		typ := sourceTypeOfURL(theme.DownloadAt)
+		if typ == "" {
+			typ = "git"
+		}
+		manifest, err = Download(theme.DownloadAt, typ, theme)
 		if err != nil {
 			return manifest, fmt.Errorf("from catalog: %w", err)
 		}
@@ -110,9 +213,26 @@ func Download(URL string, typ string, themeManifest ...Theme) (manifest Theme, e
 		panic("unexpected URL type")
 	}
 	manifest.DownloadedTo = CacheDir(manifest.Name(), manifest.currentVariantName)
+	// Themes from the catalog were recorded when they were downloaded from their actual URL, and local themes can't be locked
+	if typ == "bare" || typ == "local" {
+		return
+	}
+	if UseLockedRevisions {
+		return manifest, verifyLockedManifest(manifest)
+	}
+	recordLockedRevision(manifest, URL, typ)
 	return
 }
 
+// recordLockedRevision records the revision of the theme in the lock file (see RecordLockedRevision),
+// only warning the user if that fails: the theme can still be used.
+func recordLockedRevision(manifest Theme, URL string, typ string) {
+	err := RecordLockedRevision(manifest, URL, typ)
+	if err != nil {
+		LogWarning("couldn't record the downloaded revision of %s in the lock file: %s", manifest.Name(), err)
+	}
+}
+
 // DownloadRepository downloads the repository at URL to {{cloneTo}}/{{ffcss.yaml:name}}/{{current variant's name}}
 // It first clones the repo to tempCloneTo, then loads the manifest to determine where to move it.
 // the manifest can be provided in case the repository does not contain it.
@@ -180,6 +300,17 @@ func DownloadRepository(URL string, tempCloneTo string, cloneTo string, themeMan
 		return manifest, errors.New("manifest has no name")
 	}
 
+	if UseLockedRevisions {
+		err = checkoutLockedCommit(manifest, tempCloneTo)
+		if err != nil {
+			return manifest, err
+		}
+		err = os.RemoveAll(manifest.DownloadedTo)
+		if err != nil {
+			return manifest, fmt.Errorf("while removing previous copy at %s: %w", manifest.DownloadedTo, err)
+		}
+	}
+
 	err = os.MkdirAll(filepath.Dir(manifest.DownloadedTo), 0700)
 	if err != nil {
 		return manifest, fmt.Errorf("while creating final cache location: %w", err)
@@ -193,71 +324,270 @@ func DownloadRepository(URL string, tempCloneTo string, cloneTo string, themeMan
 	return
 }
 
-// DownloadFromZip downloads a ffcss manifest files along with its resources from the given URL.
-// The URL must point to a zip file that contains a ffcss.yaml in its root.
-// The zip file will be downloaded and extracted to {{tempDownloadTo}}, then, after loading the manifest,
-// the folder will then be moved to {{finalDownloadTo}}/{{ffcss.yaml:name}}.
-// the manifest can be provided in case the zip does not contain it.
-func DownloadFromZip(URL string, tempDownloadTo string, finalDownloadTo string, themeManifest ...Theme) (manifest Theme, err error) {
-	tempDownloadTo = tempDownloadTo + "/theme.zip"
+// DownloadLocal puts the theme in directory in the cache, at the manifest's DownloadedTo,
+// replacing what was previously there, so that changes that are not even committed are used.
+// If link is true, a symbolic link to the directory is created instead of a copy.
+// The .git directory is not copied.
+// the manifest can be provided in case the directory does not contain it.
+func DownloadLocal(directory string, link bool, themeManifest ...Theme) (manifest Theme, err error) {
+	if stat, err := os.Stat(directory); err != nil || !stat.IsDir() {
+		return manifest, fmt.Errorf("%s is not a directory", directory)
+	}
+
+	if len(themeManifest) >= 1 {
+		manifest = themeManifest[0]
+	} else {
+		if _, err := os.Stat(filepath.Join(directory, "ffcss.yaml")); os.IsNotExist(err) {
+			return manifest, fmt.Errorf("no manifest found: %w", err)
+		}
+		manifest, err = LoadManifest(filepath.Join(directory, "ffcss.yaml"))
+		if err != nil {
+			return manifest, fmt.Errorf("could not load manifest: %w", err)
+		}
+	}
+	if manifest.Name() == "" {
+		return manifest, errors.New("manifest has no name")
+	}
+
+	// Removing the previous copy would remove the directory too
+	if overlaps, same := overlapsCachedCopy(directory, manifest.DownloadedTo); overlaps {
+		if !same {
+			return manifest, fmt.Errorf("%s is inside of the theme's folder in the cache, %s", directory, manifest.DownloadedTo)
+		}
+		LogDebug("%s is already the theme's folder in the cache, not copying it", directory)
+		return manifest, nil
+	}
+
+	err = os.RemoveAll(manifest.DownloadedTo)
+	if err != nil {
+		return manifest, fmt.Errorf("while removing previous copy at %s: %w", manifest.DownloadedTo, err)
+	}
+	err = os.MkdirAll(filepath.Dir(manifest.DownloadedTo), 0700)
+	if err != nil {
+		return manifest, fmt.Errorf("while creating final cache location: %w", err)
+	}
+
+	if link {
+		LogDebug("linking %s to %s", manifest.DownloadedTo, directory)
+		err = os.Symlink(directory, manifest.DownloadedTo)
+		if err != nil {
+			return manifest, fmt.Errorf("while linking %s to %s: %w", manifest.DownloadedTo, directory, err)
+		}
+		return
+	}
+
+	LogDebug("copying %s to %s", directory, manifest.DownloadedTo)
+	err = copyIfExists(directory, manifest.DownloadedTo, ".git")
+	if err != nil {
+		return manifest, fmt.Errorf("while copying %s to %s: %w", directory, manifest.DownloadedTo, err)
+	}
+	return
+}
+
+// overlapsCachedCopy returns whether directory is the same as the theme's folder in the cache, cached, or is inside of it,
+// once symbolic links are resolved. A cached folder that is a symbolic link never overlaps, since removing it leaves its target untouched.
+func overlapsCachedCopy(directory string, cached string) (overlaps bool, same bool) {
+	if stat, err := os.Lstat(cached); err != nil || stat.Mode()&os.ModeSymlink != 0 {
+		return false, false
+	}
+	resolvedDirectory, err := filepath.Abs(directory)
+	if err == nil {
+		resolvedDirectory, err = filepath.EvalSymlinks(resolvedDirectory)
+	}
+	if err != nil {
+		return false, false
+	}
+	resolvedCached, err := filepath.EvalSymlinks(cached)
+	if err != nil {
+		return false, false
+	}
+	resolvedCached, err = filepath.Abs(resolvedCached)
+	if err != nil {
+		return false, false
+	}
+	return isWithin(resolvedCached, resolvedDirectory), resolvedCached == resolvedDirectory
+}
+
+// DownloadArchive downloads a ffcss manifest files along with its resources from the given URL.
+// The URL must point to an archive (zip, tar.gz, tar.xz or tar.zst) that contains a ffcss.yaml.
+// The archive will be downloaded and extracted to {{tempDownloadTo}}, then, after loading the manifest,
+// the directory containing it will be moved to the manifest's DownloadedTo, inside {{finalDownloadTo}}.
+// If the archive has no manifest, its only top-level directory is used instead, if it has one (as in archives of git repositories).
+// the manifest can be provided in case the archive does not contain it.
+func DownloadArchive(URL string, tempDownloadTo string, finalDownloadTo string, themeManifest ...Theme) (manifest Theme, err error) {
 	hasManifest := len(themeManifest) >= 1
 	if hasManifest {
 		manifest = themeManifest[0]
 	}
 
-	// Check if file exists, has the right Content-Type, etc.
-	head, err := http.Head(URL)
+	err = os.MkdirAll(finalDownloadTo, 0777)
+	if err != nil {
+		return manifest, fmt.Errorf("could not create directory to download to: %w", err)
+	}
+
+	// Download it. Interrupted downloads are kept in a location that only depends on the URL, so that they can be resumed.
+	partialDownloadsDir := CacheDir(TempDownloadsDirName, "archives")
+	err = os.MkdirAll(partialDownloadsDir, 0700)
 	if err != nil {
-		return manifest, fmt.Errorf("couldn't check remote file: %w", err)
+		return manifest, fmt.Errorf("while creating %s: %w", partialDownloadsDir, err)
 	}
-	if head.StatusCode >= 400 {
-		return manifest, fmt.Errorf("couldn't check remote file: server returned %s", head.Status)
+	downloadTo := filepath.Join(partialDownloadsDir, fmt.Sprintf("%x", sha1.Sum([]byte(URL))))
+	err = DownloadFile(URL, downloadTo, showDownloadProgress)
+	if err != nil {
+		return manifest, fmt.Errorf("couldn't download archive: %w", err)
 	}
-	if head.Header.Get("Content-Type") != "application/zip" {
-		return manifest, fmt.Errorf("expected a zip file (application/zip), got a %s", head.Header.Get("Content-Type"))
+
+	// Servers don't always send the right Content-Type and URLs don't always have an extension, so check the file's contents instead
+	format, err := detectArchiveFormat(downloadTo)
+	if err != nil {
+		os.Remove(downloadTo)
+		return manifest, err
 	}
 
-	// Download it
-	process := exec.Command("wget", URL, "-O", tempDownloadTo)
-	LogDebug("Running %s", process.String())
-	output, err := process.CombinedOutput()
+	checksum, err := fileChecksum(downloadTo)
 	if err != nil {
-		return manifest, fmt.Errorf("couldn't download zip file: %w: %s", err, output)
+		return manifest, err
 	}
 
-	// Unzip it, check contents
-	LogDebug("Unzipping %s to %s", tempDownloadTo, filepath.Dir(tempDownloadTo))
-	unzipped, err := zip.Unzip(tempDownloadTo, filepath.Dir(tempDownloadTo))
+	archive := filepath.Join(tempDownloadTo, "theme."+format)
+	err = os.Rename(downloadTo, archive)
 	if err != nil {
-		return manifest, fmt.Errorf("while unzipping %s: %w", tempDownloadTo, err)
+		return manifest, fmt.Errorf("while moving %s to %s: %w", downloadTo, archive, err)
 	}
 
+	// Extract it, check contents
+	extractTo := filepath.Join(tempDownloadTo, "extracted")
+	LogDebug("Extracting %s to %s", archive, extractTo)
+	extracted, err := extractArchive(archive, format, extractTo)
+	if err != nil {
+		os.RemoveAll(tempDownloadTo)
+		return manifest, fmt.Errorf("while extracting %s: %w", archive, err)
+	}
+	root := archiveRoot(extractTo, extracted)
+
 	if !hasManifest {
-		for _, file := range unzipped {
-			if filepath.Base(file) == "ffcss.yaml" {
-				hasManifest = true
-				manifest, err = LoadManifest(file)
-				if err != nil {
-					return manifest, fmt.Errorf("couldn't load the manifest file: %w", err)
-				}
-				break
-			}
+		if _, err := os.Stat(filepath.Join(root, "ffcss.yaml")); os.IsNotExist(err) {
+			os.RemoveAll(tempDownloadTo)
+			return manifest, errors.New("downloaded archive has no manifest file (ffcss.yaml)")
 		}
-		if !hasManifest {
-			os.RemoveAll(filepath.Dir(tempDownloadTo))
-			return manifest, errors.New("downloaded zip file has no manifest file (ffcss.yaml)")
+		manifest, err = LoadManifest(filepath.Join(root, "ffcss.yaml"))
+		if err != nil {
+			return manifest, fmt.Errorf("couldn't load the manifest file: %w", err)
 		}
 	}
 	if manifest.Name() == "" {
 		return manifest, errors.New("manifest has no name")
 	}
-	err = os.Rename(filepath.Dir(tempDownloadTo), filepath.Join(finalDownloadTo, manifest.Name()))
+	manifest.downloadedChecksum = checksum
+
+	err = replaceWithLockedDownload(manifest)
+	if err != nil {
+		os.RemoveAll(tempDownloadTo)
+		return manifest, err
+	}
+	err = os.MkdirAll(filepath.Dir(manifest.DownloadedTo), 0700)
+	if err != nil {
+		return manifest, fmt.Errorf("while creating final cache location: %w", err)
+	}
+	err = os.Rename(root, manifest.DownloadedTo)
 	if err != nil {
-		return manifest, fmt.Errorf("could not move %s to %s: %w", filepath.Dir(tempDownloadTo), filepath.Join(finalDownloadTo, manifest.Name()), err)
+		return manifest, fmt.Errorf("could not move %s to %s: %w", root, manifest.DownloadedTo, err)
 	}
+	os.RemoveAll(tempDownloadTo)
 	return
 }
 
+// genericCSSFileNames are names of CSS files that don't say anything about the theme they belong to.
+var genericCSSFileNames = []string{"userchrome", "usercontent", "theme", "style", "styles", "main", "index"}
+
+// DownloadCSS downloads the single CSS file at URL to {{tempDownloadTo}}, then moves it to the manifest's DownloadedTo.
+// If no manifest is provided, one is synthesized: it is named after the URL (see themeNameFromCSSURL)
+// and uses the file as its userChrome, or as its userContent if the file is named userContent.css.
+// The synthesized manifest is written next to the file.
+func DownloadCSS(URL string, tempDownloadTo string, themeManifest ...Theme) (manifest Theme, err error) {
+	fileName := path.Base(urlPath(URL))
+	if !strings.HasSuffix(strings.ToLower(fileName), ".css") {
+		fileName = "userChrome.css"
+	}
+
+	err = DownloadFile(URL, filepath.Join(tempDownloadTo, fileName), showDownloadProgress)
+	if err != nil {
+		return manifest, fmt.Errorf("couldn't download CSS file: %w", err)
+	}
+	checksum, err := fileChecksum(filepath.Join(tempDownloadTo, fileName))
+	if err != nil {
+		return manifest, err
+	}
+
+	if len(themeManifest) >= 1 {
+		manifest = themeManifest[0]
+	} else {
+		manifest = NewTheme()
+		manifest.FfcssVersion = VersionMajor
+		manifest.ExplicitName = themeNameFromCSSURL(URL)
+		manifest.DownloadAt = URL
+		if strings.EqualFold(fileName, "userContent.css") {
+			manifest.UserContent = fileName
+		} else {
+			manifest.UserChrome = fileName
+		}
+		manifest.currentVariantName = RootVariantName
+		manifest.DownloadedTo = CacheDir(manifest.Name(), manifest.currentVariantName)
+		err = manifest.WriteManifest(tempDownloadTo)
+		if err != nil {
+			return manifest, err
+		}
+	}
+	if manifest.Name() == "" {
+		return manifest, errors.New("manifest has no name")
+	}
+	manifest.downloadedChecksum = checksum
+
+	err = replaceWithLockedDownload(manifest)
+	if err != nil {
+		return manifest, err
+	}
+	err = os.MkdirAll(filepath.Dir(manifest.DownloadedTo), 0700)
+	if err != nil {
+		return manifest, fmt.Errorf("while creating final cache location: %w", err)
+	}
+	err = os.Rename(tempDownloadTo, manifest.DownloadedTo)
+	if err != nil {
+		return manifest, fmt.Errorf("while moving from temporary downloads %q to final cache location %q: %w", tempDownloadTo, manifest.DownloadedTo, err)
+	}
+	return
+}
+
+// themeNameFromCSSURL guesses the name of a theme from the URL of its CSS file:
+// the file's name, unless it is a generic one such as userChrome.css.
+// In that case, the repository's name is used for raw.githubusercontent.com URLs,
+// the author's name followed by the gist's ID for gist.githubusercontent.com URLs,
+// and the website's domain name otherwise.
+func themeNameFromCSSURL(URL string) string {
+	parsed, err := url.Parse(URL)
+	if err != nil {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	stem := strings.TrimSuffix(strings.ToLower(segments[len(segments)-1]), ".css")
+	var name string
+	switch {
+	case stem != "" && !contains(genericCSSFileNames, stem):
+		name = stem
+	case parsed.Hostname() == "raw.githubusercontent.com" && len(segments) >= 2:
+		name = segments[1]
+	case parsed.Hostname() == "gist.githubusercontent.com" && len(segments) >= 2:
+		gistID := segments[1]
+		if len(gistID) > 7 {
+			gistID = gistID[:7]
+		}
+		name = segments[0] + "-gist-" + gistID
+	default:
+		name = strings.TrimPrefix(parsed.Hostname(), "www.")
+	}
+	return regexp.MustCompile(`[^\w.-]+`).ReplaceAllString(strings.ToLower(name), "-")
+}
+
 // isURLClonable determines if the given URL points to a git repository
 func isURLClonable(URL string) bool {
 	output, err := exec.Command("git", "ls-remote", URL).CombinedOutput()
@@ -270,7 +600,7 @@ func isURLClonable(URL string) bool {
 			return false
 		}
 	}
-	LogWarning("could not determine clonability of %s: while running git-ls-remote: %w: %s\n", URL, err, output)
+	LogWarning("could not determine clonability of %s: while running git-ls-remote: %s: %s\n", URL, err, output)
 	return false
 }
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -43,7 +44,7 @@ func TestDownload(t *testing.T) {
 	assert.Contains(t, err.Error(), "server returned 404 File not found")
 
 	_, err = Download("http://localhost:8080/htmlfile.html", "website")
-	assert.Contains(t, err.Error(), "expected a zip file (application/zip), got")
+	assert.Contains(t, err.Error(), "expected an archive (zip, tar.gz, tar.xz or tar.zst), got text/html")
 
 	_, err = Download("materialfox", "bare")
 	assert.NoError(t, err)
@@ -52,20 +53,88 @@ func TestDownload(t *testing.T) {
 	assert.Contains(t, err.Error(), `theme "unknownone" not found`)
 }
 
-func TestDownloadFromZip(t *testing.T) {
+func TestDownloadArchive(t *testing.T) {
 	i := 0
 	dl := func(s string) error {
 		os.MkdirAll(filepath.Join(cwd(), fmt.Sprintf("testarea/zip-dropoff/%d", i)), 0777)
-		_, err := DownloadFromZip(s, filepath.Join(cwd(), fmt.Sprintf("testarea/zip-dropoff/%d", i)), filepath.Join(cwd(), "testarea/cache"))
+		_, err := DownloadArchive(s, filepath.Join(cwd(), fmt.Sprintf("testarea/zip-dropoff/%d", i)), filepath.Join(cwd(), "testarea/cache"))
 		i++
 		return err
 	}
 
-	assert.Contains(t, dl("http://localhost:8080/notfound").Error(), "couldn't check remote file: server returned 404 File not found")
-	assert.Contains(t, dl("http://localhost:8080/htmlfile.html").Error(), "expected a zip file (application/zip), got a text/html")
+	assert.Contains(t, dl("http://localhost:8080/notfound").Error(), "couldn't download archive: server returned 404 File not found")
+	assert.Contains(t, dl("http://localhost:8080/htmlfile.html").Error(), "expected an archive (zip, tar.gz, tar.xz or tar.zst), got text/html")
 	// TODO: check for absence of unzipped folder
-	assert.Contains(t, dl("http://localhost:8080/../themeWithNoManifest.zip").Error(), "downloaded zip file has no manifest file (ffcss.yaml)")
+	assert.Contains(t, dl("http://localhost:8080/../themeWithNoManifest.zip").Error(), "downloaded archive has no manifest file (ffcss.yaml)")
 	// TODO: check for presence of unzipped folder
 	assert.Nil(t, dl("http://localhost:8080/../materialfox.zip"))
 	// TODO: check for absence of zip file, in both cases
 }
+
+func TestLocalThemeDirectory(t *testing.T) {
+	directory, isLocal := localThemeDirectory("./testdata/nomanifest")
+	assert.True(t, isLocal)
+	assert.Equal(t, filepath.Join(cwd(), "testdata", "nomanifest"), directory)
+
+	directory, isLocal = localThemeDirectory("file://" + filepath.ToSlash(filepath.Join(cwd(), "testdata", "manifests", "ffcss.yaml")))
+	assert.True(t, isLocal)
+	assert.Equal(t, filepath.Join(cwd(), "testdata", "manifests"), directory)
+
+	directory, isLocal = localThemeDirectory("~/themes/mine")
+	assert.True(t, isLocal)
+	expected, _ := filepath.Abs(filepath.Join(mockedHomedir, "themes", "mine"))
+	assert.Equal(t, expected, directory)
+
+	_, isLocal = localThemeDirectory("materialfox")
+	assert.False(t, isLocal)
+
+	_, isLocal = localThemeDirectory("ewen-lbh/ffcss")
+	assert.False(t, isLocal)
+}
+
+func TestDownloadLocal(t *testing.T) {
+	directory := filepath.Join(testarea, "localtheme")
+	os.MkdirAll(filepath.Join(directory, ".git"), 0700)
+	os.MkdirAll(filepath.Join(directory, "chrome"), 0700)
+	os.WriteFile(filepath.Join(directory, "ffcss.yaml"), []byte("name: localtheme\nuserChrome: chrome/userChrome.css\n"), 0600)
+	os.WriteFile(filepath.Join(directory, "chrome", "userChrome.css"), []byte("#nav-bar {}"), 0600)
+	os.WriteFile(filepath.Join(directory, ".git", "HEAD"), []byte("ref: refs/heads/main"), 0600)
+
+	uri, typ, err := ResolveURL(directory)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{directory, "local"}, []string{uri, typ})
+
+	manifest, err := Download(uri, typ)
+	assert.NoError(t, err)
+	assert.Equal(t, CacheDir("localtheme", RootVariantName), manifest.DownloadedTo)
+	assert.FileExists(t, filepath.Join(manifest.DownloadedTo, "chrome", "userChrome.css"))
+	assert.NoDirExists(t, filepath.Join(manifest.DownloadedTo, ".git"))
+
+	// Uncommitted changes are used, even when the theme is already in the cache
+	os.WriteFile(filepath.Join(directory, "chrome", "userChrome.css"), []byte("#nav-bar { display: none; }"), 0600)
+	manifest, err = Download(uri, typ, manifest)
+	assert.NoError(t, err)
+	content, _ := os.ReadFile(filepath.Join(manifest.DownloadedTo, "chrome", "userChrome.css"))
+	assert.Equal(t, "#nav-bar { display: none; }", string(content))
+
+	_, err = DownloadLocal(filepath.Join(testarea, "nothere"), false)
+	assert.EqualError(t, err, filepath.Join(testarea, "nothere")+" is not a directory")
+
+	// The theme's folder in the cache is not removed when it is the directory itself, or when it contains it
+	cached := manifest.DownloadedTo
+	manifest, err = DownloadLocal(cached, false)
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(cached, "chrome", "userChrome.css"))
+	_, err = DownloadLocal(filepath.Join(cached, "chrome"), false, manifest)
+	assert.EqualError(t, err, filepath.Join(cached, "chrome")+" is inside of the theme's folder in the cache, "+cached)
+	assert.FileExists(t, filepath.Join(cached, "chrome", "userChrome.css"))
+
+	// Creating symbolic links requires special privileges on Windows
+	if runtime.GOOS != "windows" {
+		manifest, err = DownloadLocal(directory, true)
+		assert.NoError(t, err)
+		target, err := os.Readlink(manifest.DownloadedTo)
+		assert.NoError(t, err)
+		assert.Equal(t, directory, target)
+	}
+}
@@ -0,0 +1,167 @@
+package ffcss
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/colorstring"
+)
+
+// JSONOutput makes ffcss print JSON lines instead of colorized text: each line is an Event.
+// It is set by --json.
+var JSONOutput = false
+
+// Types of events
+const (
+	EventStep    = "step"
+	EventWarning = "warning"
+	EventError   = "error"
+	EventResult  = "result"
+	EventDebug   = "debug"
+)
+
+// Kinds of results
+const (
+	ResultInstalled   = "installed"
+	ResultUninstalled = "uninstalled"
+	ResultDownloaded  = "downloaded"
+	ResultUpdated     = "updated"
+	ResultBackup      = "backup"
+	ResultRestored    = "restored"
+	ResultVersion     = "version"
+	ResultTheme       = "theme"
+	ResultRegistry    = "registry"
+	ResultProblem     = "problem"
+	ResultSchema      = "schema"
+	ResultMigrated    = "migrated"
+)
+
+// Event is a line of output in JSON mode, see JSONOutput.
+type Event struct {
+	// Type is one of EventStep, EventWarning, EventError or EventResult
+	Type string `json:"type"`
+	// Level is the indentation level of steps
+	Level uint `json:"level,omitempty"`
+	// Message is the text that would be displayed, without colors
+	Message string `json:"message,omitempty"`
+	// Kind is what results describe, e.g. ResultInstalled
+	Kind string `json:"kind,omitempty"`
+	// Result is one of the *Result types, a ThemeUpdate for ResultUpdated, a RegistrySync for ResultRegistry, a LintProblem for ResultProblem, the JSON Schema of manifests for ResultSchema, or the version string for ResultVersion
+	Result interface{} `json:"result,omitempty"`
+}
+
+// InstallResult describes the installation of a theme on a profile.
+type InstallResult struct {
+	Profile     string   `json:"profile"`
+	ProfilePath string   `json:"profilePath"`
+	Theme       string   `json:"theme"`
+	Variant     string   `json:"variant,omitempty"`
+	Components  []string `json:"components,omitempty"`
+	// FirefoxVersion is the version of Firefox the profile was last used with, if it could be detected
+	FirefoxVersion string `json:"firefoxVersion,omitempty"`
+	// InstalledFiles are the paths of installed files, relative to the profile directory
+	InstalledFiles []string `json:"installedFiles"`
+	// Backup is the ID of the backup of the profile's previous theme, if there was one
+	Backup string `json:"backup,omitempty"`
+}
+
+// NewInstallResult describes the installation recorded by record on profile.
+func NewInstallResult(profile FirefoxProfile, record InstallRecord, backup string) InstallResult {
+	result := InstallResult{
+		Profile:        profile.FullName(),
+		ProfilePath:    profile.Path,
+		Theme:          record.Theme,
+		Variant:        record.Variant,
+		Components:     record.Components,
+		InstalledFiles: record.InstalledFiles(),
+		Backup:         backup,
+	}
+	if version, err := profile.FirefoxVersion(); err == nil {
+		result.FirefoxVersion = version.String()
+	}
+	return result
+}
+
+// UninstallResult describes the removal of a theme from a profile.
+type UninstallResult struct {
+	Profile     string `json:"profile"`
+	ProfilePath string `json:"profilePath"`
+	Theme       string `json:"theme"`
+	// RemovedFiles are the paths of removed files, relative to the profile directory
+	RemovedFiles []string `json:"removedFiles"`
+}
+
+// DownloadResult describes a theme downloaded to the cache.
+type DownloadResult struct {
+	Theme        string `json:"theme"`
+	DownloadedTo string `json:"downloadedTo"`
+}
+
+// MigrationResult describes a manifest upgraded to the current format.
+type MigrationResult struct {
+	File    string           `json:"file"`
+	Changes []ManifestChange `json:"changes"`
+}
+
+// ThemeResult describes a theme of the catalog.
+type ThemeResult struct {
+	Name        string   `json:"name"`
+	Author      string   `json:"author,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	// Firefox is the theme's Firefox version constraint, as written in its manifest
+	Firefox  string   `json:"firefox,omitempty"`
+	Variants []string `json:"variants,omitempty"`
+	// Score is how well the theme matched the search query, see (Catalog).Search
+	Score float32 `json:"score,omitempty"`
+}
+
+// NewThemeResult describes the theme.
+func NewThemeResult(theme Theme) ThemeResult {
+	variants := theme.AvailableVariants()
+	sort.Strings(variants)
+	return ThemeResult{
+		Name:        theme.Name(),
+		Author:      theme.AuthorName(),
+		Description: strings.TrimSpace(theme.Description),
+		Tags:        theme.Tags,
+		Firefox:     theme.FirefoxVersion,
+		Variants:    variants,
+	}
+}
+
+// BackupResult describes a backup of a profile.
+type BackupResult struct {
+	Profile     string `json:"profile"`
+	ID          string `json:"id"`
+	CreatedAt   string `json:"createdAt"`
+	Description string `json:"description"`
+}
+
+// LogResult emits the result of a command in JSON mode, and does nothing otherwise: the steps already tell what happened.
+func LogResult(kind string, result interface{}) {
+	if JSONOutput {
+		emitEvent(Event{Type: EventResult, Kind: kind, Result: result})
+	}
+}
+
+// emitEvent prints the event as a JSON line.
+func emitEvent(event Event) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		LogDebug("couldn't encode event %#v: %s", event, err)
+		return
+	}
+	printfln("%s", line)
+}
+
+var ansiEscapeSequence = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// plainText removes color tags (see colorstring) and ANSI escape sequences from s.
+func plainText(s string) string {
+	uncolorizer := colorstring.Colorize{Colors: colorizer.Colors, Disable: true}
+	withoutTags := uncolorizer.Color(s)
+	return strings.TrimSpace(ansiEscapeSequence.ReplaceAllString(withoutTags, ""))
+}
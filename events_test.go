@@ -0,0 +1,43 @@
+package ffcss
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONOutput(t *testing.T) {
+	JSONOutput = true
+	defer func() { JSONOutput = false }()
+	mockedStdout = bytes.Buffer{}
+	BaseIndentLevel = 0
+
+	LogStep(1, "Apply theme [blue][bold]%s[reset] to profile %s", "materialfox", mockedProfile.Display())
+	LogWarning("[yellow]this theme is %d%% untested", 50)
+	DisplayErrorMessage(fmt.Errorf("while downloading: %w", fmt.Errorf("no such theme")))
+	LogResult(ResultDownloaded, DownloadResult{Theme: "materialfox", DownloadedTo: "/cache/materialfox"})
+	ShowHookOutput("\x1b[32mdone\x1b[0m\n")
+	showManifestSource(Theme{raw: "name: materialfox\n"})
+	theme := NewTheme()
+	theme.AskToSeeManifestSource(false)
+	LogDebug("not shown without DEBUG")
+	os.Setenv("DEBUG", "1")
+	LogDebug("fetching %s", "materialfox")
+	os.Unsetenv("DEBUG")
+	assert.Equal(t, `{"type":"step","level":1,"message":"Apply theme materialfox to profile default-release (667ekipp)"}
+{"type":"warning","message":"this theme is 50% untested"}
+{"type":"error","message":"while downloading: no such theme"}
+{"type":"result","kind":"downloaded","result":{"theme":"materialfox","downloadedTo":"/cache/materialfox"}}
+{"type":"step","level":2,"message":"done"}
+{"type":"debug","message":"fetching materialfox"}
+`, mockedStdout.String())
+
+	// Results are only shown in JSON mode
+	JSONOutput = false
+	mockedStdout = bytes.Buffer{}
+	LogResult(ResultVersion, VersionString)
+	assert.Empty(t, mockedStdout.String())
+}
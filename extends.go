@@ -0,0 +1,188 @@
+package ffcss
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ExtendedManifestsDirName is the name of the directory of the cache where manifests extended by URL are downloaded to.
+// It starts with a dot so that it can't be mistaken for a cached theme.
+const ExtendedManifestsDirName = ".extends"
+
+// notInherited are the top-level keys of a manifest that are not inherited from the manifest it extends.
+var notInherited = []string{"name", "extends"}
+
+var manifestFileExtension = regexp.MustCompile(`\.ya?ml$`)
+
+// inheritManifest merges the (migrated) source raw of the manifest at location with the manifest it extends, if any, recursively.
+// location is a path or a URL, and is used to resolve relative paths in extends.
+// chain are the locations of the manifests that extend this one, to detect cycles.
+// raw is returned as is if the manifest does not extend another one.
+//
+// Manifests are merged key by key: mappings (such as variants, components, config or run) are merged recursively,
+// so that a variant of the same name is merged with the extended manifest's one,
+// while lists and other values replace the extended manifest's. Setting a key to null removes the extended manifest's value.
+// name and extends are not inherited.
+func inheritManifest(location string, raw []byte, chain []string) ([]byte, error) {
+	var document map[interface{}]interface{}
+	if err := yaml.Unmarshal(raw, &document); err != nil || document["extends"] == nil {
+		// Syntax errors are reported by LoadManifest
+		return raw, nil
+	}
+	reference, ok := document["extends"].(string)
+	if !ok {
+		return raw, fmt.Errorf("extends must be the name of a theme of the catalog, a URL or a path, not %v", document["extends"])
+	}
+
+	parentLocation, err := resolveExtendedManifest(reference, location)
+	if err != nil {
+		return raw, err
+	}
+	if !isValidURL(parentLocation) {
+		// So that the same file is always designated by the same location, for cycle detection
+		parentLocation, _ = filepath.Abs(parentLocation)
+	}
+	chain = append(chain, location)
+	for _, extending := range chain {
+		if extending == parentLocation {
+			return raw, fmt.Errorf("manifests extend each other in a cycle: %s", strings.Join(append(chain, parentLocation), " extends "))
+		}
+	}
+
+	parentRaw, err := readExtendedManifest(parentLocation)
+	if err != nil {
+		return raw, err
+	}
+	parentMigrated, _, err := MigrateManifest(parentRaw)
+	if err != nil {
+		LogDebug("could not migrate %s: %s", parentLocation, err)
+	}
+	parentRaw, err = inheritManifest(parentLocation, parentMigrated, chain)
+	if err != nil {
+		return raw, err
+	}
+	var parent map[interface{}]interface{}
+	if err := yaml.Unmarshal(parentRaw, &parent); err != nil {
+		return raw, fmt.Errorf("while parsing the extended manifest %s: %w", parentLocation, err)
+	}
+	for _, key := range notInherited {
+		delete(parent, key)
+	}
+
+	merged, err := yaml.Marshal(mergeManifestValues(parent, document))
+	if err != nil {
+		return raw, fmt.Errorf("while merging %s with the manifest it extends: %w", location, err)
+	}
+	return merged, nil
+}
+
+// mergeManifestValues merges a value of a manifest with the value of the manifest it extends, see inheritManifest.
+func mergeManifestValues(parent interface{}, child interface{}) interface{} {
+	parentMapping, parentIsMapping := parent.(map[interface{}]interface{})
+	childMapping, childIsMapping := child.(map[interface{}]interface{})
+	if !parentIsMapping || !childIsMapping {
+		return child
+	}
+	merged := make(map[interface{}]interface{}, len(parentMapping)+len(childMapping))
+	for key, value := range parentMapping {
+		merged[key] = value
+	}
+	for key, value := range childMapping {
+		merged[key] = mergeManifestValues(parentMapping[key], value)
+	}
+	return merged
+}
+
+// resolveExtendedManifest returns the location of the manifest reference designates, in a manifest at location.
+// reference is either a URL, a path (relative to the manifest's folder, or to its URL) or the name of a theme of the catalog.
+// Manifests that were downloaded (see isDownloadedManifest) can only extend manifests of their own folder by path.
+func resolveExtendedManifest(reference string, location string) (string, error) {
+	if isValidURL(reference) {
+		return reference, nil
+	}
+	if strings.ContainsAny(reference, `/\`) || manifestFileExtension.MatchString(reference) {
+		if isValidURL(location) {
+			base, _ := url.Parse(location)
+			relative, err := url.Parse(filepath.ToSlash(reference))
+			if err != nil {
+				return "", fmt.Errorf("invalid path %q in extends: %w", reference, err)
+			}
+			return base.ResolveReference(relative).String(), nil
+		}
+		if isDownloadedManifest(location) {
+			resolved, err := SafeJoin(filepath.Dir(location), reference)
+			if err != nil {
+				return "", fmt.Errorf("downloaded manifests can only extend manifests of their own folder: %w", err)
+			}
+			return resolved, nil
+		}
+		if filepath.IsAbs(reference) {
+			return reference, nil
+		}
+		return filepath.Join(filepath.Dir(location), reference), nil
+	}
+	for _, directory := range []string{ConfigDir("themes"), RegistryDir("themes")} {
+		entries, _ := os.ReadDir(directory)
+		for _, entry := range entries {
+			name := manifestFileExtension.ReplaceAllString(entry.Name(), "")
+			if !entry.IsDir() && name != entry.Name() && lookupPreprocess(name) == lookupPreprocess(reference) {
+				return filepath.Join(directory, entry.Name()), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("the extended theme %q is not in the catalog", reference)
+}
+
+// isDownloadedManifest returns true if the manifest at location was downloaded, with a theme or from the registry,
+// as opposed to the user's own manifests. Both are in the cache.
+func isDownloadedManifest(location string) bool {
+	location, err := filepath.Abs(location)
+	if err != nil {
+		return true
+	}
+	cache, err := filepath.Abs(CacheDir())
+	return err != nil || isWithin(cache, location)
+}
+
+// readExtendedManifest reads the manifest at location.
+// Manifests at URLs are downloaded the first time they are needed, to ExtendedManifestsDirName in the cache,
+// and read from there afterwards: see ClearExtendedManifests to download them again.
+func readExtendedManifest(location string) ([]byte, error) {
+	if !isValidURL(location) {
+		raw, err := os.ReadFile(location)
+		if err != nil {
+			return nil, fmt.Errorf("while reading the extended manifest %s: %w", location, err)
+		}
+		return raw, nil
+	}
+	cached := CacheDir(ExtendedManifestsDirName, fmt.Sprintf("%x.yaml", sha1.Sum([]byte(location))))
+	if raw, err := os.ReadFile(cached); err == nil {
+		return raw, nil
+	}
+	err := os.MkdirAll(filepath.Dir(cached), 0700)
+	if err != nil {
+		return nil, fmt.Errorf("while creating %s: %w", filepath.Dir(cached), err)
+	}
+	err = DownloadFile(location, cached, nil)
+	if err != nil {
+		return nil, fmt.Errorf("while downloading the extended manifest %s: %w", location, err)
+	}
+	return os.ReadFile(cached)
+}
+
+// ClearExtendedManifests removes the manifests extended by URL from the cache, so that they are downloaded again
+// the next time a manifest that extends them is loaded. It is used by ffcss update and ffcss registry sync.
+func ClearExtendedManifests() error {
+	err := os.RemoveAll(CacheDir(ExtendedManifestsDirName))
+	if err != nil {
+		return fmt.Errorf("while removing the extended manifests from the cache: %w", err)
+	}
+	return nil
+}
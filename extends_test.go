@@ -0,0 +1,164 @@
+package ffcss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadManifestExtends(t *testing.T) {
+	root := filepath.Join(testarea, "extends")
+	os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "fork"), 0700)
+	os.WriteFile(filepath.Join(root, "original.yaml"), []byte(`name: original
+download: https://github.com/someone/original
+description: The original theme
+user.js: user.js
+assets:
+  - chrome/**
+  - icons/**
+copy from: chrome/
+config:
+  svg.context-properties.content.enabled: true
+  browser.tabs.inTitlebar: 0
+variants:
+  dark:
+    userChrome: dark.css
+    config:
+      ui.systemUsesDarkTheme: 1
+  light:
+    userChrome: light.css
+`), 0600)
+	forkPath := filepath.Join(root, "fork", "ffcss.yaml")
+	os.WriteFile(forkPath, []byte(`extends: ../original.yaml
+name: fork
+download: https://github.com/someone-else/fork
+assets:
+  - chrome/**
+config:
+  browser.tabs.inTitlebar: 1
+user.js: null
+variants:
+  dark:
+    config:
+      ui.prefersReducedMotion: 1
+  sepia:
+    userChrome: sepia.css
+`), 0600)
+
+	fork, err := LoadManifest(forkPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "fork", fork.Name())
+	assert.Equal(t, "https://github.com/someone-else/fork", fork.DownloadAt)
+	assert.Equal(t, "The original theme", fork.Description)
+	assert.Equal(t, "chrome/", fork.CopyFrom)
+	assert.Equal(t, "", fork.UserJS)
+	assert.Equal(t, []FileTemplate{"chrome/**"}, fork.Assets)
+	assert.Equal(t, Config{
+		"toolkit.legacyUserProfileCustomizations.stylesheets": true,
+		"svg.context-properties.content.enabled":              true,
+		"browser.tabs.inTitlebar":                             1,
+	}, fork.Config)
+	assert.ElementsMatch(t, []string{"dark", "light", "sepia"}, fork.AvailableVariants())
+	assert.Equal(t, "dark.css", fork.Variants["dark"].UserChrome)
+	assert.Equal(t, Config{"ui.systemUsesDarkTheme": 1, "ui.prefersReducedMotion": 1}, fork.Variants["dark"].Config)
+
+	// The name is not inherited
+	os.WriteFile(forkPath, []byte("extends: ../original.yaml\ndownload: https://github.com/someone-else/forked\n"), 0600)
+	fork, err = LoadManifest(forkPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "forked", fork.Name())
+
+	// Themes of the catalog can be extended by name
+	os.WriteFile(ConfigDir("themes", "extends-base.yaml"), []byte("name: extends-base\ndownload: https://github.com/someone/base\nassets: [chrome/**]\ncopy from: chrome/\n"), 0600)
+	defer os.Remove(ConfigDir("themes", "extends-base.yaml"))
+	os.WriteFile(forkPath, []byte("extends: Extends Base\nname: fork\n"), 0600)
+	fork, err = LoadManifest(forkPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/someone/base", fork.DownloadAt)
+	assert.Equal(t, "chrome/", fork.CopyFrom)
+
+	os.WriteFile(forkPath, []byte("extends: nonexistent\nname: fork\n"), 0600)
+	_, err = LoadManifest(forkPath)
+	assert.EqualError(t, err, `while loading the manifest `+forkPath+` extends: the extended theme "nonexistent" is not in the catalog`)
+}
+
+func TestLoadManifestExtendsURL(t *testing.T) {
+	defer os.RemoveAll(CacheDir(ExtendedManifestsDirName))
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/themes/remote.yaml":
+			w.Write([]byte("extends: bases/chrome.yaml\nname: remote\ndescription: A remote theme\n"))
+		case "/themes/bases/chrome.yaml":
+			w.Write([]byte("assets: [chrome/**]\ncopy from: chrome/\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	manifestPath := filepath.Join(testarea, "extends-url.yaml")
+	os.WriteFile(manifestPath, []byte("extends: "+server.URL+"/themes/remote.yaml\nname: local\n"), 0600)
+	manifest, err := LoadManifest(manifestPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "local", manifest.Name())
+	assert.Equal(t, "A remote theme", manifest.Description)
+	assert.Equal(t, "chrome/", manifest.CopyFrom)
+
+	// Extended manifests are only downloaded once, until they are cleared
+	_, err = LoadManifest(manifestPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests)
+	assert.NoError(t, ClearExtendedManifests())
+	_, err = LoadManifest(manifestPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, requests)
+}
+
+func TestLoadManifestExtendsDownloaded(t *testing.T) {
+	directory := CacheDir("extends-downloaded", RootVariantName)
+	defer os.RemoveAll(CacheDir("extends-downloaded"))
+	os.MkdirAll(filepath.Join(directory, "bases"), 0700)
+	os.WriteFile(filepath.Join(directory, "bases", "base.yaml"), []byte("description: A base\n"), 0600)
+	os.WriteFile(filepath.Join(testarea, "outside.yaml"), []byte("description: Outside\n"), 0600)
+	manifestPath := filepath.Join(directory, "ffcss.yaml")
+
+	os.WriteFile(manifestPath, []byte("name: downloaded\nextends: bases/base.yaml\n"), 0600)
+	manifest, err := LoadManifest(manifestPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "A base", manifest.Description)
+
+	// Downloaded manifests can't extend files outside of their folder
+	os.WriteFile(manifestPath, []byte("name: downloaded\nextends: ../../../outside.yaml\n"), 0600)
+	_, err = LoadManifest(manifestPath)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "downloaded manifests can only extend manifests of their own folder")
+	absolute, _ := filepath.Abs(filepath.Join(testarea, "outside.yaml"))
+	os.WriteFile(manifestPath, []byte("name: downloaded\nextends: "+absolute+"\n"), 0600)
+	_, err = LoadManifest(manifestPath)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "downloaded manifests can only extend manifests of their own folder")
+}
+
+func TestLoadManifestExtendsCycle(t *testing.T) {
+	root := filepath.Join(testarea, "extends-cycle")
+	os.RemoveAll(root)
+	os.MkdirAll(root, 0700)
+	first, _ := filepath.Abs(filepath.Join(root, "first.yaml"))
+	second, _ := filepath.Abs(filepath.Join(root, "second.yaml"))
+	os.WriteFile(first, []byte("name: first\nextends: second.yaml\n"), 0600)
+	os.WriteFile(second, []byte("name: second\nextends: ./first.yaml\n"), 0600)
+
+	_, err := LoadManifest(first)
+	assert.EqualError(t, err, "while loading the manifest "+first+" extends: manifests extend each other in a cycle: "+first+" extends "+second+" extends "+first)
+
+	os.WriteFile(first, []byte("name: first\nextends: first.yaml\n"), 0600)
+	_, err = LoadManifest(first)
+	assert.EqualError(t, err, "while loading the manifest "+first+" extends: manifests extend each other in a cycle: "+first+" extends "+first)
+}
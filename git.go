@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"os/exec"
+	"strings"
 )
 
 // currentRepoRemote returns the git repo's origin remote URL
@@ -57,3 +58,17 @@ func switchGitTag(tagName, clonedTo string) error {
 	}
 	return nil
 }
+
+// runGit runs git with the given arguments in directory, and returns its output, without surrounding whitespace.
+func runGit(directory string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	process := exec.Command("git", args...)
+	process.Dir = directory
+	process.Stdout = &stdout
+	process.Stderr = &stderr
+	err := process.Run()
+	if err != nil {
+		return "", fmt.Errorf("while running git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -8,18 +8,21 @@ require (
 	github.com/bmatcuk/doublestar v1.3.4
 	github.com/charmbracelet/glamour v0.5.0
 	github.com/docopt/docopt-go v0.0.0-20180111231733-ee0de3bc6815
-	github.com/evilsocket/islazy v1.10.6
+	github.com/fsnotify/fsnotify v1.5.4
 	github.com/hbollon/go-edlib v1.6.0
 	github.com/hoisie/mustache v0.0.0-20160804235033-6375acf62c69
+	github.com/klauspost/compress v1.15.9
 	github.com/mattn/go-colorable v0.1.12 // indirect
 	github.com/mgutz/ansi v0.0.0-20200706080929-d51e80ef957d // indirect
 	github.com/microcosm-cc/bluemonday v1.0.18 // indirect
 	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db
 	github.com/muesli/termenv v0.11.0 // indirect
 	github.com/stretchr/testify v1.7.0
+	github.com/ulikunitz/xz v0.5.12
 	github.com/yuin/goldmark v1.4.10 // indirect
 	golang.org/x/net v0.0.0-20220225172249-27dd8689420f // indirect
-	golang.org/x/sys v0.0.0-20220315194320-039c03cc5b86 // indirect
+	golang.org/x/sys v0.0.0-20220412211240-33da011f77ad
 	golang.org/x/text v0.3.7
 	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,184 @@
+package ffcss
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DownloadTimeout is how long a download can go without receiving any data before it is abandoned.
+var DownloadTimeout = 30 * time.Second
+
+// maxRedirects is the number of redirects followed before a download is abandoned.
+const maxRedirects = 10
+
+// partialDownloadSuffix is appended to the destination of a download while it is in progress.
+// If a download is interrupted, the partial file is kept, and the download resumes from there the next time.
+const partialDownloadSuffix = ".part"
+
+// partialValidatorSuffix is appended to the path of a partial download to get the file storing the validator
+// (ETag or Last-Modified header) of the file being downloaded, so that a download is only resumed if the file did not change since.
+const partialValidatorSuffix = ".validator"
+
+// DownloadProgressFunc is called regularly during a download with the number of bytes downloaded so far,
+// and the total size of the file, or -1 if it is not known.
+type DownloadProgressFunc func(downloaded int64, total int64)
+
+// DownloadFile downloads the file at URL to destination, following redirects.
+// The file is first written to destination + ".part". If that file already exists, the download is resumed from where it stopped,
+// if the server supports it and the file did not change since.
+// progress can be nil.
+func DownloadFile(URL string, destination string, progress DownloadProgressFunc) error {
+	partial := destination + partialDownloadSuffix
+	validatorPath := partial + partialValidatorSuffix
+	var alreadyDownloaded int64
+	validator, _ := os.ReadFile(validatorPath)
+	if stat, err := os.Stat(partial); err == nil && len(validator) > 0 {
+		alreadyDownloaded = stat.Size()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, URL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid URL %s: %w", URL, err)
+	}
+	if alreadyDownloaded > 0 {
+		LogDebug("resuming download of %s from byte %d", URL, alreadyDownloaded)
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", alreadyDownloaded))
+		// The server sends the whole file instead if it changed since the partial download was started
+		request.Header.Set("If-Range", string(validator))
+	}
+
+	// Abandon the download if no data is received for too long
+	stalled := time.AfterFunc(DownloadTimeout, cancel)
+	defer stalled.Stop()
+
+	client := http.Client{
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			LogDebug("following redirect to %s", request.URL)
+			return nil
+		},
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("no response after %s", DownloadTimeout)
+		}
+		return err
+	}
+	defer response.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case response.StatusCode == http.StatusPartialContent && alreadyDownloaded > 0:
+		flags |= os.O_APPEND
+	case response.StatusCode == http.StatusRequestedRangeNotSatisfiable && alreadyDownloaded > 0:
+		// The partial file might already be complete
+		current := responseValidator(response)
+		if completeSize(response.Header.Get("Content-Range")) == alreadyDownloaded && (current == "" || current == string(validator)) {
+			os.Remove(validatorPath)
+			return os.Rename(partial, destination)
+		}
+		os.Remove(partial)
+		os.Remove(validatorPath)
+		return fmt.Errorf("server could not resume the download, try again")
+	case response.StatusCode >= 400:
+		return fmt.Errorf("server returned %s", response.Status)
+	default:
+		// The server ignored the Range header, or the file changed: start over
+		if alreadyDownloaded > 0 {
+			LogDebug("server sent the whole file, restarting the download of %s", URL)
+		}
+		flags |= os.O_TRUNC
+		alreadyDownloaded = 0
+		os.Remove(validatorPath)
+		if validator := responseValidator(response); validator != "" {
+			err = os.WriteFile(validatorPath, []byte(validator), 0600)
+			if err != nil {
+				return fmt.Errorf("while writing %s: %w", validatorPath, err)
+			}
+		}
+	}
+
+	file, err := os.OpenFile(partial, flags, 0600)
+	if err != nil {
+		return fmt.Errorf("while opening %s: %w", partial, err)
+	}
+	defer file.Close()
+
+	total := int64(-1)
+	if response.ContentLength >= 0 {
+		total = alreadyDownloaded + response.ContentLength
+	}
+	downloaded := alreadyDownloaded
+	buffer := make([]byte, 32*1024)
+	lastReport := time.Time{}
+	for {
+		read, readErr := response.Body.Read(buffer)
+		if read > 0 {
+			stalled.Reset(DownloadTimeout)
+			if _, err := file.Write(buffer[:read]); err != nil {
+				return fmt.Errorf("while writing to %s: %w", partial, err)
+			}
+			downloaded += int64(read)
+			if progress != nil && time.Since(lastReport) > 100*time.Millisecond {
+				progress(downloaded, total)
+				lastReport = time.Now()
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("no data received for %s, stopped after %d bytes", DownloadTimeout, downloaded)
+			}
+			return fmt.Errorf("stopped after %d bytes: %w", downloaded, readErr)
+		}
+	}
+	if progress != nil {
+		progress(downloaded, total)
+	}
+	if total >= 0 && downloaded != total {
+		return fmt.Errorf("expected %d bytes, got %d", total, downloaded)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("while writing to %s: %w", partial, err)
+	}
+	os.Remove(validatorPath)
+	return os.Rename(partial, destination)
+}
+
+// responseValidator returns the value to send in an If-Range header to resume downloading the response's file,
+// or an empty string if the server gave no way to tell whether the file changed.
+// Weak ETags cannot be used in If-Range headers, so the Last-Modified header is used instead.
+func responseValidator(response *http.Response) string {
+	if etag := response.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return response.Header.Get("Last-Modified")
+}
+
+// completeSize returns the complete size of the file from a Content-Range header, or -1 if it is not known.
+func completeSize(contentRange string) int64 {
+	slash := strings.LastIndex(contentRange, "/")
+	if slash == -1 {
+		return -1
+	}
+	size, err := strconv.ParseInt(contentRange[slash+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
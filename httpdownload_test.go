@@ -0,0 +1,99 @@
+package ffcss
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadFile(t *testing.T) {
+	content := bytes.Repeat([]byte("PK\x03\x04 not really a zip file "), 10000)
+	requests := make([]*http.Request, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/theme.zip", http.StatusFound)
+		case "/theme.zip":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("ETag", `"v2"`)
+			http.ServeContent(w, r, "theme.zip", time.Time{}, bytes.NewReader(content))
+		case "/slow":
+			time.Sleep(500 * time.Millisecond)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	directory := filepath.Join(testarea, "httpdownload")
+	os.MkdirAll(directory, 0700)
+	destination := filepath.Join(directory, "theme.zip")
+
+	reported := int64(0)
+	err := DownloadFile(server.URL+"/redirect", destination, func(downloaded int64, total int64) {
+		assert.Equal(t, int64(len(content)), total)
+		reported = downloaded
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(content)), reported)
+	downloaded, _ := os.ReadFile(destination)
+	assert.Equal(t, content, downloaded)
+	assert.NoFileExists(t, destination+partialDownloadSuffix)
+	assert.NoFileExists(t, destination+partialDownloadSuffix+partialValidatorSuffix)
+	format, err := detectArchiveFormat(destination)
+	assert.NoError(t, err)
+	assert.Equal(t, "zip", format)
+
+	// Resume an interrupted download
+	os.Remove(destination)
+	os.WriteFile(destination+partialDownloadSuffix, content[:1234], 0600)
+	os.WriteFile(destination+partialDownloadSuffix+partialValidatorSuffix, []byte(`"v2"`), 0600)
+	requests = requests[:0]
+	assert.NoError(t, DownloadFile(server.URL+"/theme.zip", destination, nil))
+	downloaded, _ = os.ReadFile(destination)
+	assert.Equal(t, content, downloaded)
+	if assert.Len(t, requests, 1) {
+		assert.Equal(t, "bytes=1234-", requests[0].Header.Get("Range"))
+		assert.Equal(t, `"v2"`, requests[0].Header.Get("If-Range"))
+	}
+	assert.NoFileExists(t, destination+partialDownloadSuffix+partialValidatorSuffix)
+
+	// The partial download was already complete
+	os.Rename(destination, destination+partialDownloadSuffix)
+	os.WriteFile(destination+partialDownloadSuffix+partialValidatorSuffix, []byte(`"v2"`), 0600)
+	assert.NoError(t, DownloadFile(server.URL+"/theme.zip", destination, nil))
+	downloaded, _ = os.ReadFile(destination)
+	assert.Equal(t, content, downloaded)
+
+	// The file changed since the partial download was started, even though it has the same size
+	os.Remove(destination)
+	os.WriteFile(destination+partialDownloadSuffix, bytes.Repeat([]byte("x"), len(content)), 0600)
+	os.WriteFile(destination+partialDownloadSuffix+partialValidatorSuffix, []byte(`"v1"`), 0600)
+	assert.NoError(t, DownloadFile(server.URL+"/theme.zip", destination, nil))
+	downloaded, _ = os.ReadFile(destination)
+	assert.Equal(t, content, downloaded)
+
+	// Partial downloads without a validator can't be resumed safely
+	os.Remove(destination)
+	os.WriteFile(destination+partialDownloadSuffix, bytes.Repeat([]byte("x"), 1234), 0600)
+	requests = requests[:0]
+	assert.NoError(t, DownloadFile(server.URL+"/theme.zip", destination, nil))
+	downloaded, _ = os.ReadFile(destination)
+	assert.Equal(t, content, downloaded)
+	if assert.Len(t, requests, 1) {
+		assert.Empty(t, requests[0].Header.Get("Range"))
+	}
+
+	assert.EqualError(t, DownloadFile(server.URL+"/notfound", destination, nil), "server returned 404 Not Found")
+
+	DownloadTimeout = 100 * time.Millisecond
+	defer func() { DownloadTimeout = 30 * time.Second }()
+	assert.EqualError(t, DownloadFile(server.URL+"/slow", destination, nil), "no response after 100ms")
+}
@@ -7,60 +7,65 @@ import (
 	"path/filepath"
 )
 
-// InstallAssets installs the assets in the specified profile directory
-func (t Theme) InstallAssets(operatingSystem string, variant Variant, profileDir string) (err error) {
+// InstallAssets installs the assets in the specified profile directory.
+// It returns the paths of the files it wrote.
+func (t Theme) InstallAssets(operatingSystem string, variant Variant, profileDir string) (installed []string, err error) {
+	installed = make([]string, 0)
 	files, err := t.AssetsPaths(operatingSystem, variant)
 	if err != nil {
-		return fmt.Errorf("while gathering assets: %w", err)
+		return installed, fmt.Errorf("while gathering assets: %w", err)
 	}
 	LogDebug("gathered %d asset(s)", len(files))
 
 	for _, file := range files {
 		stat, err := os.Stat(file)
 		if err != nil {
-			return fmt.Errorf("couldn't check file %s: %w", file, err)
+			return installed, fmt.Errorf("couldn't check file %s: %w", file, err)
 		}
 
 		if stat.IsDir() {
 			continue
 		}
 
-		content, err := ioutil.ReadFile(file)
+		destPath, err := t.DestinationPathOfAsset(file, profileDir, operatingSystem, variant)
 		if err != nil {
-			return fmt.Errorf("while reading %s: %w", file, err)
+			return installed, err
 		}
 
-		destPath, err := t.DestinationPathOfAsset(file, profileDir, operatingSystem, variant)
+		content, err := ioutil.ReadFile(file)
 		if err != nil {
-			println(err.Error())
-			continue
+			return installed, fmt.Errorf("while reading %s: %w", file, err)
 		}
 
 		err = os.MkdirAll(filepath.Dir(destPath), 0700)
 		if err != nil {
-			return fmt.Errorf("couldn't create parent directories for %s: %w", destPath, err)
+			return installed, fmt.Errorf("couldn't create parent directories for %s: %w", destPath, err)
 		}
 
 		err = ioutil.WriteFile(destPath, content, 0700)
 		if err != nil {
-			return fmt.Errorf("while writing to %s: %w", destPath, err)
+			return installed, fmt.Errorf("while writing to %s: %w", destPath, err)
 		}
+		installed = append(installed, destPath)
 		LogDebug("wrote %s", destPath)
 
 	}
-	return nil
+	return installed, nil
 }
 
 // InstallUserJS installs the content of user.js and the config entries to {{profileDir}}/user.js
-func (t Theme) InstallUserJS(operatingSystem string, variant Variant, profileDir string) error {
+// It returns the path of the file it wrote, if any.
+func (t Theme) InstallUserJS(operatingSystem string, variant Variant, profileDir string) (installed []string, err error) {
 	var content []byte
-	var err error
 
 	if t.UserJS != "" {
-		file := filepath.Join(t.DownloadedTo, renderFileTemplate(t.UserJS, operatingSystem, variant, t.OSNames))
+		file, err := SafeJoin(t.DownloadedTo, renderFileTemplate(t.UserJS, operatingSystem, variant, t.OSNames))
+		if err != nil {
+			return []string{}, fmt.Errorf("invalid user.js: %w", err)
+		}
 		content, err = ioutil.ReadFile(file)
 		if err != nil {
-			return fmt.Errorf("while reading %s: %w", file, err)
+			return []string{}, fmt.Errorf("while reading %s: %w", file, err)
 		}
 
 	} else {
@@ -69,7 +74,7 @@ func (t Theme) InstallUserJS(operatingSystem string, variant Variant, profileDir
 
 	additionalContent, err := t.UserJSFileContent()
 	if err != nil {
-		return fmt.Errorf("while translating config entries to javascript: %w", err)
+		return []string{}, fmt.Errorf("while translating config entries to javascript: %w", err)
 	}
 
 	if additionalContent != "" {
@@ -78,57 +83,65 @@ func (t Theme) InstallUserJS(operatingSystem string, variant Variant, profileDir
 	}
 
 	if string(content) == "" {
-		return nil
+		return []string{}, nil
 	}
 
 	err = ioutil.WriteFile(filepath.Join(profileDir, "user.js"), content, 0700)
 	if err != nil {
-		return fmt.Errorf("while writing: %w", err)
+		return []string{}, fmt.Errorf("while writing: %w", err)
 	}
 
 	LogDebug("installed user.js @ %s", filepath.Join(profileDir, "user.js"))
 
-	return nil
+	return []string{filepath.Join(profileDir, "user.js")}, nil
 }
 
 // InstallUserChrome writes the content of userChrome to {{profileDir}}/chrome/userChrome.css
-func (t Theme) InstallUserChrome(os string, variant Variant, profileDir string) error {
+// It returns the path of the file it wrote, if any.
+func (t Theme) InstallUserChrome(os string, variant Variant, profileDir string) (installed []string, err error) {
 	if t.UserChrome == "" {
-		return nil
+		return []string{}, nil
+	}
+	file, err := SafeJoin(t.DownloadedTo, renderFileTemplate(t.UserChrome, os, variant, t.OSNames))
+	if err != nil {
+		return []string{}, fmt.Errorf("invalid userChrome: %w", err)
 	}
-	file := filepath.Join(t.DownloadedTo, renderFileTemplate(t.UserChrome, os, variant, t.OSNames))
 	content, err := ioutil.ReadFile(file)
 	if err != nil {
-		return fmt.Errorf("while reading %s: %w", file, err)
+		return []string{}, fmt.Errorf("while reading %s: %w", file, err)
 	}
 
 	err = ioutil.WriteFile(filepath.Join(profileDir, "chrome", "userChrome.css"), content, 0700)
 	if err != nil {
-		return fmt.Errorf("while writing: %w", err)
+		return []string{}, fmt.Errorf("while writing: %w", err)
 	}
 
 	LogDebug("installed userChrome.css @ %s", filepath.Join(profileDir, "chrome", "userChrome.css"))
 
-	return nil
+	return []string{filepath.Join(profileDir, "chrome", "userChrome.css")}, nil
 }
 
 // InstallUserContent writes the content of userContent to {{profileDir}}/chrome/userContent.css
-func (t Theme) InstallUserContent(os string, variant Variant, profileDir string) error {
+// It returns the path of the file it wrote, if any.
+func (t Theme) InstallUserContent(os string, variant Variant, profileDir string) (installed []string, err error) {
 	if t.UserContent == "" {
-		return nil
+		return []string{}, nil
+	}
+	file, err := SafeJoin(t.DownloadedTo, renderFileTemplate(t.UserContent, os, variant, t.OSNames))
+	if err != nil {
+		return []string{}, fmt.Errorf("invalid userContent: %w", err)
 	}
-	file := filepath.Join(t.DownloadedTo, renderFileTemplate(t.UserContent, os, variant, t.OSNames))
 	content, err := ioutil.ReadFile(file)
 	if err != nil {
-		return fmt.Errorf("while reading %s: %w", file, err)
+		return []string{}, fmt.Errorf("while reading %s: %w", file, err)
 	}
 
 	err = ioutil.WriteFile(filepath.Join(profileDir, "chrome", "userContent.css"), content, 0700)
 	if err != nil {
-		return fmt.Errorf("while writing: %w", err)
+		return []string{}, fmt.Errorf("while writing: %w", err)
 	}
 
 	LogDebug("installed userContent.css @ %s", filepath.Join(profileDir, "chrome", "userContent.css"))
 
-	return nil
+	return []string{filepath.Join(profileDir, "chrome", "userContent.css")}, nil
 }
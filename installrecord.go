@@ -0,0 +1,303 @@
+package ffcss
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// InstallRecord lists what a theme installed on a profile, so that it can be uninstalled later
+// without touching the rest of the profile.
+type InstallRecord struct {
+	Theme      string
+	Variant    string   `yaml:",omitempty"`
+	Components []string `yaml:",omitempty"`
+	// Files maps paths of installed files, relative to the profile directory, to the SHA-256 hash of their contents.
+	Files map[string]string
+	// Prefs lists the configuration entries set by the installed user.js.
+	Prefs []string `yaml:",omitempty"`
+	// PreviousPrefs maps the entries of Prefs that were already set in the profile's prefs.js before the installation
+	// to their previous values, as JS source code. They are restored on uninstall, while the other entries of Prefs are removed.
+	PreviousPrefs map[string]string `yaml:"previous prefs,omitempty"`
+}
+
+// installRecordPath returns the path of the file where the install record of the profile is stored.
+func (ffp FirefoxProfile) installRecordPath() string {
+	return ConfigDir("installed", ffp.FullName()+".yaml")
+}
+
+// NewInstallRecord creates an install record for the given installed files, which are hashed.
+// installed contains absolute paths, that must be inside profileDir.
+func NewInstallRecord(current CurrentTheme, profileDir string, installed []string) (InstallRecord, error) {
+	record := InstallRecord{
+		Theme:      current.Name,
+		Variant:    current.Variant,
+		Components: current.Components,
+		Files:      make(map[string]string, len(installed)),
+		Prefs:      []string{},
+	}
+	for _, file := range installed {
+		relative, err := filepath.Rel(profileDir, file)
+		if err != nil || !isWithin(profileDir, file) {
+			return record, fmt.Errorf("installed file %s is outside of the profile directory %s", file, profileDir)
+		}
+		hash, err := hashFile(file)
+		if err != nil {
+			return record, fmt.Errorf("while hashing %s: %w", file, err)
+		}
+		record.Files[filepath.ToSlash(relative)] = hash
+		if relative == "user.js" {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return record, fmt.Errorf("while reading %s: %w", file, err)
+			}
+			record.Prefs = UserPrefNames(content)
+		}
+	}
+	return record, nil
+}
+
+// SnapshotPrefs returns the record with the values that the configuration entries it lists had in the profile's prefs.js,
+// so that uninstalling the theme does not remove entries the user had set before installing it.
+// It must be called before Firefox is started with the new user.js, which copies its entries into prefs.js.
+// Entries that were already set by the theme previously installed on the profile keep the values recorded by that installation.
+func (ffp FirefoxProfile) SnapshotPrefs(record InstallRecord) (InstallRecord, error) {
+	record.PreviousPrefs = nil
+	if len(record.Prefs) == 0 {
+		return record, nil
+	}
+	prefsPath := filepath.Join(ffp.Path, "prefs.js")
+	content, err := os.ReadFile(prefsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return record, fmt.Errorf("while reading %s: %w", prefsPath, err)
+	}
+	current := UserPrefValues(content)
+	previous, found, err := ffp.InstallRecord()
+	if err != nil {
+		return record, fmt.Errorf("while reading the previous install record: %w", err)
+	}
+	previousValues := make(map[string]string)
+	for _, key := range record.Prefs {
+		if found && contains(previous.Prefs, key) {
+			if value, ok := previous.PreviousPrefs[key]; ok {
+				previousValues[key] = value
+			}
+			continue
+		}
+		if value, ok := current[key]; ok {
+			previousValues[key] = value
+		}
+	}
+	if len(previousValues) > 0 {
+		record.PreviousPrefs = previousValues
+	}
+	return record, nil
+}
+
+// UpdateInstallRecord hashes the given files again in the profile's install record, after they were re-installed.
+// files are absolute paths inside of the profile directory. Files that do not exist anymore are removed from the record,
+// and the configuration entries are read again if user.js is one of them.
+// Nothing is done if no theme was installed on the profile by ffcss.
+func (ffp FirefoxProfile) UpdateInstallRecord(files []string) error {
+	record, found, err := ffp.InstallRecord()
+	if err != nil || !found {
+		return err
+	}
+	if record.Files == nil {
+		record.Files = make(map[string]string, len(files))
+	}
+	for _, file := range files {
+		relative, err := filepath.Rel(ffp.Path, file)
+		if err != nil || !isWithin(ffp.Path, file) {
+			return fmt.Errorf("installed file %s is outside of the profile directory %s", file, ffp.Path)
+		}
+		relative = filepath.ToSlash(relative)
+		hash, err := hashFile(file)
+		if os.IsNotExist(err) {
+			delete(record.Files, relative)
+			if relative == "user.js" {
+				record.Prefs = []string{}
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("while hashing %s: %w", file, err)
+		}
+		record.Files[relative] = hash
+		if relative == "user.js" {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("while reading %s: %w", file, err)
+			}
+			record.Prefs = UserPrefNames(content)
+		}
+	}
+	record, err = ffp.SnapshotPrefs(record)
+	if err != nil {
+		return err
+	}
+	return ffp.RecordInstallation(record)
+}
+
+// hashFile returns the hex-encoded SHA-256 hash of the file's contents.
+func hashFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(content)), nil
+}
+
+// InstalledFiles returns the recorded files' paths, relative to the profile directory, sorted.
+func (record InstallRecord) InstalledFiles() []string {
+	files := make([]string, 0, len(record.Files))
+	for file := range record.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// RecordInstallation saves the install record of the profile, replacing any previous one.
+func (ffp FirefoxProfile) RecordInstallation(record InstallRecord) error {
+	err := os.MkdirAll(filepath.Dir(ffp.installRecordPath()), 0700)
+	if err != nil {
+		return fmt.Errorf("while creating install records directory: %w", err)
+	}
+	contents, err := yaml.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("while marshaling into YAML: %w", err)
+	}
+	err = os.WriteFile(ffp.installRecordPath(), contents, 0600)
+	if err != nil {
+		return fmt.Errorf("while writing install record: %w", err)
+	}
+	return nil
+}
+
+// InstallRecord returns the install record of the profile.
+// found is false if no theme was installed on that profile by ffcss (or if it was uninstalled).
+func (ffp FirefoxProfile) InstallRecord() (record InstallRecord, found bool, err error) {
+	contents, err := os.ReadFile(ffp.installRecordPath())
+	if os.IsNotExist(err) {
+		return InstallRecord{}, false, nil
+	}
+	if err != nil {
+		return InstallRecord{}, false, fmt.Errorf("while reading install record: %w", err)
+	}
+	err = yaml.Unmarshal(contents, &record)
+	if err != nil {
+		return InstallRecord{}, false, fmt.Errorf("while parsing install record %s: %w", ffp.installRecordPath(), err)
+	}
+	return record, true, nil
+}
+
+// ModifiedFiles returns the recorded files that were modified since they were installed, relative to the profile directory.
+// Files that were removed are not considered modified.
+func (ffp FirefoxProfile) ModifiedFiles(record InstallRecord) ([]string, error) {
+	modified := make([]string, 0)
+	for _, file := range record.InstalledFiles() {
+		hash, err := hashFile(filepath.Join(ffp.Path, filepath.FromSlash(file)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return modified, fmt.Errorf("while hashing %s: %w", file, err)
+		}
+		if hash != record.Files[file] {
+			modified = append(modified, file)
+		}
+	}
+	return modified, nil
+}
+
+// Uninstall removes the files installed by ffcss on this profile, as well as the configuration entries it set.
+// Files that were modified since they were installed are left in place, and a warning is shown.
+// The removed files are returned, relative to the profile directory.
+func (ffp FirefoxProfile) Uninstall() (removed []string, err error) {
+	removed = make([]string, 0)
+	record, found, err := ffp.InstallRecord()
+	if err != nil {
+		return removed, err
+	}
+	if !found {
+		return removed, fmt.Errorf("no theme was installed on %s by ffcss", ffp)
+	}
+
+	modified, err := ffp.ModifiedFiles(record)
+	if err != nil {
+		return removed, fmt.Errorf("while checking for modified files: %w", err)
+	}
+
+	for _, file := range record.InstalledFiles() {
+		path := filepath.Join(ffp.Path, filepath.FromSlash(file))
+		if contains(modified, file) {
+			LogWarning("%s was modified since it was installed, leaving it in place", file)
+			continue
+		}
+		err = os.Remove(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return removed, fmt.Errorf("while removing %s: %w", path, err)
+		}
+		removed = append(removed, file)
+		removeEmptyParents(filepath.Dir(path), ffp.Path)
+	}
+
+	err = ffp.RestorePrefs(record)
+	if err != nil {
+		return removed, fmt.Errorf("while restoring configuration entries: %w", err)
+	}
+
+	err = os.Remove(ffp.installRecordPath())
+	if err != nil {
+		return removed, fmt.Errorf("while removing install record: %w", err)
+	}
+
+	return removed, ffp.UnregisterCurrentTheme()
+}
+
+// RestorePrefs puts the configuration entries set by the recorded installation back in the profile's prefs.js as they were before:
+// entries that were not set are removed, so that they go back to their default values, and the others get their previous values back.
+func (ffp FirefoxProfile) RestorePrefs(record InstallRecord) error {
+	prefsPath := filepath.Join(ffp.Path, "prefs.js")
+	content, err := os.ReadFile(prefsPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("while reading %s: %w", prefsPath, err)
+	}
+	added := make([]string, 0, len(record.Prefs))
+	for _, key := range record.Prefs {
+		if _, set := record.PreviousPrefs[key]; !set {
+			added = append(added, key)
+		}
+	}
+	content = SetUserPrefCalls(RemoveUserPrefCalls(content, added), record.PreviousPrefs)
+	return os.WriteFile(prefsPath, content, 0600)
+}
+
+// removeEmptyParents removes directory and its parents, as long as they are empty and inside of root (root itself is never removed).
+func removeEmptyParents(directory string, root string) {
+	for {
+		relative, err := filepath.Rel(root, directory)
+		if err != nil || relative == "." || !isWithin(root, directory) {
+			return
+		}
+		entries, err := os.ReadDir(directory)
+		if err != nil || len(entries) > 0 {
+			return
+		}
+		if os.Remove(directory) != nil {
+			return
+		}
+		directory = filepath.Dir(directory)
+	}
+}
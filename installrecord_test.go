@@ -0,0 +1,109 @@
+package ffcss
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUninstall(t *testing.T) {
+	profile := NewFirefoxProfileFromPath(filepath.Join(testarea, "uninstall", "abcdefgh.uninstalled"))
+	inProfile := func(path string) string {
+		return filepath.Join(profile.Path, path)
+	}
+	os.MkdirAll(inProfile("chrome/icons"), 0700)
+	os.WriteFile(inProfile("chrome/userChrome.css"), []byte("#nav-bar { display: none }"), 0700)
+	os.WriteFile(inProfile("chrome/icons/firefox.svg"), []byte("<svg/>"), 0700)
+	os.WriteFile(inProfile("chrome/handwritten.css"), []byte("/* mine */"), 0700)
+	os.WriteFile(inProfile("user.js"), []byte("user_pref(\"toolkit.legacyUserProfileCustomizations.stylesheets\", true);\nuser_pref(\"svg.context-properties.content.enabled\", true);"), 0700)
+	os.WriteFile(inProfile("prefs.js"), []byte("user_pref(\"browser.startup.homepage_override.mstone\", \"90.0\");\nuser_pref(\"toolkit.legacyUserProfileCustomizations.stylesheets\", false);\n"), 0700)
+
+	record, err := NewInstallRecord(CurrentTheme{Name: "sometheme"}, profile.Path, []string{
+		inProfile("chrome/userChrome.css"),
+		inProfile("chrome/icons/firefox.svg"),
+		inProfile("user.js"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"chrome/icons/firefox.svg", "chrome/userChrome.css", "user.js"}, record.InstalledFiles())
+	assert.Equal(t, []string{"toolkit.legacyUserProfileCustomizations.stylesheets", "svg.context-properties.content.enabled"}, record.Prefs)
+	record, err = profile.SnapshotPrefs(record)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"toolkit.legacyUserProfileCustomizations.stylesheets": "false"}, record.PreviousPrefs)
+	assert.NoError(t, profile.RecordInstallation(record))
+
+	// Firefox copies user.js' entries into prefs.js when it starts
+	os.WriteFile(inProfile("prefs.js"), []byte("user_pref(\"browser.startup.homepage_override.mstone\", \"90.0\");\nuser_pref(\"svg.context-properties.content.enabled\", true);\nuser_pref(\"toolkit.legacyUserProfileCustomizations.stylesheets\", true);\n"), 0700)
+	reinstalled, err := profile.SnapshotPrefs(record)
+	assert.NoError(t, err)
+	assert.Equal(t, record.PreviousPrefs, reinstalled.PreviousPrefs)
+
+	_, err = NewInstallRecord(CurrentTheme{Name: "sometheme"}, profile.Path, []string{filepath.Join(testarea, "elsewhere.css")})
+	assert.Error(t, err)
+
+	actual, found, err := profile.InstallRecord()
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, record, actual)
+
+	os.WriteFile(inProfile("chrome/userChrome.css"), []byte("#nav-bar { display: block }"), 0700)
+	modified, err := profile.ModifiedFiles(record)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"chrome/userChrome.css"}, modified)
+
+	removed, err := profile.Uninstall()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"chrome/icons/firefox.svg", "user.js"}, removed)
+	assert.NoDirExists(t, inProfile("chrome/icons"))
+	assert.FileExists(t, inProfile("chrome/userChrome.css"))
+	assert.FileExists(t, inProfile("chrome/handwritten.css"))
+	prefs, _ := os.ReadFile(inProfile("prefs.js"))
+	assert.Equal(t, "user_pref(\"browser.startup.homepage_override.mstone\", \"90.0\");\nuser_pref(\"toolkit.legacyUserProfileCustomizations.stylesheets\", false);\n", string(prefs))
+
+	_, found, err = profile.InstallRecord()
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	_, err = profile.Uninstall()
+	assert.EqualError(t, err, "no theme was installed on uninstalled (abcdefgh) by ffcss")
+}
+
+func TestUpdateInstallRecord(t *testing.T) {
+	profile := NewFirefoxProfileFromPath(filepath.Join(testarea, "uninstall", "abcdefgh.developed"))
+	inProfile := func(path string) string {
+		return filepath.Join(profile.Path, path)
+	}
+	os.MkdirAll(inProfile("chrome/icons"), 0700)
+	os.WriteFile(inProfile("chrome/userChrome.css"), []byte("#nav-bar { display: none }"), 0700)
+	os.WriteFile(inProfile("chrome/icons/firefox.svg"), []byte("<svg/>"), 0700)
+	assert.NoError(t, profile.UpdateInstallRecord([]string{inProfile("chrome/userChrome.css")}))
+	_, found, _ := profile.InstallRecord()
+	assert.False(t, found)
+
+	record, err := NewInstallRecord(CurrentTheme{Name: "sometheme"}, profile.Path, []string{
+		inProfile("chrome/userChrome.css"),
+		inProfile("chrome/icons/firefox.svg"),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, profile.RecordInstallation(record))
+
+	os.WriteFile(inProfile("chrome/userChrome.css"), []byte("#nav-bar { display: block }"), 0700)
+	os.Remove(inProfile("chrome/icons/firefox.svg"))
+	os.WriteFile(inProfile("user.js"), []byte(`user_pref("toolkit.legacyUserProfileCustomizations.stylesheets", true);`), 0700)
+	assert.NoError(t, profile.UpdateInstallRecord([]string{
+		inProfile("chrome/userChrome.css"),
+		inProfile("chrome/icons/firefox.svg"),
+		inProfile("user.js"),
+	}))
+	updated, _, err := profile.InstallRecord()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"chrome/userChrome.css", "user.js"}, updated.InstalledFiles())
+	assert.Equal(t, []string{"toolkit.legacyUserProfileCustomizations.stylesheets"}, updated.Prefs)
+	modified, err := profile.ModifiedFiles(updated)
+	assert.NoError(t, err)
+	assert.Empty(t, modified)
+
+	assert.Error(t, profile.UpdateInstallRecord([]string{filepath.Join(testarea, "elsewhere.css")}))
+	os.Remove(profile.installRecordPath())
+}
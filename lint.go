@@ -0,0 +1,293 @@
+package ffcss
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bmatcuk/doublestar"
+	"gopkg.in/yaml.v2"
+)
+
+// LintProblem is something wrong with a manifest, see LintManifest.
+type LintProblem struct {
+	File string `json:"file"`
+	// Line is the line of the manifest the problem is on, or 0 if it is not about a specific line
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (problem LintProblem) String() string {
+	if problem.Line == 0 {
+		return fmt.Sprintf("%s: %s", problem.File, problem.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", problem.File, problem.Line, problem.Message)
+}
+
+// lintedOperatingSystems are the values {{os}} can take
+var lintedOperatingSystems = []string{"linux", "macos", "windows"}
+
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.+)$`)
+var yamlUnknownField = regexp.MustCompile(`^field (.+) not found in type .+$`)
+
+// LintManifest checks the manifest at manifestPath without looking at the theme's files:
+// keys must be known (typos like userchrome are reported with their line), keys of older formats must be migrated (see MigrateManifest),
+// and the manifest must be valid for LoadManifest.
+// The loaded manifest is returned if it is valid. The error is only about reading the manifest.
+func LintManifest(manifestPath string) ([]LintProblem, Theme, error) {
+	problems := make([]LintProblem, 0)
+	raw, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return problems, Theme{}, fmt.Errorf("while reading manifest %s: %w", manifestPath, err)
+	}
+
+	// Keys of older formats are reported as such instead of as unknown keys
+	_, changes, _ := MigrateManifest(raw)
+	migratedLines := make(map[int]bool)
+	for _, change := range changes {
+		migratedLines[change.Line] = true
+	}
+
+	strict := NewTheme()
+	err = yaml.UnmarshalStrict(raw, &strict)
+	var typeError *yaml.TypeError
+	if errors.As(err, &typeError) {
+		for _, message := range typeError.Errors {
+			if problem := yamlProblem(manifestPath, message); !migratedLines[problem.Line] {
+				problems = append(problems, problem)
+			}
+		}
+	} else if err != nil {
+		// Syntax errors make the rest of the checks meaningless
+		return append(problems, yamlProblem(manifestPath, err.Error())), Theme{}, nil
+	}
+	for _, change := range changes {
+		problems = append(problems, LintProblem{File: manifestPath, Line: change.Line, Message: change.Message + " (run ffcss migrate to update the manifest)"})
+	}
+
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		return append(problems, LintProblem{File: manifestPath, Message: err.Error()}), Theme{}, nil
+	}
+	return problems, manifest, nil
+}
+
+// yamlProblem turns an error message of the YAML parser into a problem, on the line it refers to.
+func yamlProblem(manifestPath string, message string) LintProblem {
+	problem := LintProblem{File: manifestPath, Message: message}
+	if match := yamlErrorLine.FindStringSubmatch(message); match != nil {
+		problem.Line, _ = strconv.Atoi(match[1])
+		problem.Message = match[2]
+	}
+	if match := yamlUnknownField.FindStringSubmatch(problem.Message); match != nil {
+		problem.Message = fmt.Sprintf("unknown key %q", match[1])
+	}
+	return problem
+}
+
+// LintTheme checks the manifest at manifestPath like LintManifest does, and checks that the theme's files,
+// which are next to the manifest, are there:
+// userChrome, userContent, user.js and assets must resolve to existing files for every variant on every OS,
+// with the conditional blocks (if) that hold for them, and copy from must be a directory that contains the assets.
+// Variants and conditional blocks that are downloaded from elsewhere, or from another branch, tag or commit, are not checked.
+func LintTheme(manifestPath string) ([]LintProblem, error) {
+	problems, manifest, err := LintManifest(manifestPath)
+	if err != nil || manifest.Name() == "" {
+		return problems, err
+	}
+	raw, _ := os.ReadFile(manifestPath)
+	root := filepath.Dir(manifestPath)
+
+	variants := []Variant{{}}
+	if len(manifest.Variants) > 0 {
+		variants = make([]Variant, 0, len(manifest.Variants))
+		for _, name := range manifest.AvailableVariants() {
+			variants = append(variants, manifest.Variants[name])
+		}
+		sort.Slice(variants, func(i, j int) bool { return variants[i].Name < variants[j].Name })
+	}
+
+	// Problems are grouped by message, so that a file missing for every OS is only reported once
+	messages := make([]string, 0)
+	lines := make(map[string]int)
+	combinations := make(map[string][]string)
+	report := func(line int, combination string, message string, args ...interface{}) {
+		message = fmt.Sprintf(message, args...)
+		if _, seen := combinations[message]; !seen {
+			messages = append(messages, message)
+			lines[message] = line
+		}
+		combinations[message] = append(combinations[message], combination)
+	}
+
+	for _, variant := range variants {
+		variantTheme := manifest
+		if variant.Name != "" {
+			var actionsNeeded struct{ switchBranch, reDownload bool }
+			variantTheme, actionsNeeded = manifest.WithVariant(variant)
+			if actionsNeeded.reDownload || actionsNeeded.switchBranch {
+				LogDebug("not checking the files of variant %s, they are downloaded from elsewhere", variant.Name)
+				continue
+			}
+		}
+
+		for _, operatingSystem := range lintedOperatingSystems {
+			combination := "on " + operatingSystem
+			if variant.Name != "" {
+				combination = fmt.Sprintf("variant %s on %s", variant.Name, operatingSystem)
+			}
+			// Components and the Firefox and OS versions are unknown here, so conditions on them are false
+			theme, actionsNeeded := variantTheme.WithConditions(ConditionContext{OS: operatingSystem, Variant: variant.Name})
+			if actionsNeeded.reDownload || actionsNeeded.switchBranch {
+				LogDebug("not checking the files (%s), they are downloaded from elsewhere", combination)
+				continue
+			}
+			theme.DownloadedTo = root
+			for _, file := range []struct{ key, template string }{
+				{"userChrome", theme.UserChrome},
+				{"userContent", theme.UserContent},
+				{"user.js", theme.UserJS},
+			} {
+				if file.template == "" {
+					continue
+				}
+				if variant.Name == "" && strings.Contains(file.template, "{{variant}}") {
+					report(lineOf(raw, file.key, file.template), combination, "%s %q uses {{variant}}, but the theme has no variants", file.key, file.template)
+					continue
+				}
+				rendered := renderFileTemplate(file.template, operatingSystem, variant, theme.OSNames)
+				path, err := SafeJoin(root, rendered)
+				if err != nil {
+					report(lineOf(raw, file.key, file.template), combination, "%s %q is outside of the theme's folder", file.key, file.template)
+				} else if stat, err := os.Stat(path); err != nil || stat.IsDir() {
+					report(lineOf(raw, file.key, file.template), combination, "%s %q: %s does not exist", file.key, file.template, rendered)
+				}
+			}
+			lintAssets(theme, variant, operatingSystem, raw, func(line int, message string, args ...interface{}) {
+				report(line, combination, message, args...)
+			})
+		}
+	}
+
+	for _, message := range messages {
+		problems = append(problems, LintProblem{
+			File:    manifestPath,
+			Line:    lines[message],
+			Message: fmt.Sprintf("%s (%s)", message, strings.Join(combinations[message], ", ")),
+		})
+	}
+	return problems, nil
+}
+
+// lintAssets checks that the theme's assets and copy from resolve, for the variant on operatingSystem.
+func lintAssets(theme Theme, variant Variant, operatingSystem string, raw []byte, report func(line int, message string, args ...interface{})) {
+	if theme.CopyFrom != "" {
+		line := lineOf(raw, "copy from", theme.CopyFrom)
+		rendered := renderFileTemplate(theme.CopyFrom, operatingSystem, variant, theme.OSNames)
+		copyFrom, err := SafeJoin(theme.DownloadedTo, rendered)
+		if err != nil {
+			report(line, "copy from %q is outside of the theme's folder", theme.CopyFrom)
+			return
+		}
+		if stat, err := os.Stat(copyFrom); err != nil || !stat.IsDir() {
+			report(line, "copy from %q: %s is not a directory, no assets can be copied from there", theme.CopyFrom, rendered)
+			return
+		}
+		if len(theme.Assets) == 0 {
+			report(line, "copy from %q is useless, the theme has no assets", theme.CopyFrom)
+		}
+	}
+
+	for _, template := range theme.Assets {
+		line := lineOf(raw, "-", template)
+		glob := renderFileTemplate(template, operatingSystem, variant, theme.OSNames)
+		if _, err := SafeJoin(theme.DownloadedTo, glob); err != nil {
+			report(line, "asset %q is outside of the theme's folder", template)
+			continue
+		}
+		files, err := doublestar.Glob(filepath.Join(theme.DownloadedTo, glob))
+		if err != nil {
+			report(line, "asset %q is not a valid glob pattern: %s", template, err)
+			continue
+		}
+		if len(files) == 0 {
+			report(line, "asset %q matches no files", template)
+			continue
+		}
+		for _, file := range files {
+			if _, err := theme.DestinationPathOfAsset(file, string(filepath.Separator)+"profile", operatingSystem, variant); err != nil {
+				relative, _ := filepath.Rel(theme.DownloadedTo, file)
+				report(line, "asset %q: %s is outside of copy from %q, it can't be copied", template, filepath.ToSlash(relative), theme.CopyFrom)
+				break
+			}
+		}
+	}
+}
+
+// lineOf returns the number of the first line of raw that sets key to value (or, for "-", that has value as a list item),
+// or the first line that sets key if there is none, or 0 if key is not set at all.
+func lineOf(raw []byte, key string, value string) int {
+	firstWithKey := 0
+	for i, line := range strings.Split(string(raw), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if key == "-" {
+			if strings.HasPrefix(trimmed, "-") && strings.Contains(trimmed, value) {
+				return i + 1
+			}
+			continue
+		}
+		if !strings.HasPrefix(trimmed, key+":") {
+			continue
+		}
+		if strings.Contains(trimmed, value) {
+			return i + 1
+		}
+		if firstWithKey == 0 {
+			firstWithKey = i + 1
+		}
+	}
+	return firstWithKey
+}
+
+// LintCatalog checks every manifest of a directory of manifests, such as ~/.config/ffcss/themes or the themes of a registry.
+// Themes' files are not checked, since they are downloaded from elsewhere, but themes must be named after their manifest's file,
+// so that they can be found, and names must be unique.
+func LintCatalog(directory string) ([]LintProblem, error) {
+	problems := make([]LintProblem, 0)
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return problems, fmt.Errorf("while listing %s: %w", directory, err)
+	}
+	manifestFile := regexp.MustCompile(`^(.+)\.ya?ml$`)
+	manifestsByName := make(map[string]string)
+	for _, entry := range entries {
+		match := manifestFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		path := filepath.Join(directory, entry.Name())
+		manifestProblems, manifest, err := LintManifest(path)
+		if err != nil {
+			return problems, err
+		}
+		problems = append(problems, manifestProblems...)
+		if manifest.Name() == "" {
+			continue
+		}
+		if manifest.Name() != strings.ToLower(match[1]) {
+			raw, _ := os.ReadFile(path)
+			problems = append(problems, LintProblem{File: path, Line: lineOf(raw, "name", ""), Message: fmt.Sprintf("the theme is named %q, but its manifest is named %s", manifest.Name(), entry.Name())})
+		}
+		if other, taken := manifestsByName[manifest.Name()]; taken {
+			problems = append(problems, LintProblem{File: path, Message: fmt.Sprintf("%s also has a theme named %q", filepath.Base(other), manifest.Name())})
+			continue
+		}
+		manifestsByName[manifest.Name()] = path
+	}
+	return problems, nil
+}
@@ -0,0 +1,79 @@
+package ffcss
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintTheme(t *testing.T) {
+	root := filepath.Join(testarea, "lint", "theme")
+	os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "chrome", "icons"), 0700)
+	os.WriteFile(filepath.Join(root, "chrome", "icons", "tab.svg"), []byte("<svg/>"), 0600)
+	os.WriteFile(filepath.Join(root, "chrome", "dark.css"), []byte("#nav-bar {}"), 0600)
+	os.WriteFile(filepath.Join(root, "chrome", "light-linux.css"), []byte("#nav-bar {}"), 0600)
+	os.WriteFile(filepath.Join(root, "README.md"), []byte("# Theme"), 0600)
+	manifestPath := filepath.Join(root, "ffcss.yaml")
+	os.WriteFile(manifestPath, []byte(`name: linted
+firefox: 89+
+userchrome: chrome/userChrome.css
+
+variants:
+  dark:
+    userChrome: chrome/dark.css
+  light:
+    userChrome: chrome/light-{{os}}.css
+
+copy from: chrome/
+assets:
+  - chrome/icons/**
+  - README.md
+
+if:
+  variant:dark and windows:
+    userChrome: chrome/dark-windows.css
+`), 0600)
+
+	problems, err := LintTheme(manifestPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []LintProblem{
+		{File: manifestPath, Line: 3, Message: `unknown key "userchrome"`},
+		{File: manifestPath, Line: 14, Message: `asset "README.md": README.md is outside of copy from "chrome/", it can't be copied (variant dark on linux, variant dark on macos, variant dark on windows, variant light on linux, variant light on macos, variant light on windows)`},
+		{File: manifestPath, Line: 18, Message: `userChrome "chrome/dark-windows.css": chrome/dark-windows.css does not exist (variant dark on windows)`},
+		{File: manifestPath, Line: 9, Message: `userChrome "chrome/light-{{os}}.css": chrome/light-macos.css does not exist (variant light on macos)`},
+		{File: manifestPath, Line: 9, Message: `userChrome "chrome/light-{{os}}.css": chrome/light-windows.css does not exist (variant light on windows)`},
+	}, problems)
+
+	os.WriteFile(manifestPath, []byte("name: linted\nfirefox: 95-89\n"), 0600)
+	problems, err = LintTheme(manifestPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []LintProblem{
+		{File: manifestPath, Message: `invalid Firefox version constraint "95-89": lower bound (95.x) is higher than upper bound (89.x)`},
+	}, problems)
+
+	os.WriteFile(manifestPath, []byte("name: linted\nassets: [\n"), 0600)
+	problems, err = LintTheme(manifestPath)
+	assert.NoError(t, err)
+	assert.Len(t, problems, 1)
+	assert.Equal(t, 2, problems[0].Line)
+}
+
+func TestLintCatalog(t *testing.T) {
+	catalog := filepath.Join(testarea, "lint", "catalog")
+	os.RemoveAll(catalog)
+	os.MkdirAll(catalog, 0700)
+	os.WriteFile(filepath.Join(catalog, "first.yaml"), []byte("name: first\ndownload: https://github.com/someone/first\n"), 0600)
+	os.WriteFile(filepath.Join(catalog, "second.yaml"), []byte("name: first\nvariants:\n  dark:\n    usercontent: dark.css\n"), 0600)
+
+	problems, err := LintCatalog(catalog)
+	assert.NoError(t, err)
+	second := filepath.Join(catalog, "second.yaml")
+	assert.Equal(t, []LintProblem{
+		{File: second, Line: 4, Message: `unknown key "usercontent"`},
+		{File: second, Line: 1, Message: `the theme is named "first", but its manifest is named second.yaml`},
+		{File: second, Message: `first.yaml also has a theme named "first"`},
+	}, problems)
+}
@@ -0,0 +1,210 @@
+package ffcss
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// UseLockedRevisions makes Download use the exact revisions recorded in the lock file (see Lock) instead of the latest ones,
+// and fail if they can't be reproduced.
+var UseLockedRevisions = false
+
+// LockedRevision records what was downloaded for a variant of a theme, so that the exact same thing can be downloaded again.
+type LockedRevision struct {
+	// URL and Type are where and how the theme was downloaded from, see ResolveURL
+	URL  string `yaml:"url"`
+	Type string `yaml:"type"`
+	// Commit is the commit the repository was at, for themes downloaded from git repositories
+	Commit string `yaml:"commit,omitempty"`
+	// SHA256 is the checksum of the downloaded file, for themes downloaded from archives or CSS files
+	SHA256 string `yaml:"sha256,omitempty"`
+	// Manifest is the checksum of the theme's manifest
+	Manifest string `yaml:"manifest"`
+}
+
+// Lock maps theme names to the revisions of their variants that were downloaded,
+// by the name of the variant's directory in the cache (RootVariantName for the default variant).
+// It is stored in lock.yaml, in ffcss' configuration folder.
+type Lock map[string]map[string]LockedRevision
+
+// LoadLock reads the lock file. It is empty if the lock file does not exist yet.
+func LoadLock() (Lock, error) {
+	lock := make(Lock)
+	raw, err := os.ReadFile(ConfigDir("lock.yaml"))
+	if os.IsNotExist(err) {
+		return lock, nil
+	}
+	if err != nil {
+		return lock, fmt.Errorf("while reading the lock file: %w", err)
+	}
+	err = yaml.Unmarshal(raw, &lock)
+	if err != nil {
+		return lock, fmt.Errorf("while parsing the lock file %s: %w", ConfigDir("lock.yaml"), err)
+	}
+	return lock, nil
+}
+
+// Write replaces the contents of the lock file with lock.
+func (lock Lock) Write() error {
+	contents, err := yaml.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("while marshaling into YAML: %w", err)
+	}
+	err = os.WriteFile(ConfigDir("lock.yaml"), contents, 0600)
+	if err != nil {
+		return fmt.Errorf("while writing the lock file: %w", err)
+	}
+	return nil
+}
+
+// Revision returns the locked revision of the theme's current variant.
+func (lock Lock) Revision(manifest Theme) (LockedRevision, bool) {
+	revision, found := lock[manifest.Name()][filepath.Base(manifest.DownloadedTo)]
+	return revision, found
+}
+
+// RecordLockedRevision records in the lock file the revision of the theme that was downloaded from URL (of type typ) to its DownloadedTo.
+// The checksum of archives and CSS files is only known right after they are downloaded:
+// when it is not, the one previously recorded for the same URL is kept.
+func RecordLockedRevision(manifest Theme, URL string, typ string) error {
+	lock, err := LoadLock()
+	if err != nil {
+		return err
+	}
+	revision := LockedRevision{URL: URL, Type: typ}
+	revision.Manifest, err = manifestChecksum(manifest)
+	if err != nil {
+		return err
+	}
+	switch typ {
+	case "git":
+		revision.Commit, err = runGit(manifest.DownloadedTo, "rev-parse", "HEAD")
+		if err != nil {
+			return fmt.Errorf("while getting the downloaded commit: %w", err)
+		}
+	case "archive", "website", "css":
+		revision.SHA256 = manifest.downloadedChecksum
+		if previous, found := lock.Revision(manifest); revision.SHA256 == "" && found && previous.URL == URL {
+			revision.SHA256 = previous.SHA256
+		}
+	}
+
+	if lock[manifest.Name()] == nil {
+		lock[manifest.Name()] = make(map[string]LockedRevision)
+	}
+	lock[manifest.Name()][filepath.Base(manifest.DownloadedTo)] = revision
+	LogDebug("locking %s [%s] to %#v", manifest.Name(), filepath.Base(manifest.DownloadedTo), revision)
+	return lock.Write()
+}
+
+// lockedRevision returns the locked revision of the theme's current variant, or an error if it was never locked.
+func lockedRevision(manifest Theme) (LockedRevision, error) {
+	lock, err := LoadLock()
+	if err != nil {
+		return LockedRevision{}, err
+	}
+	revision, found := lock.Revision(manifest)
+	if !found {
+		return revision, fmt.Errorf("%s (variant %s) is not in the lock file, use it without --locked first", manifest.Name(), filepath.Base(manifest.DownloadedTo))
+	}
+	return revision, nil
+}
+
+// checkoutLockedCommit checks out the locked commit of the theme in the repository at directory,
+// fetching it if the repository does not have it.
+func checkoutLockedCommit(manifest Theme, directory string) error {
+	revision, err := lockedRevision(manifest)
+	if err != nil {
+		return err
+	}
+	if revision.Commit == "" {
+		return fmt.Errorf("%s was not locked to a commit (it was downloaded from %s)", manifest.Name(), revision.URL)
+	}
+	if _, err := runGit(directory, "cat-file", "-e", revision.Commit+"^{commit}"); err != nil {
+		LogDebug("fetching locked commit %s", revision.Commit)
+		_, err = runGit(directory, "fetch", "--quiet", "origin", revision.Commit)
+		if err != nil {
+			return fmt.Errorf("locked commit %s of %s can't be found anymore: %w", revision.Commit, manifest.Name(), err)
+		}
+	}
+	LogDebug("switching to locked commit %s", revision.Commit)
+	_, err = runGit(directory, "checkout", "--quiet", revision.Commit)
+	return err
+}
+
+// verifyLockedChecksum returns an error if checksum, the one of the file that was just downloaded for the theme, is not the locked one.
+func verifyLockedChecksum(manifest Theme, checksum string) error {
+	revision, err := lockedRevision(manifest)
+	if err != nil {
+		return err
+	}
+	if revision.SHA256 == "" {
+		return fmt.Errorf("%s has no locked checksum, use it without --locked first", manifest.Name())
+	}
+	if revision.SHA256 != checksum {
+		return fmt.Errorf("the file downloaded for %s changed since it was locked: its SHA-256 checksum is %s instead of %s", manifest.Name(), checksum, revision.SHA256)
+	}
+	return nil
+}
+
+// verifyLockedManifest returns an error if the theme's manifest is not the one that was locked.
+func verifyLockedManifest(manifest Theme) error {
+	revision, err := lockedRevision(manifest)
+	if err != nil {
+		return err
+	}
+	checksum, err := manifestChecksum(manifest)
+	if err != nil {
+		return err
+	}
+	if revision.Manifest != checksum {
+		return fmt.Errorf("the manifest of %s changed since it was locked", manifest.Name())
+	}
+	return nil
+}
+
+// manifestChecksum returns the SHA-256 checksum of the theme's manifest.
+func manifestChecksum(manifest Theme) (string, error) {
+	content, err := manifest.GenerateManifest()
+	if err != nil {
+		return "", fmt.Errorf("while generating the manifest of %s: %w", manifest.Name(), err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(content))), nil
+}
+
+// fileChecksum returns the SHA-256 checksum of the file at path.
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("while opening %s: %w", path, err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("while reading %s: %w", path, err)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// replaceWithLockedDownload checks, with UseLockedRevisions, that the file that was just downloaded for the theme is the locked one,
+// and removes the theme's previous copy from the cache, to replace it with the download.
+func replaceWithLockedDownload(manifest Theme) error {
+	if !UseLockedRevisions {
+		return nil
+	}
+	err := verifyLockedChecksum(manifest, manifest.downloadedChecksum)
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(manifest.DownloadedTo)
+	if err != nil {
+		return fmt.Errorf("while removing previous copy at %s: %w", manifest.DownloadedTo, err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package ffcss
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockedGitRevision(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	defer func() { UseLockedRevisions = false }()
+	upstream := filepath.Join(testarea, "lockable-upstream")
+	os.RemoveAll(upstream)
+	os.RemoveAll(CacheDir("lockable"))
+	os.MkdirAll(upstream, 0700)
+	os.WriteFile(filepath.Join(upstream, "ffcss.yaml"), []byte("name: lockable\nuserChrome: userChrome.css\n"), 0600)
+	os.WriteFile(filepath.Join(upstream, "userChrome.css"), []byte("#nav-bar {}"), 0600)
+	gitIn(t, upstream, "init", "--quiet")
+	gitIn(t, upstream, "add", ".")
+	gitIn(t, upstream, "commit", "--quiet", "-m", "Initial commit")
+
+	manifest, err := Download(upstream, "git")
+	assert.NoError(t, err)
+	lock, err := LoadLock()
+	assert.NoError(t, err)
+	locked, found := lock.Revision(manifest)
+	assert.True(t, found)
+	head, _ := runGit(upstream, "rev-parse", "HEAD")
+	assert.Equal(t, LockedRevision{
+		URL:      upstream,
+		Type:     "git",
+		Commit:   head,
+		Manifest: locked.Manifest,
+	}, locked)
+	assert.Len(t, locked.Manifest, 64)
+
+	// A new commit upstream is not installed with the lock
+	os.WriteFile(filepath.Join(upstream, "userChrome.css"), []byte("#nav-bar { display: none; }"), 0600)
+	gitIn(t, upstream, "commit", "--quiet", "-am", "Hide the navigation bar")
+	UseLockedRevisions = true
+	manifest, err = Download(upstream, "git")
+	assert.NoError(t, err)
+	content, _ := os.ReadFile(filepath.Join(manifest.DownloadedTo, "userChrome.css"))
+	assert.Equal(t, "#nav-bar {}", string(content))
+
+	// The lock can't be reproduced anymore once the manifest changed, e.g. in the catalog
+	manifest.raw = "name: lockable\nuserChrome: userChrome.css\ndescription: changed\n"
+	_, err = Download(upstream, "git", manifest)
+	assert.EqualError(t, err, "the manifest of lockable changed since it was locked")
+
+	_, err = Download(upstream, "local")
+	assert.EqualError(t, err, upstream+" is a local theme, it can't be used with --locked")
+}
+
+func TestLockedChecksum(t *testing.T) {
+	defer func() { UseLockedRevisions = false }()
+	css := "#nav-bar { display: none; }"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		w.Write([]byte(css))
+	}))
+	defer server.Close()
+	os.RemoveAll(CacheDir("lockedcss"))
+
+	manifest, err := Download(server.URL+"/lockedcss.css", "css")
+	assert.NoError(t, err)
+	lock, _ := LoadLock()
+	locked, found := lock.Revision(manifest)
+	assert.True(t, found)
+	assert.Equal(t, "css", locked.Type)
+	checksum, _ := fileChecksum(filepath.Join(manifest.DownloadedTo, "lockedcss.css"))
+	assert.Equal(t, checksum, locked.SHA256)
+
+	// Downloading the same file again with the lock replaces the cached copy
+	UseLockedRevisions = true
+	_, err = Download(server.URL+"/lockedcss.css", "css")
+	assert.NoError(t, err)
+
+	// But a file that changed is refused, and the cached copy is kept
+	css = "#nav-bar { display: block; }"
+	_, err = Download(server.URL+"/lockedcss.css", "css")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "the file downloaded for lockedcss changed since it was locked")
+	content, _ := os.ReadFile(filepath.Join(manifest.DownloadedTo, "lockedcss.css"))
+	assert.Equal(t, "#nav-bar { display: none; }", string(content))
+
+	_, err = Download(server.URL+"/neverlocked.css", "css")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "neverlocked (variant _) is not in the lock file, use it without --locked first")
+}
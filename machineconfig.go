@@ -0,0 +1,220 @@
+package ffcss
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// MachineConfig describes the state profiles should be in: which theme each profile gets, see ffcss apply.
+// It is read from profiles.yaml in ffcss' configuration folder (see MachineConfigPath), or from any other file.
+type MachineConfig struct {
+	// Profiles maps a profile's name, ID or path to the state it should be in.
+	Profiles map[string]ProfileConfig
+}
+
+// ProfileConfig is the state a profile should be in.
+type ProfileConfig struct {
+	// Theme is the theme to use, as given to ffcss use. When it is empty, the profile's theme is uninstalled.
+	Theme      string
+	Variant    string   `yaml:",omitempty"`
+	Components []string `yaml:",omitempty"`
+	// Prefs are configuration entries to set in addition to the theme's
+	Prefs Config `yaml:",omitempty"`
+}
+
+// CurrentTheme returns how the profile's theme is recorded in currently.yaml once the profile is in that state.
+func (config ProfileConfig) CurrentTheme() CurrentTheme {
+	return CurrentTheme{
+		Name:       config.Theme,
+		Variant:    config.Variant,
+		Components: config.Components,
+		Prefs:      config.Prefs,
+	}
+}
+
+// MachineConfigPath returns the path of the default machine configuration file.
+func MachineConfigPath() string {
+	return ConfigDir("profiles.yaml")
+}
+
+// LoadMachineConfig reads the machine configuration file at path.
+func LoadMachineConfig(path string) (MachineConfig, error) {
+	var config MachineConfig
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("while reading %s: %w", path, err)
+	}
+	err = yaml.Unmarshal(raw, &config)
+	if err != nil {
+		return config, fmt.Errorf("while parsing %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ConfiguredProfile is a profile of a MachineConfig, along with the state it should be in.
+type ConfiguredProfile struct {
+	// Reference is how the profile is referred to in the configuration file
+	Reference string
+	Profile   FirefoxProfile
+	Config    ProfileConfig
+}
+
+// ConfiguredProfiles finds the profiles the configuration refers to, sorted by how they are referred to.
+// Profiles can be referred to by their name or ID (see Profiles), or by their path, which may be relative to profilesDir.
+func (config MachineConfig) ConfiguredProfiles(profilesDir string, browser string) ([]ConfiguredProfile, error) {
+	references := make([]string, 0, len(config.Profiles))
+	for reference := range config.Profiles {
+		references = append(references, reference)
+	}
+	sort.Strings(references)
+
+	profiles, err := SelectableProfiles(profilesDir, browser)
+	if err != nil {
+		LogDebug("couldn't get profiles, only using paths: %s", err)
+	}
+	configured := make([]ConfiguredProfile, 0, len(references))
+	for _, reference := range references {
+		profile, err := findProfileByReference(reference, profiles, profilesDir, browser)
+		if err != nil {
+			return configured, err
+		}
+		configured = append(configured, ConfiguredProfile{Reference: reference, Profile: profile, Config: config.Profiles[reference]})
+	}
+	return configured, nil
+}
+
+// findProfileByReference returns the profile of profiles named, identified or stored at reference.
+func findProfileByReference(reference string, profiles []FirefoxProfile, profilesDir string, browser string) (FirefoxProfile, error) {
+	matching := make([]FirefoxProfile, 0)
+	for _, profile := range profiles {
+		if profile.Name == reference || profile.ID == reference || profile.FullName() == reference {
+			matching = append(matching, profile)
+		}
+	}
+	if len(matching) == 1 {
+		return matching[0], nil
+	}
+	if len(matching) > 1 {
+		return FirefoxProfile{}, fmt.Errorf("%q refers to several profiles, use the path of the one you mean", reference)
+	}
+
+	paths := []string{reference}
+	if profilesDir != "" && !filepath.IsAbs(reference) {
+		paths = append(paths, filepath.Join(profilesDir, reference))
+	}
+	for _, path := range paths {
+		if stat, err := os.Stat(path); err == nil && stat.IsDir() {
+			selected, err := SelectProfiles([]string{path}, profilesDir, browser, false, false)
+			if err != nil || len(selected) == 0 {
+				return FirefoxProfile{}, fmt.Errorf("while getting profile %q: %w", reference, err)
+			}
+			return selected[0], nil
+		}
+	}
+	return FirefoxProfile{}, fmt.Errorf("no profile is named %q, has that ID or is stored there", reference)
+}
+
+// Actions of a ProfileChange
+const (
+	ProfileUpToDate  = "up to date"
+	ProfileInstall   = "install"
+	ProfileSwitch    = "switch"
+	ProfileReinstall = "reinstall"
+	ProfileUninstall = "uninstall"
+)
+
+// ProfileChange is what needs to be done to bring a profile to the state described by its ProfileConfig.
+type ProfileChange struct {
+	ConfiguredProfile
+	// Action is one of ProfileUpToDate, ProfileInstall, ProfileSwitch, ProfileReinstall or ProfileUninstall
+	Action string
+	// Current is the theme that is currently applied to the profile, if any
+	Current CurrentTheme
+	// Reason explains why the theme needs to be reinstalled
+	Reason string
+}
+
+// PlanChange determines what needs to be done to bring the profile to the state it should be in.
+// A profile is up to date if its current theme, variant, components and prefs are the configured ones,
+// and the files installed by ffcss are still there, untouched.
+func (configured ConfiguredProfile) PlanChange() (ProfileChange, error) {
+	change := ProfileChange{ConfiguredProfile: configured, Action: ProfileUpToDate}
+	currentThemes, err := CurrentThemeDetailsByProfile()
+	if err != nil {
+		return change, err
+	}
+	current, hasCurrent := currentThemes[configured.Profile.FullName()]
+	record, installed, err := configured.Profile.InstallRecord()
+	if err != nil {
+		return change, err
+	}
+	if hasCurrent {
+		change.Current = current
+	}
+
+	desired := configured.Config
+	switch {
+	case desired.Theme == "":
+		if installed {
+			change.Action = ProfileUninstall
+		}
+		return change, nil
+	case !hasCurrent || !installed:
+		change.Action = ProfileInstall
+		return change, nil
+	case current.Name != desired.Theme:
+		change.Action = ProfileSwitch
+		return change, nil
+	}
+
+	reasons := make([]string, 0)
+	if current.Variant != desired.Variant {
+		reasons = append(reasons, "the variant changed")
+	}
+	if !sameStrings(current.Components, desired.Components) {
+		reasons = append(reasons, "the components changed")
+	}
+	if !current.Prefs.Equal(desired.Prefs) {
+		reasons = append(reasons, "the prefs changed")
+	}
+	modified, err := configured.Profile.ModifiedFiles(record)
+	if err != nil {
+		return change, fmt.Errorf("while checking for modified files: %w", err)
+	}
+	if len(modified) > 0 {
+		reasons = append(reasons, "installed files were modified")
+	}
+	for _, file := range record.InstalledFiles() {
+		if _, err := os.Stat(filepath.Join(configured.Profile.Path, filepath.FromSlash(file))); os.IsNotExist(err) {
+			reasons = append(reasons, "installed files were removed")
+			break
+		}
+	}
+	if len(reasons) > 0 {
+		change.Action = ProfileReinstall
+		change.Reason = strings.Join(reasons, ", ")
+	}
+	return change, nil
+}
+
+// sameStrings returns true if a and b contain the same strings, in any order.
+func sameStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]string{}, a...)
+	sortedB := append([]string{}, b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+	return true
+}
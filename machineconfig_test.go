@@ -0,0 +1,102 @@
+package ffcss
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMachineConfig(t *testing.T) {
+	os.MkdirAll(filepath.Join(testarea, "machineconfig"), 0700)
+	path := filepath.Join(testarea, "machineconfig", "profiles.yaml")
+	os.WriteFile(path, []byte(`profiles:
+  work:
+    theme: materialfox
+    variant: dark
+    components: [Sidebar Static]
+    prefs:
+      browser.compactmode.show: true
+  abcdefgh.personal:
+    theme: ""
+`), 0600)
+
+	config, err := LoadMachineConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, MachineConfig{Profiles: map[string]ProfileConfig{
+		"work": {
+			Theme:      "materialfox",
+			Variant:    "dark",
+			Components: []string{"Sidebar Static"},
+			Prefs:      Config{"browser.compactmode.show": true},
+		},
+		"abcdefgh.personal": {},
+	}}, config)
+
+	profilesDir := filepath.Join(testarea, "machineconfig", "profiles")
+	os.MkdirAll(filepath.Join(profilesDir, "abcdefgh.personal"), 0700)
+	os.MkdirAll(filepath.Join(profilesDir, "ijklmnop.work"), 0700)
+	configured, err := config.ConfiguredProfiles(profilesDir, "")
+	assert.NoError(t, err)
+	assert.Len(t, configured, 2)
+	assert.Equal(t, "abcdefgh.personal", configured[0].Reference)
+	assert.Equal(t, filepath.Join(profilesDir, "abcdefgh.personal"), configured[0].Profile.Path)
+	assert.Equal(t, "work", configured[1].Reference)
+	assert.Equal(t, filepath.Join(profilesDir, "ijklmnop.work"), configured[1].Profile.Path)
+
+	config.Profiles["nope"] = ProfileConfig{}
+	_, err = config.ConfiguredProfiles(profilesDir, "")
+	assert.EqualError(t, err, `no profile is named "nope", has that ID or is stored there`)
+}
+
+func TestPlanChange(t *testing.T) {
+	profile := NewFirefoxProfileFromPath(filepath.Join(testarea, "planchange", "abcdefgh.planned"))
+	os.MkdirAll(filepath.Join(profile.Path, "chrome"), 0700)
+	desired := ProfileConfig{Theme: "materialfox", Variant: "dark", Prefs: Config{"browser.compactmode.show": true}}
+	configured := ConfiguredProfile{Reference: "planned", Profile: profile, Config: desired}
+
+	change, err := configured.PlanChange()
+	assert.NoError(t, err)
+	assert.Equal(t, ProfileInstall, change.Action)
+
+	os.WriteFile(filepath.Join(profile.Path, "chrome", "userChrome.css"), []byte("#nav-bar {}"), 0600)
+	record, err := NewInstallRecord(desired.CurrentTheme(), profile.Path, []string{filepath.Join(profile.Path, "chrome", "userChrome.css")})
+	assert.NoError(t, err)
+	assert.NoError(t, profile.RecordInstallation(record))
+	assert.NoError(t, profile.RegisterCurrentTheme(desired.CurrentTheme()))
+	change, err = configured.PlanChange()
+	assert.NoError(t, err)
+	assert.Equal(t, ProfileUpToDate, change.Action)
+
+	configured.Config.Variant = "light"
+	configured.Config.Prefs = Config{}
+	change, err = configured.PlanChange()
+	assert.NoError(t, err)
+	assert.Equal(t, ProfileReinstall, change.Action)
+	assert.Equal(t, "the variant changed, the prefs changed", change.Reason)
+
+	configured.Config = desired
+	os.WriteFile(filepath.Join(profile.Path, "chrome", "userChrome.css"), []byte("#nav-bar { color: red }"), 0600)
+	change, err = configured.PlanChange()
+	assert.NoError(t, err)
+	assert.Equal(t, ProfileReinstall, change.Action)
+	assert.Equal(t, "installed files were modified", change.Reason)
+
+	configured.Config = ProfileConfig{Theme: "simplefox"}
+	change, err = configured.PlanChange()
+	assert.NoError(t, err)
+	assert.Equal(t, ProfileSwitch, change.Action)
+	assert.Equal(t, "materialfox", change.Current.Name)
+
+	configured.Config = ProfileConfig{}
+	change, err = configured.PlanChange()
+	assert.NoError(t, err)
+	assert.Equal(t, ProfileUninstall, change.Action)
+
+	_, err = profile.Uninstall()
+	assert.NoError(t, err)
+	change, err = configured.PlanChange()
+	assert.NoError(t, err)
+	assert.Equal(t, ProfileUpToDate, change.Action)
+}
@@ -1,10 +1,13 @@
 package ffcss
 
 import (
+	"crypto/sha1"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/charmbracelet/glamour"
@@ -19,16 +22,15 @@ var ThemeCompatWarningShown = VersionMajor > 0
 // Config represents a configuration map in a manifest, representing a set of values for the about:config page in Firefox
 type Config map[string]interface{}
 
-// Equal returns true if the config c has all of its values equal to the other Config.
+// Equal returns true if the config c has the same keys as the other Config, with the same values.
 func (c Config) Equal(other Config) bool {
-	// XXX: this is not efficient AT ALL. It sucks. Change this.
-	for k, v := range c {
-		if v != other[k] {
-			return false
-		}
+	if len(c) != len(other) {
+		return false
 	}
-	for k, v := range other {
-		if other[k] != v {
+	for k, v := range c {
+		otherValue, found := other[k]
+		// Values can be lists or maps, which can't be compared with !=
+		if !found || !reflect.DeepEqual(v, otherValue) {
 			return false
 		}
 	}
@@ -41,6 +43,8 @@ type FileTemplate = string
 // Variant represents a theme's variant. Most of the properties are identical to Theme's, because they overwrite the default values.
 type Variant struct {
 	// Properties exclusive to variants
+
+	// Name is the variant's name, which is its key in variants (or its condition, in if)
 	Name string
 
 	// Properties that modify the "default variant"
@@ -59,7 +63,8 @@ type Variant struct {
 		Before string
 		After  string
 	}
-	Message string
+	Message       string
+	Modifications []Modification `yaml:",omitempty"`
 }
 
 // Theme represents a FirefoxCSS theme, read from a manifest YAML file. (See LoadManifest).
@@ -68,41 +73,77 @@ type Theme struct {
 	currentVariantName string `yaml:"-"` // Used to construct the directory where the theme will be cached
 	raw                string `yaml:"-"` // Contains the raw yaml file contents
 	DownloadedTo       string `yaml:"-"` // Stores the path to the directory where the theme is cached. Set by .Download().
+	downloadedChecksum string `yaml:"-"` // SHA-256 checksum of the archive or CSS file the theme was just downloaded from, see RecordLockedRevision
 
 	// Top-level (non-variant-modifiable)
-	FfcssVersion             int                      `yaml:"ffcss"`
+
+	// FfcssVersion is the major version of ffcss the manifest was written for
+	FfcssVersion int `yaml:"ffcss"`
+	// FirefoxVersion is the range of Firefox versions the theme supports, such as 89+ or 78-91
 	FirefoxVersion           string                   `yaml:"firefox,omitempty"`
 	FirefoxVersionConstraint FirefoxVersionConstraint `yaml:"-"`
-	ExplicitName             string                   `yaml:"name"`
-	Author                   string                   `yaml:"by"`
-	Description              string
-	Variants                 map[string]Variant
-	OSNames                  map[string]string `yaml:"os,omitempty"`
+	// ExplicitName is the theme's name. If it is not set, the theme is named after its manifest's file
+	ExplicitName string `yaml:"name"`
+	// Extends is the manifest this one inherits from: the name of a theme of the catalog, a URL, or a path relative to this manifest.
+	// Only what differs from it needs to be set
+	Extends string `yaml:",omitempty"`
+	// Author is who made the theme. Their name is guessed from the download URL if it is not set
+	Author string `yaml:"by"`
+	// Description is a short description of the theme, shown before installing it
+	Description string
+	// Tags are keywords that help finding the theme with ffcss search
+	Tags []string `yaml:",omitempty"`
+	// Variants are variations of the theme that users choose from, by name. They override the theme's properties
+	Variants map[string]Variant
+	// Components are optional parts of the theme that users can enable independently, by name
+	Components map[string]Component `yaml:",omitempty"`
+	// Conditions override the theme's properties when their condition (such as os:windows or variant:dark) is true
+	Conditions map[string]Variant `yaml:"if,omitempty"`
+	// OSNames maps operating systems (linux, macos or windows) to what {{os}} is replaced with
+	OSNames map[string]string `yaml:"os,omitempty"`
 
 	// Override-able by variants
-	DownloadAt  string `yaml:"download"`
-	Branch      string
-	Commit      string `yaml:",omitempty"`
-	Tag         string `yaml:",omitempty"`
-	Config      Config
-	UserChrome  FileTemplate `yaml:"userChrome"`
+
+	// DownloadAt is the URL of the theme's git repository, archive or CSS file
+	DownloadAt string `yaml:"download"`
+	// Branch is the git branch to download
+	Branch string
+	// Commit is the SHA of the git commit to download
+	Commit string `yaml:",omitempty"`
+	// Tag is the git tag to download
+	Tag string `yaml:",omitempty"`
+	// Config maps about:config preferences to the values written to the profile's user.js
+	Config Config
+	// UserChrome is the path of the file copied to chrome/userChrome.css
+	UserChrome FileTemplate `yaml:"userChrome"`
+	// UserContent is the path of the file copied to chrome/userContent.css
 	UserContent FileTemplate `yaml:"userContent"`
-	UserJS      FileTemplate `yaml:"user.js"`
-	Assets      []FileTemplate
-	CopyFrom    string `yaml:"copy from,omitempty"`
-	Addons      []string
-	Run         struct {
+	// UserJS is the path of the file copied to user.js, before the preferences of config
+	UserJS FileTemplate `yaml:"user.js"`
+	// Assets are glob patterns of other files copied to chrome/
+	Assets []FileTemplate
+	// CopyFrom is the folder assets are copied from, so that it is not part of their path in chrome/
+	CopyFrom string `yaml:"copy from,omitempty"`
+	// Addons are the URLs of add-ons to install from addons.mozilla.org
+	Addons []string
+	// Run has shell commands to run in the theme's folder
+	Run struct {
+		// Before is run before installing the theme
 		Before string
-		After  string
+		// After is run after installing the theme
+		After string
 	}
+	// Message is shown to the user after installing the theme, rendered as markdown
 	Message string
+	// Modifications are text edits done to the installed files
+	Modifications []Modification `yaml:",omitempty"`
 }
 
 // ManifestKeyGroupsStarts specifies at which keys a group of related keys starts.
 // This assumes that the YAML keys are displayed/written in the order they are defined in (see Theme).
 //
 // It is used to add blank lines in generated manifests: for example, above the 'variant' key, a blank line should be added to add grouping.
-var ManifestKeyGroupsStarts = [...]string{"name", "variants", "os", "download", "config", "run", "message"}
+var ManifestKeyGroupsStarts = [...]string{"name", "variants", "components", "if", "os", "download", "config", "run", "message"}
 
 // NewTheme creates a new Theme with vital defaults (namely the config entry to enable CSS customization of Firefox).
 func NewTheme() Theme {
@@ -116,6 +157,10 @@ func NewTheme() Theme {
 }
 
 // LoadManifest loads a ffcss.yaml file into a Theme object.
+// Manifests written for older versions of ffcss are migrated to the current format first, see MigrateManifest.
+// Manifests that extend another one are merged with it, see inheritManifest.
+// Extending a manifest by URL downloads it the first time it is needed, so loading a manifest (or a catalog, see LoadCatalog)
+// can make network requests. Downloaded manifests are cached, until ClearExtendedManifests is called.
 func LoadManifest(manifestPath string) (manifest Theme, err error) {
 	raw, err := os.ReadFile(manifestPath)
 	if err != nil {
@@ -124,7 +169,19 @@ func LoadManifest(manifestPath string) (manifest Theme, err error) {
 	}
 	manifest = NewTheme()
 	manifest.raw = string(raw)
-	err = yaml.Unmarshal(raw, &manifest)
+	migrated, changes, migrationErr := MigrateManifest(raw)
+	if migrationErr != nil {
+		LogDebug("could not migrate %s: %s", manifestPath, migrationErr)
+	} else if len(changes) > 0 {
+		LogDebug("%s is written for an older ffcss, it was migrated: %v", manifestPath, changes)
+	}
+	location, _ := filepath.Abs(manifestPath)
+	migrated, err = inheritManifest(location, migrated, []string{})
+	if err != nil {
+		err = fmt.Errorf("while loading the manifest %s extends: %w", manifestPath, err)
+		return
+	}
+	err = yaml.Unmarshal(migrated, &manifest)
 
 	if manifest.FfcssVersion < 0 {
 		err = fmt.Errorf("ffcss version cannot be negative but is set to %d", manifest.FfcssVersion)
@@ -161,6 +218,48 @@ func LoadManifest(manifestPath string) (manifest Theme, err error) {
 		variantWithName.Name = name
 		manifest.Variants[name] = variantWithName
 	}
+
+	for name, component := range manifest.Components {
+		componentWithName := component
+		componentWithName.Name = name
+		manifest.Components[name] = componentWithName
+	}
+	for name, component := range manifest.Components {
+		for _, incompatible := range component.IncompatibleWith {
+			if _, found := manifest.Component(incompatible); !found {
+				err = fmt.Errorf("component %q is declared incompatible with %q, which does not exist", name, incompatible)
+				return
+			}
+		}
+		for _, modification := range component.Modifications {
+			if modification.In == "" {
+				err = fmt.Errorf("component %q has a modification with no file to modify (in)", name)
+				return
+			}
+		}
+	}
+	for expression, conditional := range manifest.Conditions {
+		condition, parseErr := ParseCondition(expression)
+		if parseErr != nil {
+			err = fmt.Errorf("invalid condition %q: %w", expression, parseErr)
+			return
+		}
+		for _, component := range condition.references("component") {
+			if _, found := manifest.Component(component); !found {
+				err = fmt.Errorf("condition %q refers to component %q, which does not exist", expression, component)
+				return
+			}
+		}
+		for _, variant := range condition.references("variant") {
+			if _, found := manifest.Variants[variant]; !found {
+				err = fmt.Errorf("condition %q refers to variant %q, which does not exist", expression, variant)
+				return
+			}
+		}
+		conditional.Name = expression
+		manifest.Conditions[expression] = conditional
+	}
+
 	manifest.currentVariantName = RootVariantName // ensure the current variant's name wasn't manipulated by the YAML unmarshaling
 	if err != nil {
 		err = fmt.Errorf("while parsing manifest %s: %w", manifestPath, err)
@@ -185,49 +284,101 @@ func LoadManifest(manifestPath string) (manifest Theme, err error) {
 // Some variants change the git branch, the entire repository or other settings that require external actions.
 // Those are returned in actionsNeeded as a struct of booleans with descriptive field names.
 func (t Theme) WithVariant(variant Variant) (newTheme Theme, actionsNeeded struct{ switchBranch, reDownload bool }) {
+	newTheme, actionsNeeded = t.withOverrides(variant)
+	newTheme.currentVariantName = variant.Name
+	if actionsNeeded.reDownload || actionsNeeded.switchBranch {
+		newTheme.DownloadedTo = CacheDir(newTheme.Name(), newTheme.currentVariantName)
+	}
+	return newTheme, actionsNeeded
+}
+
+// withOverrides returns the theme with the values set in overrides replacing its own, see WithVariant.
+// Config entries are combined, and modifications are added after the theme's.
+func (t Theme) withOverrides(overrides Variant) (newTheme Theme, actionsNeeded struct{ switchBranch, reDownload bool }) {
 	// TODO might clean this up with reflection, selecting fields that are both in Manifest & Variant
 	newTheme = t
-	newTheme.currentVariantName = variant.Name
-	if variant.UserChrome != "" {
-		newTheme.UserChrome = variant.UserChrome
+	if overrides.UserChrome != "" {
+		newTheme.UserChrome = overrides.UserChrome
+	}
+	if overrides.UserContent != "" {
+		newTheme.UserContent = overrides.UserContent
 	}
-	if variant.UserContent != "" {
-		newTheme.UserContent = variant.UserContent
+	if overrides.UserJS != "" {
+		newTheme.UserJS = overrides.UserJS
 	}
-	if variant.UserJS != "" {
-		newTheme.UserJS = variant.UserJS
+	if overrides.Message != "" {
+		newTheme.Message = overrides.Message
 	}
-	if variant.Message != "" {
-		newTheme.Message = variant.Message
+	if len(overrides.Assets) > 0 {
+		newTheme.Assets = overrides.Assets
 	}
-	if len(variant.Assets) > 0 {
-		newTheme.Assets = variant.Assets
+	if len(overrides.Addons) > 0 {
+		newTheme.Addons = overrides.Addons
 	}
-	if variant.DownloadAt != "" {
+	if overrides.DownloadAt != "" {
 		actionsNeeded.reDownload = true
-		newTheme.DownloadAt = variant.DownloadAt
+		newTheme.DownloadAt = overrides.DownloadAt
 	}
-	if variant.Branch != "" {
+	if overrides.Branch != "" {
 		actionsNeeded.switchBranch = true
-		newTheme.Branch = variant.Branch
+		newTheme.Branch = overrides.Branch
 	}
-	if variant.Commit != "" {
-		newTheme.Commit = variant.Commit
+	if overrides.Commit != "" {
+		newTheme.Commit = overrides.Commit
 	}
-	if variant.Tag != "" {
-		newTheme.Tag = variant.Tag
+	if overrides.Tag != "" {
+		newTheme.Tag = overrides.Tag
 	}
-	if variant.Run.Before != "" {
-		newTheme.Run.Before = variant.Run.Before
+	if overrides.Run.Before != "" {
+		newTheme.Run.Before = overrides.Run.Before
 	}
-	if variant.Run.After != "" {
-		newTheme.Run.After = variant.Run.After
+	if overrides.Run.After != "" {
+		newTheme.Run.After = overrides.Run.After
+	}
+	// Copy the config so that the original theme's is not modified
+	newTheme.Config = make(Config, len(t.Config)+len(overrides.Config))
+	for key, val := range t.Config {
+		newTheme.Config[key] = val
 	}
-	for key, val := range variant.Config {
+	for key, val := range overrides.Config {
 		newTheme.Config[key] = val
 	}
+	newTheme.Modifications = append(append([]Modification{}, t.Modifications...), overrides.Modifications...)
+	return newTheme, actionsNeeded
+}
+
+// WithConditions returns the theme with the values of every conditional block (the "if" entry in the manifest)
+// whose condition holds in context applied, the same way variants are (see WithVariant).
+// Conditional blocks are applied in alphabetical order of their conditions.
+func (t Theme) WithConditions(context ConditionContext) (newTheme Theme, actionsNeeded struct{ switchBranch, reDownload bool }) {
+	newTheme = t
+	expressions := make([]string, 0, len(t.Conditions))
+	for expression := range t.Conditions {
+		expressions = append(expressions, expression)
+	}
+	sort.Strings(expressions)
+
+	fulfilled := make([]string, 0)
+	for _, expression := range expressions {
+		condition, err := ParseCondition(expression)
+		if err != nil {
+			LogWarning("ignoring invalid condition %q: %s", expression, err)
+			continue
+		}
+		if !condition.Evaluate(context) {
+			continue
+		}
+		LogDebug("condition %q holds", expression)
+		fulfilled = append(fulfilled, expression)
+		var conditionActions struct{ switchBranch, reDownload bool }
+		newTheme, conditionActions = newTheme.withOverrides(t.Conditions[expression])
+		actionsNeeded.reDownload = actionsNeeded.reDownload || conditionActions.reDownload
+		actionsNeeded.switchBranch = actionsNeeded.switchBranch || conditionActions.switchBranch
+	}
 	if actionsNeeded.reDownload || actionsNeeded.switchBranch {
-		newTheme.DownloadedTo = CacheDir(newTheme.Name(), newTheme.currentVariantName)
+		// Conditional blocks that change what gets downloaded are cached separately from the variant itself
+		hash := sha1.Sum([]byte(strings.Join(fulfilled, "\n")))
+		newTheme.DownloadedTo = CacheDir(newTheme.Name(), fmt.Sprintf("%s@%x", newTheme.currentVariantName, hash[:4]))
 	}
 	return newTheme, actionsNeeded
 }
@@ -247,6 +398,18 @@ func (t Theme) Name() string {
 	return ""
 }
 
+// AuthorName returns the theme's author, or the owner of its GitHub repository if it does not declare one.
+func (t Theme) AuthorName() string {
+	if t.Author != "" {
+		return t.Author
+	}
+	urlParts := strings.Split(t.DownloadAt, "/")
+	if strings.Contains(t.DownloadAt, "github.com") && len(urlParts) == 5 {
+		return urlParts[len(urlParts)-2]
+	}
+	return ""
+}
+
 // AvailableVariants lists the possible variant names to choose from
 func (t Theme) AvailableVariants() []string {
 	names := make([]string, 0, len(t.Variants))
@@ -256,8 +419,15 @@ func (t Theme) AvailableVariants() []string {
 	return names
 }
 
-// ShowMessage renders the message and prints it to the user
+// ShowMessage renders the message and prints it to the user.
+// In JSON mode, the message is emitted as a step, without rendering its markdown.
 func (t Theme) ShowMessage() error {
+	if JSONOutput {
+		if strings.TrimSpace(t.Message) != "" {
+			emitEvent(Event{Type: EventStep, Message: strings.TrimSpace(t.Message)})
+		}
+		return nil
+	}
 	scheme := os.Getenv("COLORSCHEME")
 	if scheme != "light" && scheme != "dark" {
 		// TODO: detect with the terminal's current background color as a fallback
@@ -308,7 +478,8 @@ func (t Theme) GenerateManifest() (string, error) {
 
 // WriteManifest writes the contents of t as a YAML file named ffcss.yaml
 // inside inDirectory.
-// It adds a comment mentioning the documentation at the top of the file.
+// It adds a comment mentioning the documentation at the top of the file,
+// and one that tells editors where to find the manifest's JSON Schema (see ManifestSchemaURL).
 // See GenerateManifest to see how the contents of the file are generated.
 func (t Theme) WriteManifest(inDirectory string) error {
 	content, err := t.GenerateManifest()
@@ -316,7 +487,7 @@ func (t Theme) WriteManifest(inDirectory string) error {
 		return fmt.Errorf("while generating manifest contents for %s: %w", t.Name(), err)
 	}
 
-	content = "# This is a manifest for a FirefoxCSS theme. \n# See https://github.com/ewen-lbh/ffcss for more information.\n" + content
+	content = "# yaml-language-server: $schema=" + ManifestSchemaURL + "\n# This is a manifest for a FirefoxCSS theme. \n# See https://github.com/ewen-lbh/ffcss for more information.\n" + content
 
 	err = ioutil.WriteFile(filepath.Join(inDirectory, "ffcss.yaml"), []byte(content), 0700)
 	if err != nil {
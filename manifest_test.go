@@ -29,6 +29,8 @@ func TestLoadManifest(t *testing.T) {
 		{"root_variant_name", "invalid variant name \"" + RootVariantName + "\""},
 		{"unknown_os_key", "hannah montana is not a valid os replacement target. Targets are macos, windows and linux"},
 		{"wrong_casing_os_key", "MacOS is not a valid os replacement target. Targets are macos, windows and linux"},
+		{"invalid_condition", `invalid condition "fedora:38": unknown condition key "fedora"`},
+		{"condition_unknown_component", `condition "component:compakt and linux" refers to component "compakt", which does not exist`},
 	}
 
 	// TODO when out of 0.x.x, test for warning appearing when ffcss version incompatible (and appearing only _once_)
@@ -98,3 +100,15 @@ func TestLoadManifest(t *testing.T) {
 		Message: "Here's a choccy milk :) <https://i.redd.it/sh9re7861t851.png>\n",
 	}, actual)
 }
+
+func TestConfigEqual(t *testing.T) {
+	config := Config{"browser.tabs.inTitlebar": 1, "ui.systemUsesDarkTheme": 1}
+	assert.True(t, config.Equal(Config{"ui.systemUsesDarkTheme": 1, "browser.tabs.inTitlebar": 1}))
+	assert.True(t, Config{}.Equal(nil))
+	assert.False(t, config.Equal(Config{"browser.tabs.inTitlebar": 1}))
+	assert.False(t, Config{"browser.tabs.inTitlebar": 1}.Equal(config))
+	assert.False(t, config.Equal(Config{"browser.tabs.inTitlebar": 1, "ui.systemUsesDarkTheme": 0}))
+	assert.False(t, Config{"browser.tabs.inTitlebar": nil}.Equal(Config{"ui.systemUsesDarkTheme": nil}))
+	assert.True(t, Config{"ffcss.list": []interface{}{"a", 1}}.Equal(Config{"ffcss.list": []interface{}{"a", 1}}))
+	assert.False(t, Config{"ffcss.list": []interface{}{"a", 1}}.Equal(Config{"ffcss.list": []interface{}{"a", 2}}))
+}
@@ -0,0 +1,220 @@
+package ffcss
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// ManifestChange describes an edit done to a manifest to upgrade it to the current format, see MigrateManifest.
+type ManifestChange struct {
+	// Line is the line of the original manifest the change is on
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// manifestMigration upgrades manifests written for ffcss versions up to UpTo (included).
+// Since ffcss 0.X.X versions can change the format of manifests, migrations must be idempotent:
+// they are run every time a manifest written for a 0.X.X version is loaded, even if it is already in the current format.
+type manifestMigration struct {
+	UpTo int
+	// Migrate edits the root mapping of the manifest's YAML document in place, and describes what it changed
+	Migrate func(root *yamlv3.Node) []ManifestChange
+}
+
+// manifestMigrations are run in order on manifests written for older versions of ffcss, see MigrateManifest.
+var manifestMigrations = []manifestMigration{
+	{UpTo: 0, Migrate: renameManifestKey("repository", "download")},
+}
+
+// MigrateManifest upgrades the source of a manifest to the current format, according to the ffcss version it was written for (its ffcss entry).
+// Comments are kept. If the manifest needed changes, its ffcss entry is set to the current major version.
+// The source is returned as is if nothing needed to be changed.
+func MigrateManifest(raw []byte) ([]byte, []ManifestChange, error) {
+	changes := make([]ManifestChange, 0)
+	var document yamlv3.Node
+	err := yamlv3.Unmarshal(raw, &document)
+	if err != nil {
+		return raw, changes, fmt.Errorf("while parsing the manifest: %w", err)
+	}
+	if len(document.Content) == 0 || document.Content[0].Kind != yamlv3.MappingNode {
+		return raw, changes, nil
+	}
+	root := document.Content[0]
+
+	version := 0
+	if versionNode := mappingValue(root, "ffcss"); versionNode != nil {
+		version, err = strconv.Atoi(versionNode.Value)
+		if err != nil {
+			return raw, changes, fmt.Errorf("the manifest's ffcss version, %q, is not a number", versionNode.Value)
+		}
+	}
+	for _, migration := range manifestMigrations {
+		if version <= migration.UpTo {
+			changes = append(changes, migration.Migrate(root)...)
+		}
+	}
+	if len(changes) == 0 {
+		return raw, changes, nil
+	}
+
+	if versionNode := mappingValue(root, "ffcss"); versionNode != nil {
+		versionNode.Value = strconv.Itoa(VersionMajor)
+	} else {
+		versionKey := &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: "ffcss"}
+		// Keep the comment at the top of the file at the top
+		if len(root.Content) > 0 {
+			versionKey.HeadComment, root.Content[0].HeadComment = root.Content[0].HeadComment, ""
+		}
+		root.Content = append([]*yamlv3.Node{
+			versionKey,
+			{Kind: yamlv3.ScalarNode, Tag: "!!int", Value: strconv.Itoa(VersionMajor)},
+		}, root.Content...)
+	}
+
+	var migrated bytes.Buffer
+	encoder := yamlv3.NewEncoder(&migrated)
+	encoder.SetIndent(indentationOf(root))
+	err = encoder.Encode(&document)
+	if err != nil {
+		return raw, changes, fmt.Errorf("while writing the migrated manifest: %w", err)
+	}
+	err = encoder.Close()
+	if err != nil {
+		return raw, changes, fmt.Errorf("while writing the migrated manifest: %w", err)
+	}
+	return restoreBlankLines(raw, root, migrated.Bytes()), changes, nil
+}
+
+// restoreBlankLines adds back the blank lines that separated top-level keys in the original manifest, which are lost when the document is re-encoded.
+func restoreBlankLines(raw []byte, root *yamlv3.Node, migrated []byte) []byte {
+	rawLines := strings.Split(string(raw), "\n")
+	separated := make(map[string]bool)
+	for i := 0; i < len(root.Content)-1; i += 2 {
+		key := root.Content[i]
+		if key.Line == 0 {
+			continue
+		}
+		// The key's comment, if any, is right above it
+		above := key.Line - 1 - len(strings.Split(key.HeadComment, "\n"))
+		if key.HeadComment == "" {
+			above = key.Line - 1
+		}
+		if above >= 1 && strings.TrimSpace(rawLines[above-1]) == "" {
+			separated[key.Value] = true
+		}
+	}
+
+	lines := strings.Split(string(migrated), "\n")
+	restored := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if separated[strings.SplitN(line, ":", 2)[0]] && !strings.HasPrefix(line, " ") {
+			// Put the blank line above the key's comment
+			commentStart := len(restored)
+			for commentStart > 0 && strings.HasPrefix(restored[commentStart-1], "#") {
+				commentStart--
+			}
+			if commentStart > 0 {
+				restored = append(restored[:commentStart], append([]string{""}, restored[commentStart:]...)...)
+			}
+		}
+		restored = append(restored, line)
+	}
+	return []byte(strings.Join(restored, "\n"))
+}
+
+// MigrateManifestFile upgrades the manifest at manifestPath to the current format in place, see MigrateManifest.
+// The file is left untouched if nothing needed to be changed.
+func MigrateManifestFile(manifestPath string) ([]ManifestChange, error) {
+	stat, err := os.Stat(manifestPath)
+	if err != nil {
+		return []ManifestChange{}, fmt.Errorf("while reading manifest %s: %w", manifestPath, err)
+	}
+	raw, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return []ManifestChange{}, fmt.Errorf("while reading manifest %s: %w", manifestPath, err)
+	}
+	migrated, changes, err := MigrateManifest(raw)
+	if err != nil {
+		return changes, fmt.Errorf("while migrating %s: %w", manifestPath, err)
+	}
+	if len(changes) == 0 {
+		return changes, nil
+	}
+	err = os.WriteFile(manifestPath, migrated, stat.Mode().Perm())
+	if err != nil {
+		return changes, fmt.Errorf("while writing %s: %w", manifestPath, err)
+	}
+	return changes, nil
+}
+
+// renameManifestKey returns a migration that renames the key from to to, at the top level of the manifest and in its variants and conditional blocks.
+// If to is already set, from is removed.
+func renameManifestKey(from string, to string) func(root *yamlv3.Node) []ManifestChange {
+	return func(root *yamlv3.Node) []ManifestChange {
+		changes := make([]ManifestChange, 0)
+		for _, mapping := range overridingMappings(root) {
+			for i := 0; i < len(mapping.Content)-1; i += 2 {
+				key := mapping.Content[i]
+				if key.Value != from {
+					continue
+				}
+				if mappingValue(mapping, to) != nil {
+					changes = append(changes, ManifestChange{Line: key.Line, Message: fmt.Sprintf("%s was renamed to %s, which is also set: %s is dropped", from, to, from)})
+					mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+					i -= 2
+					continue
+				}
+				changes = append(changes, ManifestChange{Line: key.Line, Message: fmt.Sprintf("%s was renamed to %s", from, to)})
+				key.Value = to
+			}
+		}
+		return changes
+	}
+}
+
+// overridingMappings returns the mappings of a manifest that can set the theme's properties:
+// the manifest itself, its variants and its conditional blocks.
+func overridingMappings(root *yamlv3.Node) []*yamlv3.Node {
+	mappings := []*yamlv3.Node{root}
+	for _, key := range []string{"variants", "if"} {
+		overrides := mappingValue(root, key)
+		if overrides == nil || overrides.Kind != yamlv3.MappingNode {
+			continue
+		}
+		for i := 1; i < len(overrides.Content); i += 2 {
+			if overrides.Content[i].Kind == yamlv3.MappingNode {
+				mappings = append(mappings, overrides.Content[i])
+			}
+		}
+	}
+	return mappings
+}
+
+// mappingValue returns the value of key in mapping, or nil if it is not set.
+func mappingValue(mapping *yamlv3.Node, key string) *yamlv3.Node {
+	for i := 0; i < len(mapping.Content)-1; i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// indentationOf guesses how many spaces the manifest is indented with, from its first nested mapping or sequence.
+// Defaults to 4, like the examples of the README.
+func indentationOf(root *yamlv3.Node) int {
+	for i := 1; i < len(root.Content); i += 2 {
+		value := root.Content[i]
+		if (value.Kind == yamlv3.MappingNode || value.Kind == yamlv3.SequenceNode) && len(value.Content) > 0 && value.Content[0].Line > root.Content[i-1].Line {
+			if indentation := value.Content[0].Column - root.Content[i-1].Column; indentation > 0 {
+				return indentation
+			}
+		}
+	}
+	return 4
+}
@@ -0,0 +1,99 @@
+package ffcss
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateManifest(t *testing.T) {
+	migrated, changes, err := MigrateManifest([]byte(`# An old theme
+name: old # named after its repository
+repository: https://github.com/someone/old
+
+variants:
+  dark:
+    # the dark variant is in another repository
+    repository: https://github.com/someone/old-dark
+  light:
+    download: https://github.com/someone/old-light
+    repository: https://github.com/someone/old-light
+
+# hooks
+run:
+  after: |
+    echo done
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []ManifestChange{
+		{Line: 3, Message: "repository was renamed to download"},
+		{Line: 8, Message: "repository was renamed to download"},
+		{Line: 11, Message: "repository was renamed to download, which is also set: repository is dropped"},
+	}, changes)
+	assert.Equal(t, `# An old theme
+ffcss: 0
+name: old # named after its repository
+download: https://github.com/someone/old
+
+variants:
+  dark:
+    # the dark variant is in another repository
+    download: https://github.com/someone/old-dark
+  light:
+    download: https://github.com/someone/old-light
+
+# hooks
+run:
+  after: |
+    echo done
+`, string(migrated))
+
+	// Manifests in the current format are left as they are
+	current := []byte("ffcss: 0\nname:   current\ndownload: https://github.com/someone/current\n")
+	migrated, changes, err = MigrateManifest(current)
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+	assert.Equal(t, current, migrated)
+
+	// Migrations only apply to manifests written for the versions they upgrade from
+	newer := []byte("ffcss: 1000\nname: newer\nrepository: https://example.com\n")
+	migrated, changes, err = MigrateManifest(newer)
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+	assert.Equal(t, newer, migrated)
+
+	_, _, err = MigrateManifest([]byte("ffcss: one\nname: invalid\n"))
+	assert.EqualError(t, err, `the manifest's ffcss version, "one", is not a number`)
+}
+
+func TestMigrateManifestFile(t *testing.T) {
+	root := filepath.Join(testarea, "migrations")
+	os.RemoveAll(root)
+	os.MkdirAll(root, 0700)
+	manifestPath := filepath.Join(root, "ffcss.yaml")
+	os.WriteFile(manifestPath, []byte("name: old\nrepository: https://github.com/someone/old\n"), 0640)
+
+	// Old manifests are migrated when they are loaded
+	manifest, err := LoadManifest(manifestPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/someone/old", manifest.DownloadAt)
+	problems, err := LintTheme(manifestPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []LintProblem{
+		{File: manifestPath, Line: 2, Message: "repository was renamed to download (run ffcss migrate to update the manifest)"},
+	}, problems)
+
+	changes, err := MigrateManifestFile(manifestPath)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+	content, _ := os.ReadFile(manifestPath)
+	assert.Equal(t, "ffcss: 0\nname: old\ndownload: https://github.com/someone/old\n", string(content))
+	stat, _ := os.Stat(manifestPath)
+	assert.Equal(t, os.FileMode(0640), stat.Mode().Perm())
+
+	changes, err = MigrateManifestFile(manifestPath)
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+}
@@ -0,0 +1,13 @@
+package ffcss
+
+import "golang.org/x/sys/unix"
+
+// OSVersion returns the version of the operating system, as its users know it, e.g. 13.4.1.
+func OSVersion() string {
+	version, err := unix.Sysctl("kern.osproductversion")
+	if err != nil {
+		LogDebug("couldn't get the version of macOS: %s", err)
+		return ""
+	}
+	return version
+}
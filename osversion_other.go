@@ -0,0 +1,10 @@
+//go:build !windows && !darwin
+// +build !windows,!darwin
+
+package ffcss
+
+// OSVersion returns the version of the operating system, as its users know it.
+// It is unknown on Linux, which has no version of its own: OSVersion returns an empty string.
+func OSVersion() string {
+	return ""
+}
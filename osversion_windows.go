@@ -0,0 +1,18 @@
+package ffcss
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// OSVersion returns the version of the operating system, as its users know it, followed by its build number: e.g. 10.19045 or 11.22631.
+func OSVersion() string {
+	version := windows.RtlGetVersion()
+	major := version.MajorVersion
+	// Windows 11 still reports itself as Windows 10, but its builds start at 22000
+	if major == 10 && version.BuildNumber >= 22000 {
+		major = 11
+	}
+	return fmt.Sprintf("%d.%d", major, version.BuildNumber)
+}
@@ -0,0 +1,79 @@
+package ffcss
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// overriddenFiles are the files users can override, by the name of their override file, with where they are in profiles.
+var overriddenFiles = []struct{ override, installed string }{
+	{"userChrome.css", filepath.Join("chrome", "userChrome.css")},
+	{"userContent.css", filepath.Join("chrome", "userContent.css")},
+	{"user.js", "user.js"},
+}
+
+// OverridesDir returns the path of the directory of the user's overrides, which are applied on top of every theme.
+// Overrides for a single profile are in its profiles/ directory, see (FirefoxProfile).OverridesDirs.
+func OverridesDir(pathSegments ...string) string {
+	return ConfigDir(append([]string{"overrides"}, pathSegments...)...)
+}
+
+// OverridesDirs returns the directories the profile's overrides are in, in the order they are applied:
+// the overrides for every profile, then the ones for this profile, if any.
+// A profile's overrides are in a directory of OverridesDir("profiles") named after the profile's name, ID or directory.
+func (ffp FirefoxProfile) OverridesDirs() []string {
+	directories := []string{OverridesDir()}
+	entries, _ := os.ReadDir(OverridesDir("profiles"))
+	for _, entry := range entries {
+		if entry.IsDir() && (entry.Name() == ffp.Name || entry.Name() == ffp.ID || entry.Name() == ffp.FullName()) {
+			directories = append(directories, OverridesDir("profiles", entry.Name()))
+		}
+	}
+	return directories
+}
+
+// ApplyOverrides appends the user's overrides of the profile (see OverridesDirs) to the userChrome.css, userContent.css and user.js
+// installed in profileDir, so that they take precedence over the theme's. Files the theme did not install are created.
+// If files are given, only the overrides of these files (such as "userChrome.css") are applied.
+// It returns the paths of the files it wrote.
+func (ffp FirefoxProfile) ApplyOverrides(profileDir string, files ...string) ([]string, error) {
+	written := make([]string, 0)
+	for _, file := range overriddenFiles {
+		if len(files) > 0 && !contains(files, file.override) {
+			continue
+		}
+		overrides := ""
+		for _, directory := range ffp.OverridesDirs() {
+			override := filepath.Join(directory, file.override)
+			content, err := os.ReadFile(override)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return written, fmt.Errorf("while reading %s: %w", override, err)
+			}
+			overrides += fmt.Sprintf("\n/* Overrides from %s */\n%s\n", override, content)
+		}
+		if overrides == "" {
+			continue
+		}
+
+		target := filepath.Join(profileDir, file.installed)
+		content, err := os.ReadFile(target)
+		if err != nil && !os.IsNotExist(err) {
+			return written, fmt.Errorf("while reading %s: %w", target, err)
+		}
+		err = os.MkdirAll(filepath.Dir(target), 0700)
+		if err != nil {
+			return written, fmt.Errorf("couldn't create parent directories for %s: %w", target, err)
+		}
+		err = os.WriteFile(target, append(content, overrides...), 0700)
+		if err != nil {
+			return written, fmt.Errorf("while writing to %s: %w", target, err)
+		}
+		LogDebug("applied overrides to %s", target)
+		written = append(written, target)
+	}
+	return written, nil
+}
@@ -0,0 +1,43 @@
+package ffcss
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyOverrides(t *testing.T) {
+	defer os.RemoveAll(OverridesDir())
+	os.MkdirAll(OverridesDir("profiles", mockedProfile.FullName()), 0700)
+	os.MkdirAll(OverridesDir("profiles", "someone-else"), 0700)
+	os.WriteFile(OverridesDir("userChrome.css"), []byte("#back-button { display: none; }"), 0600)
+	os.WriteFile(OverridesDir("user.js"), []byte(`user_pref("layout.css.devPixelsPerPx", "1.2");`), 0600)
+	os.WriteFile(OverridesDir("profiles", mockedProfile.FullName(), "userChrome.css"), []byte(":root { font-size: 14px; }"), 0600)
+	os.WriteFile(OverridesDir("profiles", "someone-else", "userChrome.css"), []byte(":root { font-size: 20px; }"), 0600)
+
+	assert.Equal(t, []string{OverridesDir(), OverridesDir("profiles", mockedProfile.FullName())}, mockedProfile.OverridesDirs())
+
+	profileDir := filepath.Join(testarea, "overrides-profile")
+	os.RemoveAll(profileDir)
+	os.MkdirAll(filepath.Join(profileDir, "chrome"), 0700)
+	os.WriteFile(filepath.Join(profileDir, "chrome", "userChrome.css"), []byte("#nav-bar { color: red; }\n"), 0600)
+
+	written, err := mockedProfile.ApplyOverrides(profileDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(profileDir, "chrome", "userChrome.css"), filepath.Join(profileDir, "user.js")}, written)
+	userChrome, _ := os.ReadFile(filepath.Join(profileDir, "chrome", "userChrome.css"))
+	assert.Equal(t, "#nav-bar { color: red; }\n"+
+		"\n/* Overrides from "+OverridesDir("userChrome.css")+" */\n#back-button { display: none; }\n"+
+		"\n/* Overrides from "+OverridesDir("profiles", mockedProfile.FullName(), "userChrome.css")+" */\n:root { font-size: 14px; }\n", string(userChrome))
+	// Files the theme did not install are created
+	userJS, _ := os.ReadFile(filepath.Join(profileDir, "user.js"))
+	assert.Equal(t, "\n/* Overrides from "+OverridesDir("user.js")+" */\n"+`user_pref("layout.css.devPixelsPerPx", "1.2");`+"\n", string(userJS))
+	_, err = os.Stat(filepath.Join(profileDir, "chrome", "userContent.css"))
+	assert.True(t, os.IsNotExist(err))
+
+	written, err = mockedProfile.ApplyOverrides(profileDir, "userContent.css")
+	assert.NoError(t, err)
+	assert.Empty(t, written)
+}
@@ -0,0 +1,126 @@
+package ffcss
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProfileLock describes the lock Firefox holds on a profile while it is running.
+type ProfileLock struct {
+	// File is the path of the lock file
+	File string
+	// PID is the process ID of the Firefox instance holding the lock, 0 if it is unknown
+	PID int
+}
+
+// String returns a human-readable description of the lock.
+func (lock ProfileLock) String() string {
+	if lock.PID == 0 {
+		return fmt.Sprintf("locked by %s", lock.File)
+	}
+	return fmt.Sprintf("locked by process %d (%s)", lock.PID, lock.File)
+}
+
+// Lock returns the lock held on the profile by a running Firefox instance.
+// locked is false if Firefox is not running with that profile.
+// Lock files left behind by Firefox instances that are not running anymore (e.g. after a crash) are ignored.
+//
+// Firefox uses different lock files depending on the platform:
+//
+//    lock         a symbolic link pointing to ADDRESS:+PID (Linux)
+//    .parentlock  a file locked with fcntl (Linux and MacOS)
+//    parent.lock  a file opened without sharing (Windows)
+func (ffp FirefoxProfile) Lock() (lock ProfileLock, locked bool, err error) {
+	symlink := filepath.Join(ffp.Path, "lock")
+	if target, err := os.Readlink(symlink); err == nil {
+		pid, err := pidFromLockSymlink(target)
+		if err != nil {
+			LogDebug("ignoring %s: %s", symlink, err)
+		} else if processAlive(pid) {
+			return ProfileLock{File: symlink, PID: pid}, true, nil
+		} else {
+			LogDebug("ignoring %s: process %d is not running", symlink, pid)
+		}
+	}
+
+	for _, name := range []string{".parentlock", "parent.lock"} {
+		path := filepath.Join(ffp.Path, name)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		pid, held, err := fileLockHolder(path)
+		if err != nil {
+			return ProfileLock{}, false, fmt.Errorf("while checking %s: %w", path, err)
+		}
+		if held {
+			return ProfileLock{File: path, PID: pid}, true, nil
+		}
+	}
+	return ProfileLock{}, false, nil
+}
+
+// pidFromLockSymlink parses the target of a lock symbolic link, of the form ADDRESS:+PID.
+func pidFromLockSymlink(target string) (int, error) {
+	separator := strings.LastIndex(target, ":+")
+	if separator == -1 {
+		return 0, fmt.Errorf("%q is not of the form ADDRESS:+PID", target)
+	}
+	pid, err := strconv.Atoi(target[separator+2:])
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("%q is not of the form ADDRESS:+PID", target)
+	}
+	return pid, nil
+}
+
+// ProfileInUseError is returned when a profile can't be modified because Firefox is running with it.
+// Waited is how long ffcss waited for Firefox to be closed, if it did.
+type ProfileInUseError struct {
+	Profile FirefoxProfile
+	Lock    ProfileLock
+	Waited  time.Duration
+}
+
+func (e ProfileInUseError) Error() string {
+	if e.Waited > 0 {
+		return fmt.Sprintf("Firefox is still running with profile %s (%s) after waiting for %s. Close it first, or use --force to modify the profile anyway", e.Profile, e.Lock, e.Waited)
+	}
+	return fmt.Sprintf("Firefox is running with profile %s (%s). Close it first, or use --wait to wait until it is closed, or --force to modify the profile anyway", e.Profile, e.Lock)
+}
+
+// EnsureNotRunning returns a ProfileInUseError if Firefox is running with the profile.
+func (ffp FirefoxProfile) EnsureNotRunning() error {
+	lock, locked, err := ffp.Lock()
+	if err != nil {
+		return err
+	}
+	if locked {
+		return ProfileInUseError{Profile: ffp, Lock: lock}
+	}
+	return nil
+}
+
+// DefaultWaitTimeout is how long to wait for Firefox to be closed with --wait, unless --wait-timeout is given.
+const DefaultWaitTimeout = 5 * time.Minute
+
+// WaitUntilNotRunning blocks until Firefox is not running with the profile anymore, checking every interval.
+// It returns a ProfileInUseError if Firefox is still running with it after timeout.
+func (ffp FirefoxProfile) WaitUntilNotRunning(interval time.Duration, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		lock, locked, err := ffp.Lock()
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return ProfileInUseError{Profile: ffp, Lock: lock, Waited: timeout}
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+// +build !windows
+
+package ffcss
+
+import (
+	"os"
+	"syscall"
+)
+
+// processAlive returns true if a process with the given PID is running.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// fileLockHolder returns whether another process holds a lock on the file, and that process' ID.
+func fileLockHolder(path string) (pid int, held bool, err error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if os.IsPermission(err) {
+		file, err = os.Open(path)
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	defer file.Close()
+
+	lock := syscall.Flock_t{Type: syscall.F_WRLCK, Whence: 0, Start: 0, Len: 0}
+	err = syscall.FcntlFlock(file.Fd(), syscall.F_GETLK, &lock)
+	if err != nil {
+		return 0, false, err
+	}
+	if lock.Type == syscall.F_UNLCK {
+		return 0, false, nil
+	}
+	return int(lock.Pid), true, nil
+}
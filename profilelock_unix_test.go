@@ -0,0 +1,63 @@
+//go:build !windows
+// +build !windows
+
+package ffcss
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileLock(t *testing.T) {
+	profile := NewFirefoxProfileFromPath(filepath.Join(testarea, "locks", "abcdefgh.running"))
+	os.MkdirAll(profile.Path, 0700)
+
+	_, locked, err := profile.Lock()
+	assert.NoError(t, err)
+	assert.False(t, locked)
+
+	// Left behind by a Firefox that's not running anymore
+	os.WriteFile(filepath.Join(profile.Path, ".parentlock"), []byte{}, 0600)
+	os.Symlink("127.0.1.1:+999999999", filepath.Join(profile.Path, "lock"))
+	_, locked, err = profile.Lock()
+	assert.NoError(t, err)
+	assert.False(t, locked)
+	assert.NoError(t, profile.EnsureNotRunning())
+
+	os.Remove(filepath.Join(profile.Path, "lock"))
+	os.Symlink(fmt.Sprintf("127.0.1.1:+%d", os.Getpid()), filepath.Join(profile.Path, "lock"))
+	lock, locked, err := profile.Lock()
+	assert.NoError(t, err)
+	assert.True(t, locked)
+	assert.Equal(t, ProfileLock{File: filepath.Join(profile.Path, "lock"), PID: os.Getpid()}, lock)
+
+	var inUse ProfileInUseError
+	err = profile.EnsureNotRunning()
+	assert.True(t, errors.As(err, &inUse))
+	assert.Contains(t, err.Error(), fmt.Sprintf("Firefox is running with profile running (abcdefgh) (locked by process %d", os.Getpid()))
+
+	err = profile.WaitUntilNotRunning(10*time.Millisecond, 50*time.Millisecond)
+	assert.True(t, errors.As(err, &inUse))
+	assert.Equal(t, 50*time.Millisecond, inUse.Waited)
+	assert.Contains(t, err.Error(), "Firefox is still running with profile running (abcdefgh)")
+
+	os.Remove(filepath.Join(profile.Path, "lock"))
+	assert.NoError(t, profile.WaitUntilNotRunning(10*time.Millisecond, 50*time.Millisecond))
+}
+
+func TestPIDFromLockSymlink(t *testing.T) {
+	pid, err := pidFromLockSymlink("192.168.1.10:+4242")
+	assert.NoError(t, err)
+	assert.Equal(t, 4242, pid)
+
+	_, err = pidFromLockSymlink("4242")
+	assert.EqualError(t, err, `"4242" is not of the form ADDRESS:+PID`)
+	_, err = pidFromLockSymlink("127.0.0.1:+-1")
+	assert.Error(t, err)
+}
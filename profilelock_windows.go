@@ -0,0 +1,37 @@
+package ffcss
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// errorSharingViolation is returned by Windows when opening a file that another process opened without sharing it.
+const errorSharingViolation syscall.Errno = 32
+
+// processAlive returns true if a process with the given PID is running.
+func processAlive(pid int) bool {
+	process, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(process)
+	var exitCode uint32
+	err = syscall.GetExitCodeProcess(process, &exitCode)
+	// STILL_ACTIVE
+	return err == nil && exitCode == 259
+}
+
+// fileLockHolder returns whether another process holds the file open without sharing it.
+// The process' ID can't be known on Windows, so it is always 0.
+func fileLockHolder(path string) (pid int, held bool, err error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if errors.Is(err, errorSharingViolation) {
+		return 0, true, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	file.Close()
+	return 0, false, nil
+}
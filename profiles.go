@@ -16,6 +16,12 @@ type FirefoxProfile struct {
 	ID   string
 	Name string
 	Path string
+	// Default is true if this is the profile Firefox uses by default.
+	Default bool
+	// Locked is true if the profile can only be used by a single Firefox installation.
+	Locked bool
+	// Browser is the name of the browser the profile belongs to (see Browsers), if known.
+	Browser string
 }
 
 type firefoxProfileWithVersion = struct {
@@ -34,9 +40,14 @@ func (ffp FirefoxProfile) String() string {
 }
 
 // NewFirefoxProfileFromPath returns a FirefoxProfile by parsing the path into and ID and a Name.
+// Directories created by Firefox are named ID.NAME, for other directories, both the ID and the name are the directory's name.
+// Use Profiles to get profiles with their real names.
 func NewFirefoxProfileFromPath(path string) FirefoxProfile {
 	base := filepath.Base(path)
-	parts := strings.Split(base, ".")
+	parts := strings.SplitN(base, ".", 2)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return FirefoxProfile{Path: path, ID: base, Name: base}
+	}
 	return FirefoxProfile{
 		Path: path,
 		ID:   parts[0],
@@ -48,9 +59,8 @@ func NewFirefoxProfileFromPath(path string) FirefoxProfile {
 // See (FirefoxProfile).Display for the definition of a display string.
 func NewFirefoxProfileFromDisplay(displayString string, profiles []FirefoxProfile) FirefoxProfile {
 	for _, profile := range profiles {
-		ffp := NewFirefoxProfileFromPath(profile.Path)
-		if ffp.Display() == displayString {
-			return ffp
+		if profile.Display() == displayString {
+			return profile
 		}
 	}
 	LogDebug("while searching for %s in %v", displayString, profiles)
@@ -82,7 +92,8 @@ func ProfilePaths(operatingSystem string, optionalProfilesDir ...string) ([]stri
 		return []string{}, fmt.Errorf("couldn't read %s: %w", profilesFolder, err)
 	}
 	for _, releasePath := range directories {
-		if patternReleaseID.MatchString(releasePath.Name()) {
+		// Hidden directories are not profiles, e.g. ffcss' staging directories (see BeginInstallation)
+		if patternReleaseID.MatchString(releasePath.Name()) && !strings.HasPrefix(releasePath.Name(), ".") {
 			stat, err := os.Stat(filepath.Join(profilesFolder, releasePath.Name()))
 			if err != nil {
 				continue
@@ -97,28 +108,75 @@ func ProfilePaths(operatingSystem string, optionalProfilesDir ...string) ([]stri
 
 // Profiles returns a list of FirefoxProfiles. If optionalProfilesDir is "",
 // DefaultProfilesDir() is used to get the profiles' directory.
+// Profiles are read from Firefox's profiles.ini file (see ProfilesFromINI).
+// Directories of the profiles' directory that look like profiles but are not listed in profiles.ini are also returned.
 func Profiles(optionalProfilesDir string) ([]FirefoxProfile, error) {
-	var profiles []FirefoxProfile
-	var err error
-	var profilePaths []string
+	profilesDir := optionalProfilesDir
+	if profilesDir == "" {
+		var err error
+		profilesDir, err = DefaultProfilesDir(GOOStoOS(runtime.GOOS))
+		if err != nil {
+			return []FirefoxProfile{}, fmt.Errorf("couldn't get the profiles folder: %w. Try to use --profiles-dir", err)
+		}
+	}
 
-	if optionalProfilesDir != "" {
-		profilePaths, err = ProfilePaths(GOOStoOS(runtime.GOOS), optionalProfilesDir)
-	} else {
-		profilePaths, err = ProfilePaths(GOOStoOS(runtime.GOOS))
+	profiles := make([]FirefoxProfile, 0)
+	if profilesINIPath, found := FindProfilesINI(profilesDir); found {
+		LogDebug("reading profiles from %s", profilesINIPath)
+		fromINI, err := ProfilesFromINI(profilesINIPath)
+		if err != nil {
+			return profiles, fmt.Errorf("while reading profiles.ini: %w", err)
+		}
+		profiles = append(profiles, fromINI...)
 	}
 
+	profilePaths, err := ProfilePaths(GOOStoOS(runtime.GOOS), profilesDir)
 	if err != nil {
 		return profiles, fmt.Errorf("while getting profile paths: %w", err)
 	}
 
 	for _, profilePath := range profilePaths {
-		profiles = append(profiles, NewFirefoxProfileFromPath(profilePath))
+		if _, found := FindProfile(profiles, profilePath); !found {
+			profiles = append(profiles, NewFirefoxProfileFromPath(profilePath))
+		}
 	}
 
 	return profiles, nil
 }
 
+// FindProfile returns the profile of profiles stored at path.
+func FindProfile(profiles []FirefoxProfile, path string) (FirefoxProfile, bool) {
+	absolutePath, _ := filepath.Abs(path)
+	for _, profile := range profiles {
+		profileAbsolutePath, _ := filepath.Abs(profile.Path)
+		if profileAbsolutePath == absolutePath {
+			return profile, true
+		}
+	}
+	return FirefoxProfile{}, false
+}
+
+// DefaultProfiles returns the profiles that Firefox uses by default.
+// There can be more than one when several Firefox installations (e.g. Firefox and Firefox Developer Edition) have different default profiles.
+// When no profile is known to be the default, e.g. without a profiles.ini file, the profile named default-release is returned.
+func DefaultProfiles(profiles []FirefoxProfile) []FirefoxProfile {
+	defaults := make([]FirefoxProfile, 0)
+	for _, profile := range profiles {
+		if profile.Default {
+			defaults = append(defaults, profile)
+		}
+	}
+	if len(defaults) > 0 {
+		return defaults
+	}
+	for _, profile := range profiles {
+		if profile.Name == "default-release" {
+			return []FirefoxProfile{profile}
+		}
+	}
+	return defaults
+}
+
 // DefaultProfilesDir returns the operating-system-dependent default location for the Firefox profiles' directories.
 func DefaultProfilesDir(operatingSystem string) (string, error) {
 	switch operatingSystem {
@@ -164,13 +222,3 @@ func (t Theme) IncompatibleProfiles(profiles []FirefoxProfile) ([]firefoxProfile
 	}
 	return []firefoxProfileWithVersion{}, nil
 }
-
-// BackupChrome moves the chrome/ folder to chrome.bak/
-func (ffp FirefoxProfile) BackupChrome() error {
-	return renameIfExists(filepath.Join(ffp.Path, "chrome"), filepath.Join(ffp.Path, "chrome.bak"))
-}
-
-// BackupUserJS moves user.js to user.js.bak if user.js exists
-func (ffp FirefoxProfile) BackupUserJS() error {
-	return renameIfExists(filepath.Join(ffp.Path, "user.js"), filepath.Join(ffp.Path, "user.js.bak"))
-}
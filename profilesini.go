@@ -0,0 +1,123 @@
+package ffcss
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// iniSection is a [section] of an INI file, with its key=value entries.
+type iniSection struct {
+	Name    string
+	Entries map[string]string
+}
+
+// parseINI parses the contents of an INI file, as written by Firefox.
+// Sections are returned in the order they appear in. Comments and malformed lines are ignored.
+func parseINI(content []byte) []iniSection {
+	sections := make([]iniSection, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			sections = append(sections, iniSection{
+				Name:    strings.TrimSpace(line[1 : len(line)-1]),
+				Entries: make(map[string]string),
+			})
+		case len(sections) > 0 && strings.Contains(line, "="):
+			parts := strings.SplitN(line, "=", 2)
+			sections[len(sections)-1].Entries[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return sections
+}
+
+// FindProfilesINI returns the path of the profiles.ini file that lists the profiles of the given profiles directory.
+// On Linux, it is in the profiles directory itself, on MacOS and Windows, it is in its parent directory.
+// found is false if there is no profiles.ini file in either of them.
+func FindProfilesINI(profilesDir string) (path string, found bool) {
+	for _, directory := range []string{profilesDir, filepath.Dir(profilesDir)} {
+		path = filepath.Join(directory, "profiles.ini")
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// ProfilesFromINI returns the profiles listed in the given profiles.ini file.
+// The default profile is the one Firefox installations use by default, as set in installs.ini (or in the Install sections of profiles.ini).
+// When there are no installations, it is the profile marked as default in profiles.ini.
+// Profiles that are locked to an installation, so that only that installation can use them, are marked as locked.
+func ProfilesFromINI(profilesINIPath string) ([]FirefoxProfile, error) {
+	content, err := os.ReadFile(profilesINIPath)
+	if err != nil {
+		return []FirefoxProfile{}, fmt.Errorf("while reading %s: %w", profilesINIPath, err)
+	}
+	iniDir := filepath.Dir(profilesINIPath)
+	sections := parseINI(content)
+
+	// installs.ini duplicates the Install sections of profiles.ini, and is the only place they're stored in some versions of Firefox
+	installsINI, err := os.ReadFile(filepath.Join(iniDir, "installs.ini"))
+	if err == nil {
+		for _, section := range parseINI(installsINI) {
+			section.Name = "Install" + section.Name
+			sections = append(sections, section)
+		}
+	} else if !os.IsNotExist(err) {
+		return []FirefoxProfile{}, fmt.Errorf("while reading installs.ini: %w", err)
+	}
+
+	resolvePath := func(path string, relative bool) string {
+		path = filepath.FromSlash(path)
+		if relative {
+			return filepath.Join(iniDir, path)
+		}
+		return filepath.Clean(path)
+	}
+
+	profiles := make([]FirefoxProfile, 0)
+	installDefaults := make(map[string]bool)
+	locked := make(map[string]bool)
+	for _, section := range sections {
+		switch {
+		case strings.HasPrefix(section.Name, "Profile"):
+			if section.Entries["Path"] == "" {
+				LogDebug("ignoring section [%s] of %s: no path", section.Name, profilesINIPath)
+				continue
+			}
+			profile := NewFirefoxProfileFromPath(resolvePath(section.Entries["Path"], section.Entries["IsRelative"] != "0"))
+			if section.Entries["Name"] != "" {
+				profile.Name = section.Entries["Name"]
+			}
+			profile.Default = section.Entries["Default"] == "1"
+			profiles = append(profiles, profile)
+		case strings.HasPrefix(section.Name, "Install"):
+			if section.Entries["Default"] == "" {
+				continue
+			}
+			// Install sections don't have IsRelative: relative paths are the ones that exist relative to the profiles.ini file
+			path := resolvePath(section.Entries["Default"], !filepath.IsAbs(filepath.FromSlash(section.Entries["Default"])))
+			installDefaults[path] = true
+			if section.Entries["Locked"] == "1" {
+				locked[path] = true
+			}
+		}
+	}
+
+	if len(installDefaults) > 0 {
+		for i := range profiles {
+			profiles[i].Default = installDefaults[profiles[i].Path]
+		}
+	}
+	for i := range profiles {
+		profiles[i].Locked = locked[profiles[i].Path]
+	}
+	return profiles, nil
+}
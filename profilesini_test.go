@@ -0,0 +1,96 @@
+package ffcss
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseINI(t *testing.T) {
+	sections := parseINI([]byte("; comment\n[Profile0]\nName=work stuff\nPath = Profiles/abc.work\n\n[General]\nVersion=2\nmalformed\n"))
+	assert.Equal(t, []iniSection{
+		{Name: "Profile0", Entries: map[string]string{"Name": "work stuff", "Path": "Profiles/abc.work"}},
+		{Name: "General", Entries: map[string]string{"Version": "2"}},
+	}, sections)
+}
+
+func TestProfilesFromINI(t *testing.T) {
+	firefoxDir := filepath.Join(testarea, "profilesini", "Firefox")
+	elsewhere := filepath.Join(testarea, "profilesini", "elsewhere", "my profile")
+	os.MkdirAll(filepath.Join(firefoxDir, "Profiles", "q1w2e3r4.default-release"), 0700)
+	os.MkdirAll(filepath.Join(firefoxDir, "Profiles", "a1b2c3d4.dev-edition-default"), 0700)
+	os.MkdirAll(filepath.Join(firefoxDir, "Profiles", "z9y8x7w6.unlisted"), 0700)
+	os.MkdirAll(elsewhere, 0700)
+	os.WriteFile(filepath.Join(firefoxDir, "profiles.ini"), []byte(`[Profile2]
+Name=Work
+IsRelative=0
+Path=`+filepath.ToSlash(elsewhere)+`
+
+[Profile1]
+Name=dev-edition-default
+IsRelative=1
+Path=Profiles/a1b2c3d4.dev-edition-default
+
+[Profile0]
+Name=Personal
+IsRelative=1
+Path=Profiles/q1w2e3r4.default-release
+Default=1
+
+[General]
+StartWithLastProfile=1
+Version=2
+`), 0700)
+
+	profiles, err := ProfilesFromINI(filepath.Join(firefoxDir, "profiles.ini"))
+	assert.NoError(t, err)
+	assert.Equal(t, []FirefoxProfile{
+		{ID: "my profile", Name: "Work", Path: elsewhere},
+		{ID: "a1b2c3d4", Name: "dev-edition-default", Path: filepath.Join(firefoxDir, "Profiles", "a1b2c3d4.dev-edition-default")},
+		{ID: "q1w2e3r4", Name: "Personal", Path: filepath.Join(firefoxDir, "Profiles", "q1w2e3r4.default-release"), Default: true},
+	}, profiles)
+
+	os.WriteFile(filepath.Join(firefoxDir, "installs.ini"), []byte(`[4F96D1932A9F858E]
+Default=Profiles/a1b2c3d4.dev-edition-default
+Locked=1
+`), 0700)
+
+	profiles, err = ProfilesFromINI(filepath.Join(firefoxDir, "profiles.ini"))
+	assert.NoError(t, err)
+	assert.Equal(t, []FirefoxProfile{
+		{ID: "a1b2c3d4", Name: "dev-edition-default", Path: filepath.Join(firefoxDir, "Profiles", "a1b2c3d4.dev-edition-default"), Default: true, Locked: true},
+	}, DefaultProfiles(profiles))
+
+	profiles, err = Profiles(filepath.Join(firefoxDir, "Profiles"))
+	assert.NoError(t, err)
+	if assert.Len(t, profiles, 4) {
+		assert.Equal(t, "Work", profiles[0].Name)
+		assert.Equal(t, FirefoxProfile{ID: "z9y8x7w6", Name: "unlisted", Path: filepath.Join(firefoxDir, "Profiles", "z9y8x7w6.unlisted")}, profiles[3])
+	}
+
+	profile, found := FindProfile(profiles, elsewhere)
+	assert.True(t, found)
+	assert.Equal(t, "Work", profile.Name)
+}
+
+func TestDefaultProfiles(t *testing.T) {
+	profiles := []FirefoxProfile{
+		NewFirefoxProfileFromPath("abcdefgh.default"),
+		NewFirefoxProfileFromPath("ijklmnop.default-release"),
+	}
+	assert.Equal(t, []FirefoxProfile{profiles[1]}, DefaultProfiles(profiles))
+	assert.Equal(t, []FirefoxProfile{}, DefaultProfiles(profiles[:1]))
+	assert.Equal(t, FirefoxProfile{ID: "custom", Name: "custom", Path: "custom"}, NewFirefoxProfileFromPath("custom"))
+
+	profiles, err := Profiles("")
+	assert.NoError(t, err)
+	assert.Equal(t, []FirefoxProfile{{
+		ID:      "667ekipp",
+		Name:    "default-release",
+		Path:    filepath.Join(mockedHomedir, ".mozilla", "firefox", "667ekipp.default-release"),
+		Default: true,
+		Locked:  true,
+	}}, profiles)
+}
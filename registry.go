@@ -0,0 +1,277 @@
+package ffcss
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// RegistryDirName is the name of the directory of the cache where the registry is synced to.
+// It starts with a dot so that it can't be mistaken for a cached theme.
+const RegistryDirName = ".registry"
+
+// RegistryConfig says where to sync the registry from, and how to check that it is genuine.
+// It is read from registry.yaml in ffcss' configuration folder, see LoadRegistryConfig.
+type RegistryConfig struct {
+	// URL is the URL of the registry's index. Its signature is downloaded from the same URL, with .sig appended.
+	URL string
+	// PublicKey is the Ed25519 public key the index is signed with, either in PEM or as base64-encoded raw bytes.
+	PublicKey string `yaml:"public key"`
+}
+
+// RegistryIndex is the file served by a registry: manifests' YAML source, by theme name.
+type RegistryIndex struct {
+	Themes map[string]string `json:"themes"`
+}
+
+// RegistrySync describes what SyncRegistry did.
+type RegistrySync struct {
+	URL string `json:"url"`
+	// Updated is false if the index did not change since the last sync
+	Updated bool `json:"updated"`
+	// Themes are the names of the themes the registry has
+	Themes []string `json:"themes"`
+}
+
+// registryState is what is remembered of the last sync, to only download the index again when it changed.
+type registryState struct {
+	URL          string
+	ETag         string    `yaml:"etag,omitempty"`
+	LastModified string    `yaml:"last modified,omitempty"`
+	SyncedAt     time.Time `yaml:"synced at"`
+}
+
+var validRegistryThemeName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// RegistryConfigPath returns the path of the registry configuration file.
+func RegistryConfigPath() string {
+	return ConfigDir("registry.yaml")
+}
+
+// RegistryDir returns the path of the directory the registry's manifests are synced to.
+func RegistryDir(pathSegments ...string) string {
+	return CacheDir(append([]string{RegistryDirName}, pathSegments...)...)
+}
+
+// LoadRegistryConfig reads the registry configuration file.
+func LoadRegistryConfig() (RegistryConfig, error) {
+	var config RegistryConfig
+	raw, err := os.ReadFile(RegistryConfigPath())
+	if os.IsNotExist(err) {
+		return config, fmt.Errorf("no registry is configured: set its url and public key in %s", RegistryConfigPath())
+	}
+	if err != nil {
+		return config, fmt.Errorf("while reading %s: %w", RegistryConfigPath(), err)
+	}
+	err = yaml.Unmarshal(raw, &config)
+	if err != nil {
+		return config, fmt.Errorf("while parsing %s: %w", RegistryConfigPath(), err)
+	}
+	if config.URL == "" {
+		return config, fmt.Errorf("%s has no url", RegistryConfigPath())
+	}
+	return config, nil
+}
+
+// publicKey decodes the configured public key.
+func (config RegistryConfig) publicKey() (ed25519.PublicKey, error) {
+	if strings.TrimSpace(config.PublicKey) == "" {
+		return nil, fmt.Errorf("the registry has no public key: only signed registries can be synced")
+	}
+	if block, _ := pem.Decode([]byte(config.PublicKey)); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("while parsing the registry's public key: %w", err)
+		}
+		if ed25519Key, ok := key.(ed25519.PublicKey); ok {
+			return ed25519Key, nil
+		}
+		return nil, fmt.Errorf("the registry's public key is not an Ed25519 key")
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(config.PublicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("the registry's public key must be an Ed25519 key, in PEM or as %d base64-encoded bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// SyncRegistry downloads the registry's index, checks its signature, and writes its manifests to RegistryDir("themes"),
+// replacing the previously synced ones.
+// The index is only downloaded again if it changed since the last sync (using ETag and Last-Modified).
+func SyncRegistry(config RegistryConfig) (RegistrySync, error) {
+	sync := RegistrySync{URL: config.URL, Themes: []string{}}
+	publicKey, err := config.publicKey()
+	if err != nil {
+		return sync, err
+	}
+
+	state := loadRegistryState()
+	if state.URL != config.URL {
+		state = registryState{URL: config.URL}
+	}
+	headers := http.Header{}
+	if _, err := os.Stat(RegistryDir("themes")); err == nil {
+		if state.ETag != "" {
+			headers.Set("If-None-Match", state.ETag)
+		}
+		if state.LastModified != "" {
+			headers.Set("If-Modified-Since", state.LastModified)
+		}
+	}
+
+	response, index, err := fetchRegistryFile(config.URL, headers)
+	if err != nil {
+		return sync, fmt.Errorf("while downloading the registry's index: %w", err)
+	}
+	if response.StatusCode == http.StatusNotModified {
+		LogDebug("registry index at %s did not change", config.URL)
+		catalog, err := loadCatalogDirectory(RegistryDir("themes"))
+		for name := range catalog {
+			sync.Themes = append(sync.Themes, name)
+		}
+		sort.Strings(sync.Themes)
+		return sync, err
+	}
+
+	_, signature, err := fetchRegistryFile(config.URL+".sig", http.Header{})
+	if err != nil {
+		return sync, fmt.Errorf("while downloading the registry's signature: %w", err)
+	}
+	if !ed25519.Verify(publicKey, index, decodeSignature(signature)) {
+		return sync, fmt.Errorf("the signature of the registry's index does not match its public key, not using it")
+	}
+
+	var parsed RegistryIndex
+	err = json.Unmarshal(index, &parsed)
+	if err != nil {
+		return sync, fmt.Errorf("while parsing the registry's index: %w", err)
+	}
+	sync.Themes, err = writeRegistryThemes(parsed)
+	if err != nil {
+		return sync, err
+	}
+	sync.Updated = true
+
+	state.ETag = response.Header.Get("ETag")
+	state.LastModified = response.Header.Get("Last-Modified")
+	state.SyncedAt = time.Now()
+	return sync, state.write()
+}
+
+// fetchRegistryFile downloads the file at URL with the given headers, and returns the response along with its body.
+// Responses other than 200 OK and 304 Not Modified are errors.
+func fetchRegistryFile(URL string, headers http.Header) (*http.Response, []byte, error) {
+	request, err := http.NewRequest(http.MethodGet, URL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid URL %s: %w", URL, err)
+	}
+	request.Header = headers
+	client := http.Client{Timeout: DownloadTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusNotModified {
+		return response, nil, fmt.Errorf("%s responded with %s", URL, response.Status)
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return response, nil, fmt.Errorf("while reading the response of %s: %w", URL, err)
+	}
+	return response, body, nil
+}
+
+// decodeSignature accepts signatures as raw bytes (as written by e.g. openssl pkeyutl -sign) or encoded in base64.
+func decodeSignature(signature []byte) []byte {
+	if len(signature) == ed25519.SignatureSize {
+		return signature
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return signature
+	}
+	return decoded
+}
+
+// writeRegistryThemes replaces the synced manifests with the index's, and returns the names of the themes that were written.
+// Manifests that are invalid are skipped with a warning.
+func writeRegistryThemes(index RegistryIndex) ([]string, error) {
+	err := os.MkdirAll(RegistryDir(), 0700)
+	if err != nil {
+		return []string{}, fmt.Errorf("while creating %s: %w", RegistryDir(), err)
+	}
+	staging, err := os.MkdirTemp(RegistryDir(), "themes-*")
+	if err != nil {
+		return []string{}, fmt.Errorf("while creating a temporary directory in %s: %w", RegistryDir(), err)
+	}
+	defer os.RemoveAll(staging)
+
+	names := make([]string, 0, len(index.Themes))
+	for name, manifest := range index.Themes {
+		if !validRegistryThemeName.MatchString(name) || name == TempDownloadsDirName {
+			LogWarning("the registry has a theme with an invalid name, %q: skipping it", name)
+			continue
+		}
+		path := filepath.Join(staging, name+".yaml")
+		err = os.WriteFile(path, []byte(manifest), 0600)
+		if err != nil {
+			return []string{}, fmt.Errorf("while writing %s: %w", path, err)
+		}
+		if _, err := LoadManifest(path); err != nil {
+			LogWarning("the registry's manifest of %s is invalid: %s. Skipping it", name, err)
+			os.Remove(path)
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	err = os.RemoveAll(RegistryDir("themes"))
+	if err != nil {
+		return []string{}, fmt.Errorf("while removing the previously synced themes: %w", err)
+	}
+	err = os.Rename(staging, RegistryDir("themes"))
+	if err != nil {
+		return []string{}, fmt.Errorf("while moving the synced themes to %s: %w", RegistryDir("themes"), err)
+	}
+	return names, nil
+}
+
+// loadRegistryState returns what is remembered of the last sync, if anything.
+func loadRegistryState() registryState {
+	var state registryState
+	raw, err := os.ReadFile(RegistryDir("state.yaml"))
+	if err != nil {
+		return state
+	}
+	if err := yaml.Unmarshal(raw, &state); err != nil {
+		LogDebug("ignoring invalid registry state: %s", err)
+	}
+	return state
+}
+
+func (state registryState) write() error {
+	raw, err := yaml.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("while serializing the registry's state: %w", err)
+	}
+	err = os.WriteFile(RegistryDir("state.yaml"), raw, 0600)
+	if err != nil {
+		return fmt.Errorf("while writing %s: %w", RegistryDir("state.yaml"), err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package ffcss
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncRegistry(t *testing.T) {
+	defer os.RemoveAll(RegistryDir())
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	index, _ := json.Marshal(RegistryIndex{Themes: map[string]string{
+		"registered": "name: registered\ndownload: https://github.com/someone/registered\n",
+		"overridden": "name: overridden\ndescription: from the registry\n",
+		"../escaped": "name: escaped\n",
+	}})
+	signature := ed25519.Sign(privateKey, index)
+	indexRequests, notModified := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.json":
+			indexRequests++
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Write(index)
+		case "/index.json.sig":
+			w.Write([]byte(base64.StdEncoding.EncodeToString(signature)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	config := RegistryConfig{URL: server.URL + "/index.json", PublicKey: base64.StdEncoding.EncodeToString(publicKey)}
+
+	sync, err := SyncRegistry(config)
+	assert.NoError(t, err)
+	assert.Equal(t, RegistrySync{URL: config.URL, Updated: true, Themes: []string{"overridden", "registered"}}, sync)
+
+	// The index is not downloaded again when it did not change
+	sync, err = SyncRegistry(config)
+	assert.NoError(t, err)
+	assert.False(t, sync.Updated)
+	assert.Equal(t, []string{"overridden", "registered"}, sync.Themes)
+	assert.Equal(t, 2, indexRequests)
+	assert.Equal(t, 1, notModified)
+
+	// Local manifests win over the registry's
+	localThemes := filepath.Join(testarea, "registry-local-themes")
+	os.MkdirAll(localThemes, 0700)
+	os.WriteFile(filepath.Join(localThemes, "overridden.yaml"), []byte("name: overridden\ndescription: local\n"), 0600)
+	catalog, err := LoadCatalog(localThemes)
+	assert.NoError(t, err)
+	assert.Len(t, catalog, 2)
+	assert.Equal(t, "local", catalog["overridden"].Description)
+	assert.Equal(t, "someone", catalog["registered"].AuthorName())
+
+	// Indexes that are not signed with the configured key are refused, and the synced themes are kept
+	otherPublicKey, _, _ := ed25519.GenerateKey(nil)
+	os.Remove(RegistryDir("state.yaml"))
+	_, err = SyncRegistry(RegistryConfig{URL: config.URL, PublicKey: base64.StdEncoding.EncodeToString(otherPublicKey)})
+	assert.EqualError(t, err, "the signature of the registry's index does not match its public key, not using it")
+	_, err = os.Stat(RegistryDir("themes", "registered.yaml"))
+	assert.NoError(t, err)
+
+	_, err = SyncRegistry(RegistryConfig{URL: config.URL})
+	assert.EqualError(t, err, "the registry has no public key: only signed registries can be synced")
+}
@@ -0,0 +1,111 @@
+package ffcss
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UnsafePathError is returned when a path coming from a theme (an archive entry, an asset, a file template…)
+// would make ffcss read or write outside of the directory it is supposed to stay in.
+type UnsafePathError struct {
+	Path string
+	Root string
+	// Reason explains why the path is unsafe, e.g. "is absolute"
+	Reason string
+}
+
+func (e UnsafePathError) Error() string {
+	return fmt.Sprintf("%q %s, refusing to go outside of %s", e.Path, e.Reason, e.Root)
+}
+
+// isWithin returns true if path is root or is inside of it. Both paths are compared lexically, symlinks are not resolved.
+func isWithin(root string, path string) bool {
+	relative, err := filepath.Rel(filepath.Clean(root), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator)) && !filepath.IsAbs(relative)
+}
+
+// SafeJoin joins the relative path elem to root, after checking that the result stays inside of root (see EnsureWithin).
+// elem can use slashes or the operating system's separator.
+func SafeJoin(root string, elem string) (string, error) {
+	if filepath.IsAbs(elem) || strings.HasPrefix(elem, "/") || strings.HasPrefix(elem, `\`) || filepath.VolumeName(elem) != "" {
+		return "", UnsafePathError{Path: elem, Root: root, Reason: "is absolute"}
+	}
+	joined := filepath.Join(root, filepath.FromSlash(elem))
+	if !isWithin(root, joined) {
+		return "", UnsafePathError{Path: elem, Root: root, Reason: "goes up too many directories"}
+	}
+	return joined, EnsureWithin(root, joined)
+}
+
+// EnsureWithin returns an UnsafePathError if path is outside of root, either lexically,
+// or because it goes through a symlink that points outside of root.
+// path does not have to exist: only the symlinks of its existing part are resolved.
+func EnsureWithin(root string, path string) error {
+	if !isWithin(root, path) {
+		return UnsafePathError{Path: path, Root: root, Reason: "is outside"}
+	}
+	// Symlinks can point to absolute paths, so both paths need to be absolute to be compared
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if os.IsNotExist(err) {
+		// Nothing can be a symlink if the root itself does not exist yet
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("while resolving symlinks of %s: %w", root, err)
+	}
+	resolved, err := evalExistingSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("while resolving symlinks of %s: %w", path, err)
+	}
+	if !isWithin(resolvedRoot, resolved) {
+		return UnsafePathError{Path: path, Root: root, Reason: "goes through a symlink that points outside"}
+	}
+	return nil
+}
+
+// evalExistingSymlinks resolves the symlinks in the longest existing prefix of path, and appends the rest of path to it.
+// Dangling symlinks are followed too, since writing to them would create their target.
+func evalExistingSymlinks(path string) (string, error) {
+	path = filepath.Clean(path)
+	missing := make([]string, 0)
+	for followed := 0; followed < 255; {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		if target, err := os.Readlink(path); err == nil {
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(path), target)
+			}
+			path = filepath.Clean(target)
+			followed++
+			continue
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		missing = append(missing, filepath.Base(path))
+		path = parent
+	}
+	return "", fmt.Errorf("too many levels of symbolic links")
+}
@@ -0,0 +1,123 @@
+package ffcss
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeJoin(t *testing.T) {
+	root := filepath.Join(testarea, "safejoin")
+	os.MkdirAll(filepath.Join(root, "chrome"), 0700)
+	os.MkdirAll(filepath.Join(testarea, "safejoin-outside"), 0700)
+
+	joined, err := SafeJoin(root, "chrome/icons/../userChrome.css")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "chrome", "userChrome.css"), joined)
+
+	_, err = SafeJoin(root, "/etc/passwd")
+	assert.True(t, errors.As(err, &UnsafePathError{}))
+	assert.EqualError(t, err, `"/etc/passwd" is absolute, refusing to go outside of `+root)
+
+	_, err = SafeJoin(root, "chrome/../../safejoin-outside/evil.css")
+	assert.EqualError(t, err, `"chrome/../../safejoin-outside/evil.css" goes up too many directories, refusing to go outside of `+root)
+
+	// A sibling directory that shares a prefix with root is still outside of it
+	_, err = SafeJoin(root, "../safejoin-outside")
+	assert.Error(t, err)
+	assert.False(t, isWithin(root, root+"-outside"))
+	assert.True(t, isWithin(root, root))
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	os.Symlink(filepath.Join(testarea, "safejoin-outside"), filepath.Join(root, "chrome", "escape"))
+	_, err = SafeJoin(root, "chrome/escape/evil.css")
+	assert.True(t, errors.As(err, &UnsafePathError{}))
+	assert.Contains(t, err.Error(), "goes through a symlink that points outside")
+
+	// Writing through a dangling symlink would create its target
+	os.Symlink(filepath.Join(testarea, "safejoin-outside", "nothing-yet"), filepath.Join(root, "chrome", "dangling"))
+	_, err = SafeJoin(root, "chrome/dangling")
+	assert.Contains(t, err.Error(), "goes through a symlink that points outside")
+
+	os.Symlink("userChrome.css", filepath.Join(root, "chrome", "inside"))
+	_, err = SafeJoin(root, "chrome/inside")
+	assert.NoError(t, err)
+}
+
+func TestExtractMaliciousArchives(t *testing.T) {
+	for _, test := range []struct {
+		archive string
+		unsafe  bool
+	}{
+		{"traversal.zip", true},
+		{"absolute.zip", true},
+		{"symlink.zip", false},
+		{"traversal.tar.gz", true},
+		{"absolute.tar.gz", true},
+		{"symlink.tar.gz", false},
+		{"hardlink.tar.gz", false},
+	} {
+		directory := filepath.Join(testarea, "malicious", test.archive)
+		destination := filepath.Join(directory, "a", "b", "extracted")
+		os.MkdirAll(destination, 0700)
+		archive := filepath.Join("testdata", "malicious", test.archive)
+		format, err := detectArchiveFormat(archive)
+		assert.NoError(t, err)
+
+		extracted, err := extractArchive(archive, format, destination)
+		if test.unsafe {
+			assert.True(t, errors.As(err, &UnsafePathError{}), "%s: expected an unsafe path error, got %v", test.archive, err)
+		} else {
+			assert.NoError(t, err, test.archive)
+		}
+		for _, file := range extracted {
+			assert.True(t, isWithin(destination, file), "%s: extracted %s", test.archive, file)
+		}
+		// Symlinks and hard links are never extracted, so files after them end up in a regular directory
+		filepath.Walk(destination, func(path string, info os.FileInfo, err error) error {
+			assert.True(t, info.Mode().IsDir() || info.Mode().IsRegular(), "%s: extracted %s, of mode %s", test.archive, path, info.Mode())
+			return nil
+		})
+		assert.NoFileExists(t, filepath.Join(directory, "evil.css"), test.archive)
+		assert.NoFileExists(t, filepath.Join(directory, "a", "evil.css"), test.archive)
+		assert.NoFileExists(t, filepath.Join(directory, "a", "b", "evil.css"), test.archive)
+		assert.NoFileExists(t, "/tmp/ffcss-evil.css", test.archive)
+	}
+}
+
+func TestInstallAssetsOutsideOfTheme(t *testing.T) {
+	theme := NewTheme()
+	theme.DownloadedTo = filepath.Join(testarea, "unsafeassets", "theme")
+	theme.Assets = []FileTemplate{"../theme-outside/*.css"}
+	profileDir := filepath.Join(testarea, "unsafeassets", "profile")
+	os.MkdirAll(filepath.Join(theme.DownloadedTo, "chrome"), 0700)
+	os.MkdirAll(filepath.Join(testarea, "unsafeassets", "theme-outside"), 0700)
+	os.MkdirAll(filepath.Join(profileDir, "chrome"), 0700)
+	os.WriteFile(filepath.Join(testarea, "unsafeassets", "theme-outside", "secret.css"), []byte("* {}"), 0600)
+
+	installed, err := theme.InstallAssets("linux", Variant{}, profileDir)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is outside of the theme's root")
+	assert.Empty(t, installed)
+
+	theme.Assets = []FileTemplate{"chrome/**"}
+	theme.CopyFrom = "../"
+	os.WriteFile(filepath.Join(theme.DownloadedTo, "chrome", "userChrome.css"), []byte("* {}"), 0600)
+	_, err = theme.InstallAssets("linux", Variant{}, profileDir)
+	assert.Contains(t, err.Error(), `copy from "../" is outside of the theme's root`)
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	theme.CopyFrom = ""
+	os.Symlink(filepath.Join(testarea, "unsafeassets", "theme-outside", "secret.css"), filepath.Join(theme.DownloadedTo, "chrome", "secret.css"))
+	_, err = theme.InstallAssets("linux", Variant{}, profileDir)
+	assert.Contains(t, err.Error(), "goes through a symlink that points outside")
+	assert.NoFileExists(t, filepath.Join(profileDir, "chrome", "secret.css"))
+}
@@ -0,0 +1,186 @@
+package ffcss
+
+import (
+	_ "embed"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// ManifestSchemaURL is where the JSON Schema of manifests is published.
+// ffcss init points to it, so that editors can validate and complete manifests.
+const ManifestSchemaURL = "https://raw.githubusercontent.com/ewen-lbh/ffcss/main/manifest.schema.json"
+
+// ManifestSchema is the JSON Schema of manifests, generated from Theme (see generateManifestSchema).
+//
+//go:embed manifest.schema.json
+var ManifestSchema []byte
+
+// JSONSchema is the subset of JSON Schema (draft-07) needed to describe manifests.
+type JSONSchema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Type is either a string or a list of strings
+	Type       interface{}            `json:"type,omitempty"`
+	Properties map[string]*JSONSchema `json:"properties,omitempty"`
+	// AdditionalProperties is either a *JSONSchema or false
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AllOf                []*JSONSchema          `json:"allOf,omitempty"`
+	Definitions          map[string]*JSONSchema `json:"definitions,omitempty"`
+}
+
+// generateManifestSchema generates the JSON Schema of manifests from Theme and the types it uses.
+// Properties are named after the fields' YAML keys, and described with docs (see docComments).
+// Fields of Variant without a doc comment are described like Theme's field of the same name, since they override it.
+func generateManifestSchema(docs map[string]string) JSONSchema {
+	definitions := make(map[string]*JSONSchema)
+	schema := schemaOf(reflect.TypeOf(Theme{}), "Theme", docs, definitions)
+	schema.Schema = "http://json-schema.org/draft-07/schema#"
+	schema.ID = ManifestSchemaURL
+	schema.Title = "ffcss manifest"
+	schema.Description = "The manifest of a FirefoxCSS theme, see https://github.com/ewen-lbh/ffcss#creating-a-firefoxcss-theme"
+	schema.Definitions = definitions
+	return *schema
+}
+
+// schemaOf returns the schema of values of type t, found at path (e.g. Theme.Run).
+// Named structs other than Theme are added to definitions and referenced.
+func schemaOf(t reflect.Type, path string, docs map[string]string, definitions map[string]*JSONSchema) *JSONSchema {
+	switch t.Kind() {
+	case reflect.String:
+		// null is used to say that a theme has no such file, e.g. userContent: null
+		return &JSONSchema{Type: []string{"string", "null"}}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: schemaOf(t.Elem(), path, docs, definitions)}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: schemaOf(t.Elem(), path, docs, definitions)}
+	case reflect.Struct:
+		if t.Name() != "" && t.Name() != "Theme" {
+			if _, defined := definitions[t.Name()]; !defined {
+				// Reserve the name first, in case the struct refers to itself
+				definitions[t.Name()] = &JSONSchema{}
+				*definitions[t.Name()] = *structSchema(t, t.Name(), docs, definitions)
+			}
+			return &JSONSchema{Ref: "#/definitions/" + t.Name()}
+		}
+		return structSchema(t, path, docs, definitions)
+	}
+	// Anything goes, e.g. the values of Config
+	return &JSONSchema{}
+}
+
+// structSchema returns the schema of the struct t, with a property per field that can be set in a manifest.
+func structSchema(t reflect.Type, path string, docs map[string]string, definitions map[string]*JSONSchema) *JSONSchema {
+	schema := &JSONSchema{
+		Type:                 "object",
+		Description:          docs[path],
+		Properties:           make(map[string]*JSONSchema),
+		AdditionalProperties: false,
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if field.PkgPath != "" || key == "-" {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		fieldPath := path + "." + field.Name
+		property := schemaOf(field.Type, fieldPath, docs, definitions)
+		doc, documented := docs[fieldPath]
+		if !documented && strings.HasPrefix(fieldPath, "Variant.") {
+			doc = docs["Theme."+strings.TrimPrefix(fieldPath, "Variant.")]
+		}
+		// Doc comments start with the field's name, which means nothing to manifest writers
+		if strings.HasPrefix(doc, field.Name+" ") {
+			doc = "`" + key + "`" + strings.TrimPrefix(doc, field.Name)
+		}
+		if property.Ref != "" && doc != "" {
+			// Keywords next to $ref are ignored in draft-07
+			property = &JSONSchema{AllOf: []*JSONSchema{property}}
+		}
+		property.Description = doc
+		schema.Properties[key] = property
+	}
+	return schema
+}
+
+// docComments reads the doc comments of the struct types declared in the Go files of directory, and of their fields.
+// They are keyed by type name (e.g. Theme) and by path (e.g. Theme.Run.Before), with line breaks replaced with spaces.
+func docComments(directory string) (map[string]string, error) {
+	docs := make(map[string]string)
+	files, err := filepath.Glob(filepath.Join(directory, "*.go"))
+	if err != nil {
+		return docs, fmt.Errorf("while listing the Go files of %s: %w", directory, err)
+	}
+	fileSet := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		parsed, err := parser.ParseFile(fileSet, file, nil, parser.ParseComments)
+		if err != nil {
+			return docs, fmt.Errorf("while parsing %s: %w", file, err)
+		}
+		for _, declaration := range parsed.Decls {
+			general, ok := declaration.(*ast.GenDecl)
+			if !ok || general.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range general.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				structType, ok := typeSpec.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				doc := typeSpec.Doc
+				if doc == nil && len(general.Specs) == 1 {
+					doc = general.Doc
+				}
+				docs[typeSpec.Name.Name] = commentText(doc)
+				fieldDocComments(structType, typeSpec.Name.Name, docs)
+			}
+		}
+	}
+	return docs, nil
+}
+
+// fieldDocComments adds the doc comments of structType's fields to docs, recursing into anonymous structs.
+func fieldDocComments(structType *ast.StructType, path string, docs map[string]string) {
+	for _, field := range structType.Fields.List {
+		doc := field.Doc
+		if doc == nil {
+			doc = field.Comment
+		}
+		for _, name := range field.Names {
+			if text := commentText(doc); text != "" {
+				docs[path+"."+name.Name] = text
+			}
+			if nested, ok := field.Type.(*ast.StructType); ok {
+				fieldDocComments(nested, path+"."+name.Name, docs)
+			}
+		}
+	}
+}
+
+func commentText(comment *ast.CommentGroup) string {
+	if comment == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(comment.Text()), " ")
+}
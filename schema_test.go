@@ -0,0 +1,35 @@
+package ffcss
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var updateSchema = flag.Bool("update-schema", false, "regenerate manifest.schema.json")
+
+// TestManifestSchema checks that manifest.schema.json is in sync with Theme.
+// Run go test -run TestManifestSchema -update-schema after changing Theme to regenerate it.
+func TestManifestSchema(t *testing.T) {
+	docs, err := docComments(".")
+	assert.NoError(t, err)
+	generated, err := json.MarshalIndent(generateManifestSchema(docs), "", "  ")
+	assert.NoError(t, err)
+	generated = append(generated, '\n')
+	if *updateSchema {
+		assert.NoError(t, os.WriteFile("manifest.schema.json", generated, 0644))
+		return
+	}
+	assert.Equal(t, string(generated), string(ManifestSchema), "manifest.schema.json is out of date: run go test -run TestManifestSchema -update-schema")
+
+	var schema JSONSchema
+	assert.NoError(t, json.Unmarshal(ManifestSchema, &schema))
+	assert.Equal(t, "`name` is the theme's name. If it is not set, the theme is named after its manifest's file", schema.Properties["name"].Description)
+	assert.Equal(t, "`before` is run before installing the theme", schema.Properties["run"].Properties["before"].Description)
+	assert.Equal(t, "#/definitions/Variant", schema.Properties["variants"].AdditionalProperties.(map[string]interface{})["$ref"])
+	assert.Equal(t, "`userChrome` is the path of the file copied to chrome/userChrome.css", schema.Definitions["Variant"].Properties["userChrome"].Description)
+	assert.NotContains(t, schema.Properties, "firefoxversionconstraint")
+}
@@ -0,0 +1,142 @@
+package ffcss
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Installation stages the installation of a theme in a temporary directory next to a profile.
+// Files are installed to StagingDir instead of the profile directory, and are only swapped in when the installation is committed.
+// Until it is finished, an installation can be rolled back, which restores the profile's previous chrome/ directory and user.js exactly.
+type Installation struct {
+	Profile    FirefoxProfile
+	StagingDir string
+	committed  bool
+	// Which of installationSwappedFiles were moved out of the profile, and which were moved in
+	movedOut map[string]bool
+	movedIn  map[string]bool
+}
+
+// installationSwappedFiles are the files and directories of a profile replaced by an installation, relative to the profile directory.
+var installationSwappedFiles = []string{"chrome", "user.js"}
+
+// BeginInstallation creates the staging directory of a new installation on the profile.
+// The staging directory is created in the same directory as the profile, so that swapping files in is a simple rename.
+func (ffp FirefoxProfile) BeginInstallation() (*Installation, error) {
+	stagingDir, err := os.MkdirTemp(filepath.Dir(ffp.Path), ".ffcss-staging-"+ffp.FullName()+"-*")
+	if err != nil {
+		return nil, fmt.Errorf("while creating staging directory: %w", err)
+	}
+
+	for _, directory := range []string{"chrome", "previous"} {
+		err = os.Mkdir(filepath.Join(stagingDir, directory), 0700)
+		if err != nil {
+			os.RemoveAll(stagingDir)
+			return nil, fmt.Errorf("while creating staging directory: %w", err)
+		}
+	}
+
+	LogDebug("staging installation on %s in %s", ffp.Path, stagingDir)
+	return &Installation{
+		Profile:    ffp,
+		StagingDir: stagingDir,
+		movedOut:   make(map[string]bool),
+		movedIn:    make(map[string]bool),
+	}, nil
+}
+
+// Commit swaps the staged files in: the profile's current chrome/ directory and user.js are moved to the staging directory,
+// and the staged ones take their place.
+// If swapping fails half-way, the profile is restored to its previous state.
+func (inst *Installation) Commit() error {
+	inst.committed = true
+	for _, name := range installationSwappedFiles {
+		err := renameIfExists(filepath.Join(inst.Profile.Path, name), filepath.Join(inst.StagingDir, "previous", name))
+		if err != nil {
+			return inst.failedCommit(fmt.Errorf("while moving the current %s out of the way: %w", name, err))
+		}
+		inst.movedOut[name] = true
+	}
+	for _, name := range installationSwappedFiles {
+		err := renameIfExists(filepath.Join(inst.StagingDir, name), filepath.Join(inst.Profile.Path, name))
+		if err != nil {
+			return inst.failedCommit(fmt.Errorf("while moving the new %s in: %w", name, err))
+		}
+		inst.movedIn[name] = true
+	}
+	LogDebug("committed installation on %s", inst.Profile.Path)
+	return nil
+}
+
+func (inst *Installation) failedCommit(err error) error {
+	if rollbackErr := inst.Rollback(); rollbackErr != nil {
+		return fmt.Errorf("%w (and restoring the profile failed: %s)", err, rollbackErr)
+	}
+	return err
+}
+
+// Rollback cancels the installation.
+// If it was already committed, the profile's previous chrome/ directory and user.js are restored.
+// The staging directory is removed.
+func (inst *Installation) Rollback() error {
+	if inst.committed {
+		for _, name := range installationSwappedFiles {
+			if inst.movedIn[name] {
+				err := os.RemoveAll(filepath.Join(inst.Profile.Path, name))
+				if err != nil {
+					return fmt.Errorf("while removing the new %s: %w", name, err)
+				}
+				delete(inst.movedIn, name)
+			}
+			if inst.movedOut[name] {
+				err := renameIfExists(filepath.Join(inst.StagingDir, "previous", name), filepath.Join(inst.Profile.Path, name))
+				if err != nil {
+					return fmt.Errorf("while restoring the previous %s: %w", name, err)
+				}
+				delete(inst.movedOut, name)
+			}
+		}
+		inst.committed = false
+		LogDebug("rolled back installation on %s", inst.Profile.Path)
+	}
+	return os.RemoveAll(inst.StagingDir)
+}
+
+// Finish ends a committed installation: the profile's previous chrome/ directory and user.js are moved to a new backup
+// (see FirefoxProfile.Backups), record is saved as the profile's install record, and the staging directory is removed.
+// found is false if the profile had neither a chrome/ directory nor a user.js, in which case no backup is created.
+// If backing up or saving the record fails, the previous files are moved back, so that the installation can still be rolled back.
+func (inst *Installation) Finish(record InstallRecord) (backup Backup, found bool, err error) {
+	if !inst.committed {
+		return Backup{}, false, fmt.Errorf("cannot finish an installation that was not committed")
+	}
+	backup, found, err = inst.Profile.backUpFrom(filepath.Join(inst.StagingDir, "previous"))
+	if err != nil {
+		return backup, found, inst.failedFinish(backup, found, fmt.Errorf("while backing up the previous theme: %w", err))
+	}
+	err = inst.Profile.RecordInstallation(record)
+	if err != nil {
+		return backup, found, inst.failedFinish(backup, found, fmt.Errorf("while recording installed files: %w", err))
+	}
+	err = os.RemoveAll(inst.StagingDir)
+	if err != nil {
+		LogWarning("couldn't remove the staging directory %s: %s", inst.StagingDir, err)
+	}
+	return backup, found, nil
+}
+
+// failedFinish moves the files of the backup created by Finish back to the staging directory and removes the backup, after err happened.
+func (inst *Installation) failedFinish(backup Backup, found bool, err error) error {
+	if !found || backup.Path == "" {
+		return err
+	}
+	for _, name := range installationSwappedFiles {
+		moveErr := renameIfExists(filepath.Join(backup.Path, name), filepath.Join(inst.StagingDir, "previous", name))
+		if moveErr != nil {
+			return fmt.Errorf("%w (and moving the previous %s back out of %s failed: %s)", err, name, backup.Path, moveErr)
+		}
+	}
+	os.RemoveAll(backup.Path)
+	return err
+}
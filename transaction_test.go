@@ -0,0 +1,82 @@
+package ffcss
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstallationTransaction(t *testing.T) {
+	profile := NewFirefoxProfileFromPath(filepath.Join(testarea, "transaction", "abcdefgh.transactional"))
+	inProfile := func(path string) string {
+		return filepath.Join(profile.Path, path)
+	}
+	readFile := func(path string) string {
+		content, _ := os.ReadFile(path)
+		return string(content)
+	}
+	os.MkdirAll(inProfile("chrome"), 0700)
+	os.WriteFile(inProfile("chrome/userChrome.css"), []byte("/* previous */"), 0700)
+	os.WriteFile(inProfile("user.js"), []byte("// previous"), 0700)
+
+	// Rolling back an uncommitted installation leaves the profile untouched
+	installation, err := profile.BeginInstallation()
+	assert.NoError(t, err)
+	assert.DirExists(t, filepath.Join(installation.StagingDir, "chrome"))
+	os.WriteFile(filepath.Join(installation.StagingDir, "chrome", "userChrome.css"), []byte("/* new */"), 0700)
+	assert.NoError(t, installation.Rollback())
+	assert.NoDirExists(t, installation.StagingDir)
+	assert.Equal(t, "/* previous */", readFile(inProfile("chrome/userChrome.css")))
+
+	// Rolling back a committed installation restores the previous files
+	installation, err = profile.BeginInstallation()
+	assert.NoError(t, err)
+	os.WriteFile(filepath.Join(installation.StagingDir, "chrome", "userChrome.css"), []byte("/* new */"), 0700)
+	os.WriteFile(filepath.Join(installation.StagingDir, "user.js"), []byte("// new"), 0700)
+	assert.NoError(t, installation.Commit())
+	assert.Equal(t, "/* new */", readFile(inProfile("chrome/userChrome.css")))
+	assert.Equal(t, "// new", readFile(inProfile("user.js")))
+	assert.NoError(t, installation.Rollback())
+	assert.NoDirExists(t, installation.StagingDir)
+	assert.Equal(t, "/* previous */", readFile(inProfile("chrome/userChrome.css")))
+	assert.Equal(t, "// previous", readFile(inProfile("user.js")))
+
+	// Finishing a committed installation moves the previous files to a backup
+	installation, err = profile.BeginInstallation()
+	assert.NoError(t, err)
+	record := InstallRecord{Theme: "transactional", Files: map[string]string{}}
+	_, _, err = installation.Finish(record)
+	assert.EqualError(t, err, "cannot finish an installation that was not committed")
+	os.WriteFile(filepath.Join(installation.StagingDir, "chrome", "userChrome.css"), []byte("/* new */"), 0700)
+	assert.NoError(t, installation.Commit())
+	backup, found, err := installation.Finish(record)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	actual, _, err := profile.InstallRecord()
+	assert.NoError(t, err)
+	assert.Equal(t, record, actual)
+	assert.NoDirExists(t, installation.StagingDir)
+	assert.Equal(t, "/* new */", readFile(inProfile("chrome/userChrome.css")))
+	assert.NoFileExists(t, inProfile("user.js"))
+	assert.Equal(t, "/* previous */", readFile(filepath.Join(backup.Path, "chrome", "userChrome.css")))
+	assert.Equal(t, "// previous", readFile(filepath.Join(backup.Path, "user.js")))
+
+	// Failing to save the install record leaves the installation as it was, so that it can be rolled back
+	os.Remove(profile.installRecordPath())
+	os.Symlink(filepath.Join(testarea, "transaction", "nowhere", "record.yaml"), profile.installRecordPath())
+	defer os.Remove(profile.installRecordPath())
+	backups, _ := profile.Backups()
+	installation, err = profile.BeginInstallation()
+	assert.NoError(t, err)
+	os.WriteFile(filepath.Join(installation.StagingDir, "chrome", "userChrome.css"), []byte("/* newer */"), 0700)
+	assert.NoError(t, installation.Commit())
+	_, _, err = installation.Finish(record)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "while recording installed files")
+	stillBackups, _ := profile.Backups()
+	assert.Len(t, stillBackups, len(backups))
+	assert.NoError(t, installation.Rollback())
+	assert.Equal(t, "/* new */", readFile(inProfile("chrome/userChrome.css")))
+}
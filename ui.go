@@ -29,6 +29,31 @@ var BulletColorsByIndentLevel = []string{
 
 var colorizer colorstring.Colorize
 
+// NonInteractive makes ffcss never prompt: questions get their default answer, or fail with a NoInputError when they have none.
+// It is set by --yes, --no-input and --json, and when the standard input is not a terminal (see StdinIsTerminal).
+var NonInteractive = false
+
+// AssumeYes makes NonInteractive answer yes to questions that confirm an action, such as ConfirmInstallAddons, instead of no.
+var AssumeYes = false
+
+// NoInputError is returned when a choice that has no default answer has to be made while NonInteractive is set.
+type NoInputError struct {
+	// Choice is what has to be chosen, e.g. "the profiles to use"
+	Choice string
+	// Hint tells how to make that choice without a prompt, e.g. "use --profiles"
+	Hint string
+}
+
+func (e NoInputError) Error() string {
+	return fmt.Sprintf("can't ask for %s without a terminal (or with --yes, --no-input or --json), %s", e.Choice, e.Hint)
+}
+
+// StdinIsTerminal returns true if the standard input is a terminal, i.e. if the user can answer prompts.
+func StdinIsTerminal() bool {
+	stat, err := os.Stdin.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
 func init() {
 	colorizer.Colors = colorstring.DefaultColors
 	colorizer.Colors["italic"] = "3"
@@ -46,17 +71,14 @@ func printfln(s string, args ...interface{}) {
 
 // DescribeTheme shows the introduction message before installation
 func DescribeTheme(theme Theme, indentLevel uint) {
+	if JSONOutput {
+		emitEvent(Event{Type: EventStep, Level: indentLevel, Message: "Installing " + theme.Name()})
+		return
+	}
 	printf("\n")
 	indentation := strings.Repeat(indent, int(indentLevel))
 
-	var author string
-	urlParts := strings.Split(theme.DownloadAt, "/")
-	LogDebug("urlParts is %#v", urlParts)
-	if theme.Author != "" {
-		author = theme.Author
-	} else if strings.Contains(theme.DownloadAt, "github.com") && len(urlParts) == 5 {
-		author = urlParts[len(urlParts)-2]
-	}
+	author := theme.AuthorName()
 
 	printf(indentation)
 
@@ -99,10 +121,14 @@ func DescribeTheme(theme Theme, indentLevel uint) {
 
 }
 
+// showManifestSource displays the theme's manifest, syntax-highlighted. Nothing is shown with JSONOutput.
 func showManifestSource(theme Theme) {
+	if JSONOutput {
+		return
+	}
 	printf("\n")
 	printfln(colorizer.Color("[italic][dim]" + theme.Name() + "'s manifest"))
-	chromaQuick.Highlight(os.Stdout, theme.raw, "YAML", "terminal16m", "pygments")
+	chromaQuick.Highlight(out, theme.raw, "YAML", "terminal16m", "pygments")
 	printf("\n")
 }
 
@@ -122,20 +148,74 @@ func plural(singular string, amount int, optionalPlural ...string) string {
 	return plural
 }
 
-// LogDebug prints a debug log line. This one always prints to the real stdout, ignoring a possibly mocked stdout
+// showDownloadProgress displays the progress of a download on a single line, that is updated in place.
+// The line is ended once the download is complete.
+// Progress is not reported in JSON mode.
+func showDownloadProgress(downloaded int64, total int64) {
+	if JSONOutput {
+		return
+	}
+	prefix := strings.Repeat(indent, int(BaseIndentLevel+1)) + colorizer.Color("[dim]")
+	if total < 0 {
+		printf("\r%sDownloaded %s"+colorizer.Color("[reset]"), prefix, humanizeBytes(downloaded))
+		return
+	}
+	printf("\r%sDownloaded %s of %s (%d%%)"+colorizer.Color("[reset]"), prefix, humanizeBytes(downloaded), humanizeBytes(total), downloaded*100/maxInt64(total, 1))
+	if downloaded >= total {
+		printf("\n")
+	}
+}
+
+// humanizeBytes returns a human-readable size, such as 4.2 MB
+func humanizeBytes(size int64) string {
+	units := []string{"B", "kB", "MB", "GB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1000 && unit < len(units)-1 {
+		value /= 1000
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", size, units[0])
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
+
+func maxInt64(a int64, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// LogDebug prints a debug log line when the DEBUG environment variable is set.
+// Outside of JSON mode, this one always prints to the real stdout, ignoring a possibly mocked stdout
 func LogDebug(s string, fmtArgs ...interface{}) {
-	if os.Getenv("DEBUG") != "" {
-		fmt.Printf(colorizer.Color("[dim][ DEBUG ] "+s+"\n"), fmtArgs...)
+	if os.Getenv("DEBUG") == "" {
+		return
+	}
+	if JSONOutput {
+		emitEvent(Event{Type: EventDebug, Message: fmt.Sprintf(s, fmtArgs...)})
+		return
 	}
+	fmt.Printf(colorizer.Color("[dim][ DEBUG ] "+s+"\n"), fmtArgs...)
 }
 
 // LogWarning prints a log line with "warning" styling
 func LogWarning(s string, fmtArgs ...interface{}) {
+	if JSONOutput {
+		emitEvent(Event{Type: EventWarning, Message: plainText(fmt.Sprintf(s, fmtArgs...))})
+		return
+	}
 	printf(colorizer.Color("[yellow][bold]"+s+"\n"), fmtArgs...)
 }
 
 // LogError is like warn but with "error" styling
 func LogError(s string, fmtArgs ...interface{}) {
+	if JSONOutput {
+		emitEvent(Event{Type: EventError, Message: plainText(fmt.Sprintf(s, fmtArgs...))})
+		return
+	}
 	printf(colorizer.Color("[red][bold]"+s+"\n"), fmtArgs...)
 }
 
@@ -147,6 +227,10 @@ func LogStep(indentLevel uint, item string, fmtArgs ...interface{}) {
 // LogStepC is like Step, but the bullet point characters is customizable
 func LogStepC(bulletChar string, indentLevel uint, item string, fmtArgs ...interface{}) {
 	indentLevel += BaseIndentLevel
+	if JSONOutput {
+		emitEvent(Event{Type: EventStep, Level: indentLevel, Message: plainText(fmt.Sprintf(item, fmtArgs...))})
+		return
+	}
 	var color string
 	if int(indentLevel) > len(BulletColorsByIndentLevel)-1 {
 		color = BulletColorsByIndentLevel[len(BulletColorsByIndentLevel)-1]
@@ -165,7 +249,17 @@ func (ffp FirefoxProfile) Display() string {
 	return colorizer.Color(fmt.Sprintf("[bold]%s [reset][dim](%s)", ffp.Name, ffp.ID))
 }
 
+// askOne prompts the user with survey. With JSONOutput, the prompt is written to the standard error instead,
+// so that the standard output only has JSON events.
+func askOne(prompt survey.Prompt, response interface{}) error {
+	if JSONOutput {
+		return survey.AskOne(prompt, response, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr))
+	}
+	return survey.AskOne(prompt, response)
+}
+
 // AskProfiles prompts the user to select one or more profiles from the given array, and returns the user's chosen profiles.
+// When profiles belong to several browsers, they are grouped by browser.
 func AskProfiles(profiles []FirefoxProfile) []FirefoxProfile {
 	var selectedProfiles []FirefoxProfile
 
@@ -174,29 +268,47 @@ func AskProfiles(profiles []FirefoxProfile) []FirefoxProfile {
 
 	LogStep(0, "Please select profiles to apply the theme on")
 
-	profileDirsDisplay := make([]string, 0)
+	groups := make([]string, 0)
+	profilesByBrowser := make(map[string][]FirefoxProfile)
 	for _, profile := range profiles {
-		profileDirsDisplay = append(profileDirsDisplay, profile.Display())
+		if _, seen := profilesByBrowser[profile.Browser]; !seen {
+			groups = append(groups, profile.Browser)
+		}
+		profilesByBrowser[profile.Browser] = append(profilesByBrowser[profile.Browser], profile)
+	}
+
+	profileDirsDisplay := make([]string, 0)
+	profilesByDisplay := make(map[string]FirefoxProfile)
+	for _, browser := range groups {
+		for _, profile := range profilesByBrowser[browser] {
+			display := profile.Display()
+			if len(groups) > 1 {
+				display = colorizer.Color("[blue]"+browser+" › [reset]") + display
+			}
+			profileDirsDisplay = append(profileDirsDisplay, display)
+			profilesByDisplay[display] = profile
+		}
 	}
 
-	survey.AskOne(&survey.MultiSelect{
+	askOne(&survey.MultiSelect{
 		Message: "Select profiles",
 		Options: profileDirsDisplay,
 		VimMode: vimModeEnabled(),
 	}, &selectedProfileDirsDisplay)
 
 	for _, chosenProfileDisplay := range selectedProfileDirsDisplay {
-		selectedProfiles = append(selectedProfiles, NewFirefoxProfileFromDisplay(chosenProfileDisplay, profiles))
+		selectedProfiles = append(selectedProfiles, profilesByDisplay[chosenProfileDisplay])
 	}
 
 	return selectedProfiles
 }
 
 // AskToSeeManifestSource prompts the user to display the theme's manifest, and, if the user accepts, displays it.
+// It is skipped when NonInteractive or JSONOutput is set.
 func (t Theme) AskToSeeManifestSource(skip bool) {
 	wantsSource := false
-	if !skip {
-		survey.AskOne(&survey.Confirm{
+	if !skip && !NonInteractive && !JSONOutput {
+		askOne(&survey.Confirm{
 			Message: "Show the manifest source?",
 		}, &wantsSource)
 	}
@@ -209,29 +321,75 @@ func (t Theme) AskToSeeManifestSource(skip bool) {
 // If the users interrupts the prompt (by e.g. pressing Ctrl-C), cancel is true.
 // Else, the selected variant is returned and cancel is false.
 // If no variants are available, the empty variant is returned and cancel is false (and the user does not get prompted).
-func (t Theme) ChooseVariant() (chosen Variant, cancel bool) {
+// When NonInteractive is set, a NoInputError is returned instead of prompting.
+func (t Theme) ChooseVariant() (chosen Variant, cancel bool, err error) {
 	var variantName string
 	if len(t.AvailableVariants()) > 0 {
+		if NonInteractive {
+			return Variant{}, false, NoInputError{
+				Choice: "the variant to use",
+				Hint:   fmt.Sprintf("give it after the theme's name. Available variants are %s", strings.Join(t.AvailableVariants(), ", ")),
+			}
+		}
 		LogStep(0, "Please choose the theme's variant")
 		variantPrompt := &survey.Select{
 			Message: "Install variant",
 			Options: t.AvailableVariants(),
 			VimMode: vimModeEnabled(),
 		}
-		survey.AskOne(variantPrompt, &variantName)
+		askOne(variantPrompt, &variantName)
 		// user Ctrl-C'd
 		if variantName == "" {
-			return Variant{}, true
+			return Variant{}, true, nil
+		}
+		return t.Variants[variantName], false, nil
+	}
+	return Variant{}, false, nil
+}
+
+// ChooseComponents asks the user to choose any number of components.
+// If the chosen components are incompatible with each other, the user is asked again.
+// If no components are available, no components are returned (and the user does not get prompted).
+// When NonInteractive is set, no components are enabled.
+func (t Theme) ChooseComponents() []Component {
+	if len(t.AvailableComponents()) == 0 {
+		return []Component{}
+	}
+	if NonInteractive {
+		LogStep(0, "[dim]Enabling no components, use --components to choose some")
+		return []Component{}
+	}
+
+	LogStep(0, "Please choose the theme's components")
+	for _, name := range t.AvailableComponents() {
+		if description := strings.TrimSpace(t.Components[name].Description); description != "" {
+			LogStep(1, "[bold]%s[reset] [dim]%s", name, strings.ReplaceAll(description, "\n", " "))
+		}
+	}
+	for {
+		var componentNames []string
+		askOne(&survey.MultiSelect{
+			Message: "Enable components",
+			Options: t.AvailableComponents(),
+			VimMode: vimModeEnabled(),
+		}, &componentNames)
+
+		components, err := t.ComponentsByName(componentNames)
+		if err == nil {
+			return components
 		}
-		return t.Variants[variantName], false
+		LogWarning("%s, please choose again", err.Error())
 	}
-	return Variant{}, false
 }
 
 // ConfirmInstallAddons asks the user to confirm the installation of addons.
+// When NonInteractive is set, the answer is AssumeYes.
 func ConfirmInstallAddons(addons []string) bool {
+	if NonInteractive {
+		return AssumeYes
+	}
 	acceptOpenExtensionPages := false
-	survey.AskOne(&survey.Confirm{
+	askOne(&survey.Confirm{
 		Message: fmt.Sprintf("This theme suggests installing %d %s. Open %s?",
 			len(addons),
 			plural("addon", len(addons)),
@@ -244,6 +402,10 @@ func ConfirmInstallAddons(addons []string) bool {
 
 // ShowHookOutput displays the given output text with additional horizontal and vertical padding
 func ShowHookOutput(output string) {
+	if JSONOutput {
+		emitEvent(Event{Type: EventStep, Level: BaseIndentLevel + 2, Message: plainText(output)})
+		return
+	}
 	fmt.Fprint(
 		out,
 		"\n",
@@ -256,35 +418,48 @@ func ShowHookOutput(output string) {
 	)
 }
 
-// DisplayErrorMessage displays a nested error message (split on colons)
+// DisplayErrorMessage displays a nested error message (split on colons).
+// In JSON mode, the whole message is emitted as a single error event.
 func DisplayErrorMessage(err error) {
+	if JSONOutput {
+		LogError("%s", err)
+		return
+	}
 	for idx, errorFragment := range strings.Split(err.Error(), ":") {
 		LogStep(uint(idx), errorFragment)
 	}
 }
 
-// SelectProfiles returns an array of FirefoxProfile:
+// SelectProfiles returns an array of FirefoxProfile, from dir if it is not empty, or from the browser's profiles directory (all installed browsers' if browser is empty):
 //
 //    If selected is non-empty, it parses the paths into an array of FirefoxProfile
+//    Else, it returns the default profiles if useDefault is true (see DefaultProfiles)
 //    Else, it returns all profiles if all is true
-//    Else, it asks the user to select one or more profiles and returns those
-func SelectProfiles(selected []string, dir string, useDefault bool, all bool) ([]FirefoxProfile, error) {
+//    Else, it asks the user to select one or more profiles and returns those, or returns a NoInputError when NonInteractive is set
+func SelectProfiles(selected []string, dir string, browser string, useDefault bool, all bool) ([]FirefoxProfile, error) {
 	var selectedProfiles []FirefoxProfile
 	if len(selected) > 0 {
+		// Selected profiles don't have to be in the profiles directory, so it's fine if it can't be read
+		profiles, err := SelectableProfiles(dir, browser)
+		if err != nil {
+			LogDebug("couldn't get profiles, using selected paths as is: %s", err)
+		}
 		for _, profilePath := range selected {
-			selectedProfiles = append(selectedProfiles, NewFirefoxProfileFromPath(profilePath))
+			if profile, found := FindProfile(profiles, profilePath); found {
+				selectedProfiles = append(selectedProfiles, profile)
+			} else {
+				selectedProfiles = append(selectedProfiles, NewFirefoxProfileFromPath(profilePath))
+			}
 		}
 	} else {
 		LogStep(0, "Getting profiles")
-		profiles, err := Profiles(dir)
+		profiles, err := SelectableProfiles(dir, browser)
 		if err != nil {
 			return []FirefoxProfile{}, fmt.Errorf("couldn't get profile directories: %w", err)
 		}
 		if useDefault {
-			for _, profile := range profiles {
-				if profile.Name == "default-release" {
-					return []FirefoxProfile{profile}, nil
-				}
+			if defaults := DefaultProfiles(profiles); len(defaults) > 0 {
+				return defaults, nil
 			}
 		}
 		// Choose profiles
@@ -292,6 +467,11 @@ func SelectProfiles(selected []string, dir string, useDefault bool, all bool) ([
 		if all {
 			LogStep(0, "Selecting all profiles")
 			selectedProfiles = profiles
+		} else if NonInteractive {
+			return []FirefoxProfile{}, NoInputError{
+				Choice: "the profiles to use",
+				Hint:   "use --profiles, --default-profile or --all-profiles",
+			}
 		} else {
 			selectedProfiles = AskProfiles(profiles)
 		}
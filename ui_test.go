@@ -1 +1,38 @@
 package ffcss
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNonInteractive(t *testing.T) {
+	NonInteractive = true
+	defer func() { NonInteractive, AssumeYes = false, false }()
+
+	profilesDir := filepath.Join(mockedHomedir, ".mozilla", "firefox")
+	_, err := SelectProfiles([]string{}, profilesDir, "", false, false)
+	assert.True(t, errors.As(err, &NoInputError{}))
+	assert.EqualError(t, err, "can't ask for the profiles to use without a terminal (or with --yes, --no-input or --json), use --profiles, --default-profile or --all-profiles")
+	profiles, err := SelectProfiles([]string{}, profilesDir, "", false, true)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, profiles)
+
+	theme := NewTheme()
+	variant, cancel, err := theme.ChooseVariant()
+	assert.NoError(t, err)
+	assert.False(t, cancel)
+	assert.Equal(t, Variant{}, variant)
+	theme.Variants = map[string]Variant{"dark": {Name: "dark"}}
+	_, _, err = theme.ChooseVariant()
+	assert.EqualError(t, err, "can't ask for the variant to use without a terminal (or with --yes, --no-input or --json), give it after the theme's name. Available variants are dark")
+
+	theme.Components = map[string]Component{"tabs": {Name: "tabs"}}
+	assert.Empty(t, theme.ChooseComponents())
+
+	assert.False(t, ConfirmInstallAddons([]string{"https://addons.mozilla.org/firefox/addon/ublock-origin/"}))
+	AssumeYes = true
+	assert.True(t, ConfirmInstallAddons([]string{"https://addons.mozilla.org/firefox/addon/ublock-origin/"}))
+}
@@ -0,0 +1,221 @@
+package ffcss
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ThemeUpdate describes what ffcss update did to a cached copy of a theme.
+type ThemeUpdate struct {
+	Theme string `json:"theme"`
+	// Directory is the cached copy, e.g. ~/.cache/ffcss/<theme>/<variant>
+	Directory string `json:"directory"`
+	// From and To are the commits the cached copy was at before and after the update
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Commits are the one-line descriptions of the commits that were pulled in, most recent first
+	Commits []string `json:"commits"`
+	// Skipped explains why the cached copy was not updated, if it wasn't
+	Skipped string `json:"skipped,omitempty"`
+	// Error is why updating the cached copy failed, if it did
+	Error string `json:"error,omitempty"`
+}
+
+// Updated returns true if the update changed the cached copy of the theme.
+func (u ThemeUpdate) Updated() bool {
+	return u.Skipped == "" && u.Error == "" && u.From != u.To
+}
+
+// Range returns the range of commits that were pulled in, as in git log FROM..TO.
+func (u ThemeUpdate) Range() string {
+	return shortCommit(u.From) + ".." + shortCommit(u.To)
+}
+
+// shortCommit abbreviates a commit SHA.
+func shortCommit(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// CachedThemes returns the names of the themes in the cache.
+func CachedThemes() ([]string, error) {
+	entries, err := os.ReadDir(CacheDir())
+	if err != nil {
+		return []string{}, fmt.Errorf("while listing %s: %w", CacheDir(), err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") && entry.Name() != scratchProfilesDirName {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// UpdateCachedTheme updates all the cached copies (one per variant) of the theme named themeName.
+// See UpdateCachedCopy. The new revisions are recorded in the lock file.
+// A cached copy that fails to update does not prevent the others from being updated:
+// its error is set on its ThemeUpdate, and the errors of all cached copies are returned together.
+func UpdateCachedTheme(themeName string) ([]ThemeUpdate, error) {
+	updates := make([]ThemeUpdate, 0)
+	copies, err := os.ReadDir(CacheDir(themeName))
+	if os.IsNotExist(err) {
+		return updates, fmt.Errorf("theme %q is not in the cache", themeName)
+	}
+	if err != nil {
+		return updates, fmt.Errorf("while listing %s: %w", CacheDir(themeName), err)
+	}
+
+	// Pins of themes from the catalog are only in the catalog's manifest
+	catalogManifest, catalogErr := Theme{}, fmt.Errorf("no catalog")
+	if catalog, err := LoadCatalog(ConfigDir("themes")); err == nil {
+		catalogManifest, catalogErr = catalog.Lookup(themeName)
+	}
+
+	failures := make([]string, 0)
+	for _, cachedCopy := range copies {
+		if !cachedCopy.IsDir() {
+			continue
+		}
+		directory := CacheDir(themeName, cachedCopy.Name())
+		manifest := catalogManifest
+		if catalogErr != nil {
+			manifest, err = LoadManifest(filepath.Join(directory, "ffcss.yaml"))
+			if err != nil {
+				updates = append(updates, ThemeUpdate{Theme: themeName, Directory: directory, Skipped: "it has no manifest"})
+				continue
+			}
+		}
+		// Variants (and conditional blocks) can have their own pins, and are cached under <variant name>[@<hash>]
+		variantName := strings.SplitN(cachedCopy.Name(), "@", 2)[0]
+		if variant, found := manifest.Variants[variantName]; found {
+			manifest, _ = manifest.WithVariant(variant)
+		}
+
+		update, err := UpdateCachedCopy(directory, manifest)
+		update.Theme = themeName
+		if err != nil {
+			update.Error = err.Error()
+			failures = append(failures, fmt.Sprintf("while updating %s: %s", directory, err))
+		}
+		updates = append(updates, update)
+		if update.Updated() {
+			manifest.DownloadedTo = directory
+			origin, _ := runGit(directory, "remote", "get-url", "origin")
+			recordLockedRevision(manifest, origin, "git")
+		}
+	}
+	if len(failures) > 0 {
+		return updates, errors.New(strings.Join(failures, "; "))
+	}
+	return updates, nil
+}
+
+// UpdateCachedCopy fetches new commits of the git repository cloned at directory, and fast-forwards it.
+// The manifest's pins are respected: a cached copy pinned to a commit is never updated,
+// a cached copy pinned to a tag is moved to where the tag now points to, and otherwise the manifest's branch
+// (or the one that is checked out, if the manifest has none) is checked out and fast-forwarded.
+func UpdateCachedCopy(directory string, manifest Theme) (ThemeUpdate, error) {
+	update := ThemeUpdate{Theme: manifest.Name(), Directory: directory}
+	if stat, err := os.Stat(filepath.Join(directory, ".git")); err != nil || !stat.IsDir() {
+		update.Skipped = "it was not downloaded from a git repository"
+		return update, nil
+	}
+	if manifest.Commit != "" {
+		update.Skipped = fmt.Sprintf("it is pinned to commit %s", manifest.Commit)
+		return update, nil
+	}
+
+	from, err := runGit(directory, "rev-parse", "HEAD")
+	if err != nil {
+		return update, err
+	}
+	update.From, update.To = from, from
+
+	var target string
+	if manifest.Tag != "" {
+		LogDebug("fetching tags of %s", directory)
+		_, err = runGit(directory, "fetch", "--tags", "--force", "origin")
+		if err != nil {
+			return update, err
+		}
+		target, err = runGit(directory, "rev-parse", "tags/"+manifest.Tag+"^{commit}")
+		if err != nil {
+			return update, err
+		}
+		if target != from {
+			_, err = runGit(directory, "checkout", "--quiet", target)
+			if err != nil {
+				return update, err
+			}
+		}
+	} else {
+		current, err := runGit(directory, "rev-parse", "--abbrev-ref", "HEAD")
+		if err != nil {
+			return update, err
+		}
+		branch := manifest.Branch
+		if branch == "" {
+			if current == "HEAD" {
+				update.Skipped = "it is not on a branch"
+				return update, nil
+			}
+			branch = current
+		}
+		LogDebug("fetching branch %s of %s", branch, directory)
+		_, err = runGit(directory, "fetch", "origin", branch)
+		if err != nil {
+			return update, err
+		}
+		if branch != current {
+			LogDebug("checking out branch %s of %s", branch, directory)
+			if _, err = runGit(directory, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+				_, err = runGit(directory, "checkout", "--quiet", branch)
+			} else {
+				_, err = runGit(directory, "checkout", "--quiet", "-b", branch, "FETCH_HEAD")
+			}
+			if err != nil {
+				return update, fmt.Errorf("while checking out branch %s: %w", branch, err)
+			}
+		}
+		_, err = runGit(directory, "merge", "--ff-only", "--quiet", "FETCH_HEAD")
+		if err != nil {
+			return update, fmt.Errorf("branch %s can't be fast-forwarded, the theme's history might have been rewritten. Run ffcss cache clear and use the theme again: %w", branch, err)
+		}
+		target, err = runGit(directory, "rev-parse", "HEAD")
+		if err != nil {
+			return update, err
+		}
+	}
+	update.To = target
+	if update.From == update.To {
+		return update, nil
+	}
+
+	log, err := runGit(directory, "log", "--format=%h %s", update.From+".."+update.To)
+	if err != nil {
+		return update, err
+	}
+	if log != "" {
+		update.Commits = strings.Split(log, "\n")
+	}
+	return update, nil
+}
+
+// UsesTheme returns true if the current theme is the theme named themeName, whether it was used with that name,
+// with the URL of its repository (or archive), or with the path of its folder.
+func (current CurrentTheme) UsesTheme(themeName string) bool {
+	used := strings.TrimSuffix(strings.TrimRight(filepath.ToSlash(current.Name), "/"), ".git")
+	if lookupPreprocess(used) == lookupPreprocess(themeName) {
+		return true
+	}
+	segments := strings.Split(used, "/")
+	return len(segments) > 1 && lookupPreprocess(segments[len(segments)-1]) == lookupPreprocess(themeName)
+}
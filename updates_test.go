@@ -0,0 +1,118 @@
+package ffcss
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gitIn runs git in directory, failing the test if it does not succeed.
+func gitIn(t *testing.T, directory string, args ...string) {
+	command := exec.Command("git", append([]string{"-c", "user.name=ffcss", "-c", "user.email=ffcss@example.com", "-c", "init.defaultBranch=main"}, args...)...)
+	command.Dir = directory
+	if output, err := command.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %s: %s", args, err, output)
+	}
+}
+
+func TestUpdateCachedTheme(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	upstream := filepath.Join(testarea, "updatable-upstream")
+	os.RemoveAll(upstream)
+	os.RemoveAll(CacheDir("updatable"))
+	os.MkdirAll(upstream, 0700)
+	os.MkdirAll(CacheDir("updatable"), 0700)
+	os.WriteFile(filepath.Join(upstream, "ffcss.yaml"), []byte("name: updatable\nuserChrome: userChrome.css\n"), 0600)
+	os.WriteFile(filepath.Join(upstream, "userChrome.css"), []byte("#nav-bar {}"), 0600)
+	gitIn(t, upstream, "init", "--quiet")
+	gitIn(t, upstream, "add", ".")
+	gitIn(t, upstream, "commit", "--quiet", "-m", "Initial commit")
+	gitIn(t, CacheDir("updatable"), "clone", "--quiet", upstream, RootVariantName)
+
+	updates, err := UpdateCachedTheme("updatable")
+	assert.NoError(t, err)
+	assert.Len(t, updates, 1)
+	assert.False(t, updates[0].Updated())
+	assert.Empty(t, updates[0].Commits)
+
+	os.WriteFile(filepath.Join(upstream, "userChrome.css"), []byte("#nav-bar { display: none; }"), 0600)
+	gitIn(t, upstream, "commit", "--quiet", "-am", "Hide the navigation bar")
+	updates, err = UpdateCachedTheme("updatable")
+	assert.NoError(t, err)
+	assert.True(t, updates[0].Updated())
+	assert.Equal(t, "updatable", updates[0].Theme)
+	assert.Len(t, updates[0].Commits, 1)
+	assert.Contains(t, updates[0].Commits[0], "Hide the navigation bar")
+	assert.Regexp(t, `^[0-9a-f]{7}\.\.[0-9a-f]{7}$`, updates[0].Range())
+	content, _ := os.ReadFile(CacheDir("updatable", RootVariantName, "userChrome.css"))
+	assert.Equal(t, "#nav-bar { display: none; }", string(content))
+
+	// Pinned to a commit
+	update, err := UpdateCachedCopy(CacheDir("updatable", RootVariantName), Theme{Commit: updates[0].From})
+	assert.NoError(t, err)
+	assert.Equal(t, "it is pinned to commit "+updates[0].From, update.Skipped)
+	assert.False(t, update.Updated())
+
+	// Pinned to a tag that was moved
+	gitIn(t, upstream, "tag", "v1")
+	gitIn(t, upstream, "commit", "--quiet", "--allow-empty", "-m", "Release v1.1")
+	gitIn(t, upstream, "tag", "--force", "v1")
+	update, err = UpdateCachedCopy(CacheDir("updatable", RootVariantName), Theme{Tag: "v1"})
+	assert.NoError(t, err)
+	assert.True(t, update.Updated())
+	assert.Len(t, update.Commits, 1)
+	assert.Contains(t, update.Commits[0], "Release v1.1")
+
+	// Not a git repository
+	os.MkdirAll(CacheDir("updatable", "copied"), 0700)
+	update, err = UpdateCachedCopy(CacheDir("updatable", "copied"), Theme{})
+	assert.NoError(t, err)
+	assert.Equal(t, "it was not downloaded from a git repository", update.Skipped)
+
+	// The manifest's branch is checked out
+	gitIn(t, upstream, "checkout", "--quiet", "-b", "compact")
+	gitIn(t, upstream, "commit", "--quiet", "--allow-empty", "-m", "Make it compact")
+	gitIn(t, upstream, "checkout", "--quiet", "main")
+	update, err = UpdateCachedCopy(CacheDir("updatable", RootVariantName), Theme{Branch: "compact"})
+	assert.NoError(t, err)
+	assert.True(t, update.Updated())
+	assert.Contains(t, update.Commits[0], "Make it compact")
+	branch, _ := runGit(CacheDir("updatable", RootVariantName), "rev-parse", "--abbrev-ref", "HEAD")
+	assert.Equal(t, "compact", branch)
+
+	// A cached copy that fails to update does not prevent the others from being updated
+	gitIn(t, CacheDir("updatable"), "clone", "--quiet", upstream, "broken")
+	gitIn(t, CacheDir("updatable", "broken"), "remote", "set-url", "origin", filepath.Join(testarea, "nonexistent-upstream"))
+	gitIn(t, upstream, "checkout", "--quiet", "compact")
+	gitIn(t, upstream, "commit", "--quiet", "--allow-empty", "-m", "Fix the tabs")
+	updates, err = UpdateCachedTheme("updatable")
+	assert.Error(t, err)
+	if assert.Len(t, updates, 3) {
+		assert.Equal(t, CacheDir("updatable", RootVariantName), updates[0].Directory)
+		assert.True(t, updates[0].Updated())
+		assert.Equal(t, CacheDir("updatable", "broken"), updates[1].Directory)
+		assert.NotEmpty(t, updates[1].Error)
+		assert.False(t, updates[1].Updated())
+		assert.Equal(t, "it has no manifest", updates[2].Skipped)
+	}
+	assert.Contains(t, err.Error(), "while updating "+CacheDir("updatable", "broken"))
+
+	themes, err := CachedThemes()
+	assert.NoError(t, err)
+	assert.Contains(t, themes, "updatable")
+	assert.NotContains(t, themes, scratchProfilesDirName)
+}
+
+func TestCurrentThemeUsesTheme(t *testing.T) {
+	assert.True(t, CurrentTheme{Name: "materialfox"}.UsesTheme("materialfox"))
+	assert.True(t, CurrentTheme{Name: "MaterialFox"}.UsesTheme("materialfox"))
+	assert.True(t, CurrentTheme{Name: "https://github.com/muckSponge/MaterialFox.git"}.UsesTheme("materialfox"))
+	assert.True(t, CurrentTheme{Name: "/home/me/themes/materialfox/"}.UsesTheme("materialfox"))
+	assert.False(t, CurrentTheme{Name: "materialfox-updated"}.UsesTheme("materialfox"))
+	assert.False(t, CurrentTheme{Name: "https://github.com/materialfox/simplefox"}.UsesTheme("materialfox"))
+}
@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
-
+	"sort"
 	"strings"
 )
 
@@ -38,3 +38,63 @@ func ValueOfUserPrefCall(prefsJSContent []byte, key string) (string, error) {
 	}
 	return fmt.Sprint(jsonParsed), nil
 }
+
+// UserPrefNames returns the names of the configuration entries set by user_pref calls in a user.js or prefs.js file content, in order of appearance.
+func UserPrefNames(content []byte) []string {
+	pattern := regexp.MustCompile(`(?m)^\s*user_pref\(\s*"([^"]+)"\s*,`)
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, match := range pattern.FindAllSubmatch(content, -1) {
+		name := string(match[1])
+		if !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	return names
+}
+
+// RemoveUserPrefCalls returns the contents of a prefs.js or user.js file without the user_pref calls that set any of the given keys.
+func RemoveUserPrefCalls(content []byte, keys []string) []byte {
+	if len(keys) == 0 {
+		return content
+	}
+	quotedKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		quotedKeys = append(quotedKeys, regexp.QuoteMeta(key))
+	}
+	pattern := regexp.MustCompile(`(?m)^[ \t]*user_pref\(\s*"(` + strings.Join(quotedKeys, "|") + `)"\s*,.*\)[ \t]*;?[ \t]*\n?`)
+	return pattern.ReplaceAll(content, []byte{})
+}
+
+// UserPrefValues returns the values set by user_pref calls in a user.js or prefs.js file content, as JS source code, by name.
+// When a configuration entry is set more than once, the last value wins, as it does for Firefox.
+func UserPrefValues(content []byte) map[string]string {
+	pattern := regexp.MustCompile(`(?m)^[ \t]*user_pref\(\s*"([^"]+)"\s*,\s*(.+?)\s*\)[ \t]*;?[ \t]*$`)
+	values := make(map[string]string)
+	for _, match := range pattern.FindAllSubmatch(content, -1) {
+		values[string(match[1])] = string(match[2])
+	}
+	return values
+}
+
+// SetUserPrefCalls returns the contents of a prefs.js or user.js file where the given configuration entries are set to the given values,
+// which are JS source code (as returned by UserPrefValues). Previous user_pref calls for these entries are removed.
+func SetUserPrefCalls(content []byte, values map[string]string) []byte {
+	if len(values) == 0 {
+		return content
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	content = RemoveUserPrefCalls(content, keys)
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+	for _, key := range keys {
+		content = append(content, []byte(fmt.Sprintf("user_pref(%q, %s);\n", key, values[key]))...)
+	}
+	return content
+}
@@ -228,3 +228,44 @@ func TestValueOfUserPrefCall(t *testing.T) {
 	assert.Contains(t, err.Error(), `key "lkghjoertkjhoietrjhoirtjhoirtjhor" not found`)
 	assert.Equal(t, result, "")
 }
+
+func TestUserPrefNames(t *testing.T) {
+	assert.Equal(t, []string{"app.normandy.first_run", "app.normandy.migrationsApplied"}, UserPrefNames([]byte(`// user.js
+user_pref("app.normandy.first_run", false);
+  user_pref( "app.normandy.migrationsApplied", 12);
+user_pref("app.normandy.first_run", true);
+`)))
+}
+
+func TestRemoveUserPrefCalls(t *testing.T) {
+	assert.Equal(t, "// prefs.js\n\nuser_pref(\"app.normandy.first_run.not\", 1);\n", string(RemoveUserPrefCalls([]byte(`// prefs.js
+
+user_pref("app.normandy.first_run", false);
+user_pref("app.normandy.first_run.not", 1);
+user_pref("some.(weird)|pref", "hey");
+`), []string{"app.normandy.first_run", "some.(weird)|pref"})))
+	assert.Equal(t, "unchanged", string(RemoveUserPrefCalls([]byte("unchanged"), []string{})))
+}
+
+func TestUserPrefValues(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"app.normandy.first_run":         "true",
+		"app.normandy.migrationsApplied": "12",
+		"browser.search.region":          `"FR"`,
+	}, UserPrefValues([]byte(`// user.js
+user_pref("app.normandy.first_run", false);
+  user_pref( "app.normandy.migrationsApplied", 12 );
+user_pref("app.normandy.first_run", true);
+user_pref("browser.search.region", "FR");
+`)))
+}
+
+func TestSetUserPrefCalls(t *testing.T) {
+	assert.Equal(t, "// prefs.js\nuser_pref(\"app.normandy.first_run.not\", 1);\nuser_pref(\"app.normandy.first_run\", true);\nuser_pref(\"browser.search.region\", \"FR\");\n", string(SetUserPrefCalls([]byte(`// prefs.js
+user_pref("app.normandy.first_run", false);
+user_pref("app.normandy.first_run.not", 1);`), map[string]string{
+		"browser.search.region":  `"FR"`,
+		"app.normandy.first_run": "true",
+	})))
+	assert.Equal(t, "unchanged", string(SetUserPrefCalls([]byte("unchanged"), map[string]string{})))
+}
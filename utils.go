@@ -74,6 +74,42 @@ func renameIfExists(from string, to string) error {
 	return os.Rename(from, to)
 }
 
+// copyIfExists copies the file or directory from to to if from exists, replacing to if it exists.
+// Files and directories named like one of ignored are not copied.
+func copyIfExists(from string, to string, ignored ...string) error {
+	if _, err := os.Stat(from); os.IsNotExist(err) {
+		return nil
+	}
+	err := os.RemoveAll(to)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(from, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != from && contains(ignored, info.Name()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		relative, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		destination := filepath.Join(to, relative)
+		if info.IsDir() {
+			return os.MkdirAll(destination, info.Mode().Perm()|0700)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(destination, content, info.Mode().Perm())
+	})
+}
+
 // vimModeEnabled returns true if the user has explicitly set vim mode, or if the $EDITOR is vim/neovim
 func vimModeEnabled() bool {
 	if os.Getenv("VIM_MODE") == "1" || os.Getenv("VIM_STYLE") == "1" {
@@ -105,3 +141,13 @@ func GOOStoOS(GOOS string) string {
 		return GOOS
 	}
 }
+
+// contains returns true if needle is one of haystack's elements.
+func contains(haystack []string, needle string) bool {
+	for _, element := range haystack {
+		if element == needle {
+			return true
+		}
+	}
+	return false
+}